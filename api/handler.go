@@ -10,6 +10,7 @@ import (
 	"strconv"
 
 	"sadk.dev/logar"
+	"sadk.dev/logar/internal/otlp"
 )
 
 type HandlerConfig struct {
@@ -51,6 +52,7 @@ func init() {
 
 func (h *Handler) Router(mux *http.ServeMux) {
 	mux.HandleFunc("GET /language", h.GetLanguage)
+	mux.HandleFunc("GET /health", h.Health)
 
 	mux.HandleFunc("POST /auth/login", h.Login)
 	mux.HandleFunc("POST /auth/logout", h.AuthMiddleware(h.Logout))
@@ -79,6 +81,31 @@ func (h *Handler) Router(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /globals", h.AuthMiddleware(h.UpdateGlobal))
 	mux.HandleFunc("DELETE /globals", h.AuthMiddleware(h.DeleteGlobal))
 
+	mux.HandleFunc("GET /issues", h.AuthMiddleware(h.GetIssues))
+	mux.HandleFunc("PUT /issues", h.AuthMiddleware(h.UpdateIssue))
+	mux.HandleFunc("DELETE /issues", h.AuthMiddleware(h.DeleteIssue))
+
+	mux.HandleFunc("GET /traces", h.AuthMiddleware(h.GetTraces))
+	mux.HandleFunc("GET /traces/{traceId}", h.AuthMiddleware(h.GetTrace))
+
+	mux.HandleFunc("GET /proxies", h.AuthMiddleware(h.GetProxies))
+	mux.HandleFunc("POST /proxies", h.AuthMiddleware(h.CreateProxy))
+	mux.HandleFunc("PUT /proxies", h.AuthMiddleware(h.UpdateProxy))
+	mux.HandleFunc("DELETE /proxies", h.AuthMiddleware(h.DeleteProxy))
+	mux.HandleFunc("POST /proxies/enabled", h.AuthMiddleware(h.SetProxyEnabled))
+	mux.HandleFunc("GET /proxies/metrics", h.AuthMiddleware(h.GetProxyMetrics))
+	mux.HandleFunc("GET /proxies/dead-letters", h.AuthMiddleware(h.GetDeadLetters))
+	mux.HandleFunc("POST /proxies/dead-letters/replay", h.AuthMiddleware(h.ReplayDeadLetters))
+	mux.HandleFunc("DELETE /proxies/dead-letters", h.AuthMiddleware(h.DeleteDeadLetter))
+
+	mux.HandleFunc("POST /ingest", h.IngestAuthMiddleware(h.Ingest))
+	mux.HandleFunc("POST "+otlp.LogsPath, h.IngestAuthMiddleware(h.ReceiveOTLPLogs))
+	mux.HandleFunc("POST "+otlp.TracesPath, h.IngestAuthMiddleware(h.ReceiveOTLPTraces))
+
+	mux.HandleFunc("GET /ingest-tokens", h.AuthMiddleware(h.GetIngestTokens))
+	mux.HandleFunc("POST /ingest-tokens", h.AuthMiddleware(h.CreateIngestToken))
+	mux.HandleFunc("DELETE /ingest-tokens", h.AuthMiddleware(h.DeleteIngestToken))
+
 	if h.cfg.WebClientFiles != nil && !dev {
 		sub, err := fs.Sub(h.cfg.WebClientFiles, "build")
 		if err != nil {
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Health reports whether the server and its database are up, for load balancers and orchestrators.
+// It doesn't require authentication.
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.logger.Ping(ctx); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, map[string]any{
+			"status":   "unavailable",
+			"database": err.Error(),
+		}))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, map[string]any{
+		"status":   "ok",
+		"database": "ok",
+	}))
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/models"
+)
+
+const (
+	ingestCategory     = "ingest"
+	contentTypeNDJSON  = "application/x-ndjson"
+	contentTypeJSONL   = "application/jsonl"
+	ingestTimeMaxDrift = 24 * time.Hour
+)
+
+// IngestEntry is a log sent by a remote producer.
+type IngestEntry struct {
+	Model    string          `json:"model"`
+	Category string          `json:"category,omitempty"` // defaults to "ingest"
+	Severity json.RawMessage `json:"severity,omitempty"` // name such as "warn", or number. Defaults to log
+	Message  any             `json:"message"`
+	Fields   map[string]any  `json:"fields,omitempty"` // stored next to the message
+
+	// RFC 3339 string or unix milliseconds, defaults to the time the entry is received
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	TraceID   string          `json:"trace_id,omitempty"`
+	SpanID    string          `json:"span_id,omitempty"`
+
+	Caller     string `json:"caller,omitempty"` // file:line of the code that wrote the log
+	Function   string `json:"function,omitempty"`
+	StackTrace string `json:"stack_trace,omitempty"`
+}
+
+type IngestError struct {
+	Index int    `json:"index"` // position of the entry in the request, starting at 0
+	Error string `json:"error"`
+}
+
+type IngestResult struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Errors   []IngestError `json:"errors,omitempty"`
+}
+
+// Ingest writes logs of remote producers. The body is a JSON object, a JSON array of objects
+// or newline delimited JSON objects. Entries are validated one by one, invalid ones are
+// reported in the result while the others are written.
+func (h *Handler) Ingest(w http.ResponseWriter, r *http.Request) {
+	if !h.logger.IsIngestEnabled() {
+		w.WriteHeader(404)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, "Ingest is not enabled"))
+		return
+	}
+
+	limits := h.logger.GetIngestLimits()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, fmt.Sprintf("Request body is larger than %d bytes", limits.MaxBodySize)))
+			return
+		}
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, err.Error()))
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	rawEntries, err := splitIngestBody(body, mediaType == contentTypeNDJSON || mediaType == contentTypeJSONL)
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, err.Error()))
+		return
+	}
+	if len(rawEntries) == 0 {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "No entries in request body"))
+		return
+	}
+	if len(rawEntries) > limits.MaxEntries {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, fmt.Sprintf("Request has %d entries, at most %d are allowed", len(rawEntries), limits.MaxEntries)))
+		return
+	}
+
+	logger := h.logger.GetLogger()
+	result := IngestResult{Errors: []IngestError{}}
+	for i, raw := range rawEntries {
+		entry, err := h.parseIngestEntry(r, raw, limits)
+		if err == nil {
+			err = logger.Write(entry)
+		}
+		if err != nil {
+			result.Rejected++
+			result.Errors = append(result.Errors, IngestError{Index: i, Error: err.Error()})
+			continue
+		}
+		result.Accepted++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case result.Accepted == 0:
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, result))
+	case result.Rejected > 0:
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, result))
+	default:
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, result))
+	}
+}
+
+// splitIngestBody splits the body into the raw JSON of each entry. Lines that aren't valid JSON
+// are kept, so they are reported with their index.
+func splitIngestBody(body []byte, ndjson bool) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if !ndjson {
+		switch {
+		case trimmed[0] == '[':
+			var entries []json.RawMessage
+			if err := json.Unmarshal(trimmed, &entries); err != nil {
+				return nil, fmt.Errorf("invalid JSON array: %w", err)
+			}
+			return entries, nil
+		case json.Valid(trimmed):
+			return []json.RawMessage{trimmed}, nil
+		}
+	}
+
+	var entries []json.RawMessage
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, nil
+}
+
+func (h *Handler) parseIngestEntry(r *http.Request, raw json.RawMessage, limits logar.IngestLimits) (logar.Entry, error) {
+	var ingestEntry IngestEntry
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&ingestEntry); err != nil {
+		return logar.Entry{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	model := models.Model(strings.TrimSpace(ingestEntry.Model))
+	if model == "" {
+		return logar.Entry{}, errors.New("model is required")
+	}
+	if model == models.Model(logar.LogarLogs) {
+		return logar.Entry{}, fmt.Errorf("model %q is reserved", model)
+	}
+	if !allowsModel(r, model) {
+		return logar.Entry{}, fmt.Errorf("model %q is not allowed for this token", model)
+	}
+	if ingestEntry.Message == nil {
+		return logar.Entry{}, errors.New("message is required")
+	}
+
+	entry := logar.Entry{
+		Model:    logar.Model(model),
+		Category: ingestEntry.Category,
+		Severity: models.Severity_Log,
+		TraceID:  strings.ToLower(ingestEntry.TraceID),
+		SpanID:   strings.ToLower(ingestEntry.SpanID),
+
+		Caller:     ingestEntry.Caller,
+		Function:   ingestEntry.Function,
+		StackTrace: ingestEntry.StackTrace,
+	}
+	if entry.Category == "" {
+		entry.Category = ingestCategory
+	}
+
+	if len(ingestEntry.Severity) > 0 {
+		var severity string
+		if err := json.Unmarshal(ingestEntry.Severity, &severity); err != nil {
+			severity = string(ingestEntry.Severity)
+		}
+		parsed, ok := models.ParseSeverity(severity)
+		if !ok {
+			return logar.Entry{}, fmt.Errorf("invalid severity %s", ingestEntry.Severity)
+		}
+		entry.Severity = parsed
+	}
+
+	if len(ingestEntry.Timestamp) > 0 {
+		timestamp, err := parseIngestTimestamp(ingestEntry.Timestamp)
+		if err != nil {
+			return logar.Entry{}, err
+		}
+		if timestamp.After(time.Now().Add(ingestTimeMaxDrift)) {
+			return logar.Entry{}, errors.New("timestamp is too far in the future")
+		}
+		entry.Time = timestamp
+	}
+
+	if entry.TraceID != "" && (len(entry.TraceID) != 32 || !isHexString(entry.TraceID)) {
+		return logar.Entry{}, errors.New("trace_id must be 32 hex characters")
+	}
+	if entry.SpanID != "" && (len(entry.SpanID) != 16 || !isHexString(entry.SpanID)) {
+		return logar.Entry{}, errors.New("span_id must be 16 hex characters")
+	}
+
+	if len(ingestEntry.Fields) > 0 {
+		message := logar.Map{}
+		for k, v := range ingestEntry.Fields {
+			message[k] = v
+		}
+		message["message"] = ingestEntry.Message
+		entry.Message = message
+	} else {
+		entry.Message = ingestEntry.Message
+	}
+
+	size := len(raw)
+	if s, ok := entry.Message.(string); ok {
+		size = len(s)
+	} else if encoded, err := json.Marshal(entry.Message); err == nil {
+		size = len(encoded)
+	}
+	if size > limits.MaxMessageSize {
+		return logar.Entry{}, fmt.Errorf("message is larger than %d bytes", limits.MaxMessageSize)
+	}
+
+	return entry, nil
+}
+
+func parseIngestTimestamp(raw json.RawMessage) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		timestamp, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q, expected RFC 3339", s)
+		}
+		return timestamp, nil
+	}
+
+	millis, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s, expected RFC 3339 or unix milliseconds", raw)
+	}
+	return time.UnixMilli(millis), nil
+}
+
+func isHexString(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) GetIngestTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.logger.GetWebPanel().GetIngestTokens()
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, tokens))
+}
+
+func (h *Handler) CreateIngestToken(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'name' in request body"))
+		return
+	}
+
+	var allowedModels []logar.Model
+	for _, model := range strings.Split(r.FormValue("models"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			allowedModels = append(allowedModels, logar.Model(model))
+		}
+	}
+
+	ingestToken, token, err := h.logger.GetWebPanel().CreateIngestToken(name, allowedModels)
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	// The token can't be retrieved later
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, map[string]any{
+		"token":        token,
+		"ingest_token": ingestToken,
+	}))
+}
+
+func (h *Handler) DeleteIngestToken(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'id' in request body"))
+		return
+	}
+
+	err = h.logger.GetWebPanel().DeleteIngestToken(uint(id))
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, nil))
+}
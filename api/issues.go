@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sadk.dev/logar/models"
+)
+
+func (h *Handler) GetIssues(w http.ResponseWriter, r *http.Request) {
+	statuses := []models.IssueStatus{}
+	for _, status := range strings.Split(r.URL.Query().Get("status"), ",") {
+		if status != "" {
+			statuses = append(statuses, models.IssueStatus(status))
+		}
+	}
+
+	issues, err := h.logger.GetIssues().GetIssues(statuses...)
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, issues))
+}
+
+func (h *Handler) UpdateIssue(w http.ResponseWriter, r *http.Request) {
+	issueID := r.FormValue("id")
+	if issueID == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'id' in request body"))
+		return
+	}
+
+	issueIDUint, err := strconv.ParseUint(issueID, 10, 64)
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'id' in request body"))
+		return
+	}
+
+	status := models.IssueStatus(r.FormValue("status"))
+	if !status.IsValid() {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'status' in request body"))
+		return
+	}
+
+	err = h.logger.GetIssues().SetIssueStatus(uint(issueIDUint), status)
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	issue, err := h.logger.GetIssues().GetIssue(uint(issueIDUint))
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, issue))
+}
+
+func (h *Handler) DeleteIssue(w http.ResponseWriter, r *http.Request) {
+	issueID := r.URL.Query().Get("id")
+	if issueID == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'id' in request body"))
+		return
+	}
+
+	issueIDUint, err := strconv.ParseUint(issueID, 10, 64)
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'id' in request body"))
+		return
+	}
+
+	err = h.logger.GetIssues().DeleteIssue(uint(issueIDUint))
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, "Issue deleted"))
+}
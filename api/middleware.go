@@ -1,8 +1,11 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"strings"
+
+	"sadk.dev/logar/models"
 )
 
 func (h *Handler) SetMetadataMiddleware(next http.Handler) http.Handler {
@@ -54,3 +57,43 @@ func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+type ingestTokenContextKey struct{}
+
+// IngestAuthMiddleware authenticates remote producers with an ingest token. Web panel sessions
+// are accepted too and may write to every model.
+func (h *Handler) IngestAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			authorization = r.URL.Query().Get("token")
+		}
+
+		if authorization == "" {
+			WriteSessionExpired(w)
+			return
+		}
+
+		token := strings.TrimPrefix(authorization, "Bearer ")
+
+		ingestToken, err := h.logger.GetWebPanel().ValidateIngestToken(token)
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ingestTokenContextKey{}, ingestToken)))
+			return
+		}
+
+		_, err = h.logger.GetWebPanel().GetSession(token)
+		if err != nil {
+			WriteSessionExpired(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// allowsModel reports whether the ingest token of the request, if any, may write to the model.
+func allowsModel(r *http.Request, model models.Model) bool {
+	ingestToken, ok := r.Context().Value(ingestTokenContextKey{}).(*models.IngestToken)
+	return !ok || ingestToken.AllowsModel(model)
+}
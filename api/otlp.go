@@ -0,0 +1,338 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/internal/otlp"
+	"sadk.dev/logar/models"
+)
+
+const (
+	otlpMaxBodySize = 16 << 20
+
+	// Used when the resource has no service.name, as in the OpenTelemetry SDKs
+	otlpUnknownService = "unknown_service"
+	otlpCategory       = "otlp"
+)
+
+var errUnsupportedContentType = errors.New("unsupported content type, expected " + otlp.ContentTypeProtobuf + " or " + otlp.ContentTypeJSON)
+
+// ReceiveOTLPLogs is an OTLP/HTTP logs receiver. Each log record is stored as a log of the model named
+// by the service.name resource attribute, in the category named by the instrumentation scope.
+func (h *Handler) ReceiveOTLPLogs(w http.ResponseWriter, r *http.Request) {
+	if !h.logger.IsOTLPEnabled() {
+		w.WriteHeader(404)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, "OTLP receivers are not enabled"))
+		return
+	}
+
+	isJSON, body, err := readOTLPRequest(w, r)
+	if err != nil {
+		writeOTLPError(w, isJSON, err)
+		return
+	}
+
+	var req *otlp.ExportLogsServiceRequest
+	if isJSON {
+		req = &otlp.ExportLogsServiceRequest{}
+		err = json.Unmarshal(body, req)
+	} else {
+		req, err = otlp.UnmarshalLogs(body)
+	}
+	if err != nil {
+		writeOTLPError(w, isJSON, err)
+		return
+	}
+
+	logger := h.logger.GetLogger()
+	rejected := 0
+	var lastErr error
+	for _, rl := range req.ResourceLogs {
+		model := otlpServiceName(rl.Resource)
+		for _, sl := range rl.ScopeLogs {
+			for _, record := range sl.LogRecords {
+				entry := otlpLogEntry(model, sl.Scope, record)
+				if entry.Model == logar.LogarLogs {
+					rejected++
+					lastErr = fmt.Errorf("model %q is reserved", entry.Model)
+					continue
+				}
+				if !allowsModel(r, models.Model(entry.Model)) {
+					rejected++
+					lastErr = fmt.Errorf("model %q is not allowed for this token", entry.Model)
+					continue
+				}
+				if err := logger.Write(entry); err != nil {
+					rejected++
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	resp := &otlp.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &otlp.ExportLogsPartialSuccess{
+			RejectedLogRecords: otlp.Int64(rejected),
+			ErrorMessage:       lastErr.Error(),
+		}
+	}
+
+	writeOTLPResponse(w, isJSON, resp, otlp.MarshalLogsResponse(resp))
+}
+
+// ReceiveOTLPTraces is an OTLP/HTTP traces receiver. Spans are stored and shown with the spans recorded by the tracer.
+// Ingest tokens restricted to some models only accept spans of the services named like one of them.
+func (h *Handler) ReceiveOTLPTraces(w http.ResponseWriter, r *http.Request) {
+	if !h.logger.IsOTLPEnabled() {
+		w.WriteHeader(404)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, "OTLP receivers are not enabled"))
+		return
+	}
+
+	isJSON, body, err := readOTLPRequest(w, r)
+	if err != nil {
+		writeOTLPError(w, isJSON, err)
+		return
+	}
+
+	var req *otlp.ExportTraceServiceRequest
+	if isJSON {
+		req = &otlp.ExportTraceServiceRequest{}
+		err = json.Unmarshal(body, req)
+	} else {
+		req, err = otlp.UnmarshalTraces(body)
+	}
+	if err != nil {
+		writeOTLPError(w, isJSON, err)
+		return
+	}
+
+	var spans []models.Span
+	rejected := 0
+	var lastErr error
+	for _, rs := range req.ResourceSpans {
+		service := otlpServiceName(rs.Resource)
+		// Logs of the service are stored in the model named after it, tokens restricted to other models can't send its spans
+		allowed := allowsModel(r, models.Model(service))
+		for _, ss := range rs.ScopeSpans {
+			if !allowed {
+				rejected += len(ss.Spans)
+				lastErr = fmt.Errorf("model %q is not allowed for this token", service)
+				continue
+			}
+			for _, span := range ss.Spans {
+				spans = append(spans, otlpSpan(service, span))
+			}
+		}
+	}
+
+	if err := h.logger.GetTracer().StoreSpans(spans...); err != nil {
+		rejected += len(spans)
+		lastErr = err
+	}
+
+	resp := &otlp.ExportTraceServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &otlp.ExportTracePartialSuccess{
+			RejectedSpans: otlp.Int64(rejected),
+			ErrorMessage:  lastErr.Error(),
+		}
+	}
+
+	writeOTLPResponse(w, isJSON, resp, otlp.MarshalTracesResponse(resp))
+}
+
+// readOTLPRequest reads the possibly gzip compressed body and reports whether it's OTLP/JSON.
+func readOTLPRequest(w http.ResponseWriter, r *http.Request) (isJSON bool, body []byte, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case otlp.ContentTypeJSON:
+		isJSON = true
+	case otlp.ContentTypeProtobuf:
+	default:
+		return false, nil, errUnsupportedContentType
+	}
+
+	reader := io.Reader(http.MaxBytesReader(w, r.Body, otlpMaxBodySize))
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return isJSON, nil, err
+		}
+		defer gz.Close()
+		reader = io.LimitReader(gz, otlpMaxBodySize+1)
+	default:
+		return isJSON, nil, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	body, err = io.ReadAll(reader)
+	if err != nil {
+		return isJSON, nil, err
+	}
+	if len(body) > otlpMaxBodySize {
+		return isJSON, nil, errors.New("request body too large")
+	}
+	return isJSON, body, nil
+}
+
+func writeOTLPResponse(w http.ResponseWriter, isJSON bool, resp any, protoResp []byte) {
+	if isJSON {
+		w.Header().Set("Content-Type", otlp.ContentTypeJSON)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	w.Header().Set("Content-Type", otlp.ContentTypeProtobuf)
+	w.Write(protoResp)
+}
+
+// writeOTLPError responds with a google.rpc.Status message, as the OTLP/HTTP specification requires.
+func writeOTLPError(w http.ResponseWriter, isJSON bool, err error) {
+	status := http.StatusBadRequest
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnsupportedContentType):
+		status = http.StatusUnsupportedMediaType
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	}
+
+	if isJSON {
+		w.Header().Set("Content-Type", otlp.ContentTypeJSON)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{
+			"code":    3, // INVALID_ARGUMENT
+			"message": err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", otlp.ContentTypeProtobuf)
+	w.WriteHeader(status)
+	w.Write(otlp.MarshalStatus(3, err.Error()))
+}
+
+func otlpServiceName(resource otlp.Resource) string {
+	if service, ok := otlp.Lookup(resource.Attributes, "service.name"); ok && service != "" {
+		return service
+	}
+	return otlpUnknownService
+}
+
+func otlpLogEntry(model string, scope otlp.Scope, record otlp.LogRecord) logar.Entry {
+	entry := logar.Entry{
+		Model:    logar.Model(model),
+		Category: otlpCategory,
+		Severity: otlp.Severity(record.SeverityNumber, record.SeverityText),
+		TraceID:  record.TraceID,
+		SpanID:   record.SpanID,
+	}
+	if scope.Name != "" {
+		entry.Category = scope.Name
+	}
+
+	switch {
+	case record.TimeUnixNano != 0:
+		entry.Time = time.Unix(0, int64(record.TimeUnixNano))
+	case record.ObservedTimeUnixNano != 0:
+		entry.Time = time.Unix(0, int64(record.ObservedTimeUnixNano))
+	}
+
+	var body any = ""
+	if record.Body != nil {
+		body = record.Body.Interface()
+	}
+
+	// Attributes that map to log fields are taken out, the rest are stored with the message
+	fields := logar.Map{}
+	var file, line string
+	for _, attr := range record.Attributes {
+		value := attr.Value.Interface()
+		switch attr.Key {
+		// Set by otlplogger, so logs forwarded between logar instances keep their model and category
+		case "logar.model":
+			entry.Model = logar.Model(fmt.Sprint(value))
+		case "logar.category":
+			entry.Category = fmt.Sprint(value)
+		case "logar.fingerprint", "logar.repeat_count":
+		case "code.function":
+			entry.Function = fmt.Sprint(value)
+		case "code.filepath":
+			file = fmt.Sprint(value)
+		case "code.lineno":
+			line = fmt.Sprint(value)
+		case "exception.stacktrace":
+			entry.StackTrace = fmt.Sprint(value)
+		default:
+			fields[attr.Key] = value
+		}
+	}
+	if file != "" {
+		entry.Caller = file
+		if line != "" {
+			entry.Caller += ":" + line
+		}
+	}
+
+	if len(fields) == 0 {
+		entry.Message = body
+	} else {
+		fields["message"] = body
+		entry.Message = fields
+	}
+	return entry
+}
+
+func otlpSpan(service string, span otlp.Span) models.Span {
+	attributes := otlp.AttributeMap(span.Attributes)
+	attributes["service.name"] = service
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		encoded = []byte("{}")
+	}
+
+	start := time.Unix(0, int64(span.StartTimeUnixNano))
+	end := time.Unix(0, int64(span.EndTimeUnixNano))
+
+	result := models.Span{
+		TraceID:    span.TraceID,
+		SpanID:     span.SpanID,
+		ParentID:   span.ParentSpanID,
+		Name:       span.Name,
+		Kind:       models.SpanKind_Internal,
+		Attributes: string(encoded),
+		StartTime:  start,
+		EndTime:    end,
+		Duration:   end.Sub(start),
+		Status:     models.SpanStatus_Unset,
+	}
+
+	switch span.Kind {
+	case otlp.SpanKind_Server, otlp.SpanKind_Consumer:
+		result.Kind = models.SpanKind_Server
+	case otlp.SpanKind_Client, otlp.SpanKind_Producer:
+		result.Kind = models.SpanKind_Client
+	}
+
+	if span.Status != nil {
+		switch span.Status.Code {
+		case otlp.StatusCode_Ok:
+			result.Status = models.SpanStatus_Ok
+		case otlp.StatusCode_Error:
+			result.Status = models.SpanStatus_Error
+			result.StatusMessage = span.Status.Message
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+	"sadk.dev/logar"
+	"sadk.dev/logar/proxy"
+)
+
+func (h *Handler) GetProxies(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("name"); name != "" {
+		info, err := h.logger.GetProxies().GetProxy(name)
+		if err != nil {
+			writeProxyError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, info))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, h.logger.GetProxies().ListProxies()))
+}
+
+// GetProxyMetrics returns the delivery metrics of the proxy with the given name, or of every proxy.
+func (h *Handler) GetProxyMetrics(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("name"); name != "" {
+		metrics, err := h.logger.GetProxies().GetProxyMetrics(name)
+		if err != nil {
+			writeProxyError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, metrics))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, h.logger.GetProxies().GetAllProxyMetrics()))
+}
+
+// CreateProxy adds a proxy from a JSON body with its name, type, filter and settings, as in the config file.
+func (h *Handler) CreateProxy(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	var definition proxy.Definition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid request body"))
+		return
+	}
+
+	info, err := h.logger.GetProxies().CreateProxy(definition)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, info))
+}
+
+// UpdateProxy replaces the type, filter and settings of the proxy with the given name.
+func (h *Handler) UpdateProxy(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'name' in request body"))
+		return
+	}
+
+	var definition proxy.Definition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid request body"))
+		return
+	}
+
+	info, err := h.logger.GetProxies().UpdateProxy(name, definition)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, info))
+}
+
+func (h *Handler) SetProxyEnabled(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'name' in request body"))
+		return
+	}
+
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'enabled' in request body"))
+		return
+	}
+
+	err = h.logger.GetProxies().SetProxyEnabled(name, enabled)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, "Proxy updated"))
+}
+
+func (h *Handler) DeleteProxy(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'name' in request body"))
+		return
+	}
+
+	err := h.logger.GetProxies().RemoveProxy(name)
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, "Proxy removed"))
+}
+
+func (h *Handler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	deadLetters, err := h.logger.GetProxies().GetDeadLetters(r.URL.Query().Get("proxy"), limit)
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, deadLetters))
+}
+
+// ReplayDeadLetters queues the dead letter with the given id again. Without an id, every dead letter
+// of the given proxy, or of every proxy, is replayed.
+func (h *Handler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	deadLetterID := r.FormValue("id")
+	if deadLetterID == "" {
+		replayed, err := h.logger.GetProxies().ReplayDeadLetters(r.FormValue("proxy"))
+		if err != nil {
+			writeProxyError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, map[string]any{"replayed": replayed}))
+		return
+	}
+
+	deadLetterIDUint, err := strconv.ParseUint(deadLetterID, 10, 64)
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'id' in request body"))
+		return
+	}
+
+	err = h.logger.GetProxies().ReplayDeadLetter(uint(deadLetterIDUint))
+	if err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, map[string]any{"replayed": 1}))
+}
+
+func (h *Handler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	deadLetterID := r.URL.Query().Get("id")
+	if deadLetterID == "" {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing 'id' in request body"))
+		return
+	}
+
+	deadLetterIDUint, err := strconv.ParseUint(deadLetterID, 10, 64)
+	if err != nil {
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Invalid 'id' in request body"))
+		return
+	}
+
+	err = h.logger.GetProxies().DeleteDeadLetter(uint(deadLetterIDUint))
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, "Dead letter deleted"))
+}
+
+func writeProxyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, logar.ErrProxyNotFound):
+		w.WriteHeader(404)
+	case errors.Is(err, logar.ErrProxyExists):
+		w.WriteHeader(409)
+	case errors.Is(err, logar.ErrInvalidProxy), errors.Is(err, logar.ErrProxyNotManaged):
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, err.Error()))
+		return
+	case errors.Is(err, logar.ErrProxyQueueFull):
+		w.WriteHeader(503)
+	default:
+		w.WriteHeader(500)
+	}
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/models"
+)
+
+func (h *Handler) GetTraces(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	traces, err := h.logger.GetTracer().GetTraces(limit)
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, traces))
+}
+
+func (h *Handler) GetTrace(w http.ResponseWriter, r *http.Request) {
+	traceID := r.PathValue("traceId")
+
+	tree, err := h.logger.GetTracer().GetTraceTree(traceID)
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+	if len(tree) == 0 {
+		w.WriteHeader(404)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, "Trace not found"))
+		return
+	}
+
+	logs, err := h.logger.GetLogs(logar.NewQuery().WithFilter(models.Filter{
+		Field:    "trace_id",
+		Operator: models.FilterOperator_Equals,
+		Value:    []string{traceID},
+	}))
+	if err != nil {
+		w.WriteHeader(500)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_Error, err.Error()))
+		return
+	}
+
+	start, end := tree[0].StartTime, tree[0].EndTime
+	var walk func(nodes []*logar.SpanNode)
+	walk = func(nodes []*logar.SpanNode) {
+		for _, node := range nodes {
+			if node.StartTime.Before(start) {
+				start = node.StartTime
+			}
+			if node.EndTime.After(end) {
+				end = node.EndTime
+			}
+			walk(node.Children)
+		}
+	}
+	walk(tree)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NewResponse(StatusCode_Success, map[string]any{
+		"trace_id":   traceID,
+		"start_time": start,
+		"duration":   end.Sub(start),
+		"spans":      tree,
+		"logs":       logs,
+	}))
+}
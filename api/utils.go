@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
@@ -22,3 +23,22 @@ func (h *Handler) getSession(r *http.Request) (*models.Session, error) {
 
 	return session, nil
 }
+
+// requireAdmin responds with 401 and returns false unless the request is made by an admin.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	session, err := h.getSession(r)
+	if err != nil {
+		w.WriteHeader(401)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Missing authorization header"))
+		return false
+	}
+
+	user, err := h.logger.GetWebPanel().GetUser(session.UserID)
+	if err != nil || !user.IsAdmin {
+		w.WriteHeader(401)
+		json.NewEncoder(w).Encode(NewResponse(StatusCode_InvalidRequest, "Only admins can do this"))
+		return false
+	}
+
+	return true
+}
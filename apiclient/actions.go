@@ -0,0 +1,50 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"sadk.dev/logar/api"
+)
+
+func (c *Client) ListActions(ctx context.Context) ([]api.ActionDetails, error) {
+	var actions []api.ActionDetails
+	err := c.get(ctx, "/actions", nil, &actions)
+	return actions, err
+}
+
+// InvokeAction calls the action at path. Strings, numbers and booleans are sent as they are,
+// other arguments as JSON. Actions with a single return value return it, others a slice of
+// their return values. An error returned by the action is returned as an *Error.
+func (c *Client) InvokeAction(ctx context.Context, path string, args ...any) (any, error) {
+	req := api.InvokeActionRequest{Path: path, Args: make([]string, len(args))}
+	for i, arg := range args {
+		value, err := formatArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("apiclient: argument %d: %w", i+1, err)
+		}
+		req.Args[i] = value
+	}
+
+	var resp api.InvokeActionResponse
+	err := c.doJSON(ctx, http.MethodPost, "/actions/invoke", nil, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Result, nil
+}
+
+func formatArg(arg any) (string, error) {
+	switch arg := arg.(type) {
+	case string:
+		return arg, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+		return fmt.Sprint(arg), nil
+	}
+
+	data, err := json.Marshal(arg)
+	return string(data), err
+}
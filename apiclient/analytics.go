@@ -0,0 +1,14 @@
+package apiclient
+
+import (
+	"context"
+
+	"sadk.dev/logar"
+)
+
+// GetAnalytics returns the request statistics of the last 30 days.
+func (c *Client) GetAnalytics(ctx context.Context) (logar.AnalyticsSummary, error) {
+	var summary logar.AnalyticsSummary
+	err := c.get(ctx, "/analytics", nil, &summary)
+	return summary, err
+}
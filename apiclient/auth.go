@@ -0,0 +1,55 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"sadk.dev/logar/api"
+	"sadk.dev/logar/models"
+)
+
+// Login creates a session and uses its token for the following requests.
+func (c *Client) Login(ctx context.Context, username, password string) (models.User, error) {
+	var data struct {
+		Token string      `json:"token"`
+		User  models.User `json:"user"`
+	}
+
+	err := c.doForm(ctx, http.MethodPost, "/auth/login", url.Values{
+		"username": {username},
+		"password": {password},
+	}, &data)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.SetToken(data.Token)
+	return data.User, nil
+}
+
+// Logout deletes the session of the client.
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.doForm(ctx, http.MethodPost, "/auth/logout", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	c.SetToken("")
+	return nil
+}
+
+func (c *Client) GetActiveSessions(ctx context.Context) ([]api.SessionData, error) {
+	var sessions []api.SessionData
+	err := c.get(ctx, "/auth/sessions", nil, &sessions)
+	return sessions, err
+}
+
+func (c *Client) RevokeSession(ctx context.Context, token string) error {
+	return c.doForm(ctx, http.MethodPost, "/auth/revoke-session", url.Values{"session_id": {token}}, nil)
+}
+
+// Health reports whether the server and its database are up. It doesn't need a token.
+func (c *Client) Health(ctx context.Context) error {
+	return c.get(ctx, "/health", nil, nil)
+}
@@ -0,0 +1,202 @@
+// Package apiclient is a typed client for the REST API served by api.Handler.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"sadk.dev/logar/api"
+)
+
+// ErrSessionExpired is matched by errors of requests whose token is missing, invalid or expired.
+var ErrSessionExpired = errors.New("apiclient: session expired")
+
+// Error is returned when the API answers with an error status code.
+type Error struct {
+	HTTPStatus int
+	StatusCode api.StatusCode
+	Message    string // data of the response, if it is a string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("apiclient: request failed with status %d (status code %d)", e.HTTPStatus, e.StatusCode)
+	}
+	return fmt.Sprintf("apiclient: %s (status %d, status code %d)", e.Message, e.HTTPStatus, e.StatusCode)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrSessionExpired && e.StatusCode == api.StatusCode_SessionExpired
+}
+
+type Option func(*Client)
+
+// WithToken authenticates requests with a session token, e.g. one returned by an earlier Login.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header to every request, e.g. for a proxy in front of the server.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Add(key, value)
+	}
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New creates a client for the API mounted at baseURL, e.g. "http://localhost:3000/api".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("apiclient: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("apiclient: invalid base URL %q, the scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		headers:    http.Header{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// Token returns the session token used by the client.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
+
+// doForm sends form values the way the web client does.
+func (c *Client) doForm(ctx context.Context, method, path string, form url.Values, out any) error {
+	req, err := c.newRequest(ctx, method, path, nil, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.do(req, out)
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, method, path, query, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req, out)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, out)
+}
+
+// do sends the request and decodes the data of the response envelope into out, if not nil.
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return c.decodeResponse(req, resp, out)
+}
+
+func (c *Client) decodeResponse(req *http.Request, resp *http.Response, out any) error {
+	var envelope struct {
+		StatusCode api.StatusCode  `json:"status_code"`
+		Data       json.RawMessage `json:"data"`
+	}
+
+	// Some handlers, e.g. logout, answer with an empty body
+	err := json.NewDecoder(resp.Body).Decode(&envelope)
+	if err == io.EOF && resp.StatusCode < 300 {
+		return nil
+	}
+	if err != nil {
+		return &Error{HTTPStatus: resp.StatusCode, Message: fmt.Sprintf("invalid response: %v", err)}
+	}
+
+	if resp.StatusCode >= 300 || envelope.StatusCode != api.StatusCode_Success {
+		apiErr := &Error{HTTPStatus: resp.StatusCode, StatusCode: envelope.StatusCode}
+		json.Unmarshal(envelope.Data, &apiErr.Message)
+		return apiErr
+	}
+
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("apiclient: decoding response of %s %s: %w", req.Method, req.URL.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"sadk.dev/logar/models"
+)
+
+func (c *Client) GetFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	var flags []models.FeatureFlag
+	err := c.get(ctx, "/feature-flags", nil, &flags)
+	return flags, err
+}
+
+// CreateFeatureFlag creates the flag and returns it with its ID set.
+func (c *Client) CreateFeatureFlag(ctx context.Context, flag models.FeatureFlag) (models.FeatureFlag, error) {
+	var created models.FeatureFlag
+	err := c.doForm(ctx, http.MethodPost, "/feature-flags", featureFlagForm(flag), &created)
+	return created, err
+}
+
+// UpdateFeatureFlag replaces the name, state and condition of the flag with the given ID.
+func (c *Client) UpdateFeatureFlag(ctx context.Context, flag models.FeatureFlag) (models.FeatureFlag, error) {
+	form := featureFlagForm(flag)
+	form.Set("id", strconv.FormatUint(uint64(flag.ID), 10))
+
+	var updated models.FeatureFlag
+	err := c.doForm(ctx, http.MethodPut, "/feature-flags", form, &updated)
+	return updated, err
+}
+
+func (c *Client) DeleteFeatureFlag(ctx context.Context, id uint) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/feature-flags", url.Values{"id": {strconv.FormatUint(uint64(id), 10)}}, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
+
+func featureFlagForm(flag models.FeatureFlag) url.Values {
+	return url.Values{
+		"name":      {flag.Name},
+		"enabled":   {strconv.FormatBool(flag.Enabled)},
+		"condition": {flag.Condition},
+	}
+}
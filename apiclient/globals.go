@@ -0,0 +1,52 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"sadk.dev/logar/models"
+)
+
+// GetGlobals returns every global. Values are JSON encoded, see GetGlobalValue to decode one.
+func (c *Client) GetGlobals(ctx context.Context) ([]models.Global, error) {
+	var globals []models.Global
+	err := c.get(ctx, "/globals", nil, &globals)
+	return globals, err
+}
+
+// GetGlobalValue decodes the value of the global into out. It returns an error if there is no global with the key.
+func (c *Client) GetGlobalValue(ctx context.Context, key string, out any) error {
+	globals, err := c.GetGlobals(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, global := range globals {
+		if global.Key == key {
+			return json.Unmarshal([]byte(global.Value), out)
+		}
+	}
+	return fmt.Errorf("apiclient: global %q not found", key)
+}
+
+// SetGlobal creates or updates the global. value must be JSON serializable, exported globals are
+// available to feature flag conditions.
+func (c *Client) SetGlobal(ctx context.Context, key string, value any, exported bool) error {
+	body := map[string]any{
+		"value":    value,
+		"exported": exported,
+	}
+	return c.doJSON(ctx, http.MethodPut, "/globals", url.Values{"key": {key}}, body, nil)
+}
+
+func (c *Client) DeleteGlobal(ctx context.Context, key string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/globals", url.Values{"key": {key}}, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
@@ -0,0 +1,136 @@
+package apiclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/models"
+)
+
+// AllModels queries the logs of every model.
+const AllModels logar.Model = "__all__"
+
+// LogQuery narrows down the logs returned by GetLogs and Tail. The zero value matches every log.
+type LogQuery struct {
+	Cursor   uint            // LastID of the previous page, 0 for the first page
+	Severity models.Severity // minimum severity
+	Filters  []models.Filter
+}
+
+func (q LogQuery) values() (url.Values, error) {
+	values := url.Values{}
+	if q.Cursor != 0 {
+		values.Set("cursor", strconv.FormatUint(uint64(q.Cursor), 10))
+	}
+	if q.Severity != 0 {
+		values.Set("severity", strconv.Itoa(int(q.Severity)))
+	}
+	if len(q.Filters) > 0 {
+		filters, err := json.Marshal(q.Filters)
+		if err != nil {
+			return nil, err
+		}
+		values.Set("filters", string(filters))
+	}
+	return values, nil
+}
+
+type LogPage struct {
+	Model  string       `json:"Model"`
+	Logs   []models.Log `json:"Logs"`
+	LastID uint         `json:"LastId"` // cursor of the next page, 0 if this is the last page
+}
+
+func (c *Client) ListModels(ctx context.Context) (logar.LogModels, error) {
+	var logModels logar.LogModels
+	err := c.get(ctx, "/models", nil, &logModels)
+	return logModels, err
+}
+
+// GetLogs returns a page of logs of the model, newest first.
+func (c *Client) GetLogs(ctx context.Context, model logar.Model, query LogQuery) (LogPage, error) {
+	values, err := query.values()
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	var page LogPage
+	err = c.get(ctx, "/logs/"+url.PathEscape(string(modelOrAll(model))), values, &page)
+	return page, err
+}
+
+// Tail streams the logs of the model written after it is called, calling fn with each batch until
+// the context is done, the server closes the stream or fn returns an error. The server must have SSE enabled.
+func (c *Client) Tail(ctx context.Context, model logar.Model, query LogQuery, fn func(LogPage) error) error {
+	values, err := query.values()
+	if err != nil {
+		return err
+	}
+	values.Del("cursor")
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/logs/"+url.PathEscape(string(modelOrAll(model)))+"/sse", values, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		// Errors use the usual response envelope
+		return c.decodeResponse(req, resp, nil)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event string
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			if event == "logs" && data.Len() > 0 {
+				var page LogPage
+				if err := json.Unmarshal(data.Bytes(), &page); err != nil {
+					return fmt.Errorf("apiclient: decoding logs event: %w", err)
+				}
+				if err := fn(page); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data.Reset()
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(line[len("data:"):], []byte(" ")))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+func modelOrAll(model logar.Model) logar.Model {
+	if model == "" {
+		return AllModels
+	}
+	return model
+}
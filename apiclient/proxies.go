@@ -0,0 +1,104 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+func (c *Client) ListProxies(ctx context.Context) ([]logar.ProxyInfo, error) {
+	var proxies []logar.ProxyInfo
+	err := c.get(ctx, "/proxies", nil, &proxies)
+	return proxies, err
+}
+
+func (c *Client) GetProxy(ctx context.Context, name string) (logar.ProxyInfo, error) {
+	var info logar.ProxyInfo
+	err := c.get(ctx, "/proxies", url.Values{"name": {name}}, &info)
+	return info, err
+}
+
+// CreateProxy adds a proxy on the server, which stores it so that it is created again when the server restarts.
+func (c *Client) CreateProxy(ctx context.Context, definition proxy.Definition) (logar.ProxyInfo, error) {
+	var info logar.ProxyInfo
+	err := c.doJSON(ctx, http.MethodPost, "/proxies", nil, definition, &info)
+	return info, err
+}
+
+// UpdateProxy replaces the type, filter and settings of a proxy added at runtime.
+func (c *Client) UpdateProxy(ctx context.Context, name string, definition proxy.Definition) (logar.ProxyInfo, error) {
+	var info logar.ProxyInfo
+	err := c.doJSON(ctx, http.MethodPut, "/proxies", url.Values{"name": {name}}, definition, &info)
+	return info, err
+}
+
+func (c *Client) SetProxyEnabled(ctx context.Context, name string, enabled bool) error {
+	return c.doForm(ctx, http.MethodPost, "/proxies/enabled", url.Values{"name": {name}, "enabled": {strconv.FormatBool(enabled)}}, nil)
+}
+
+func (c *Client) RemoveProxy(ctx context.Context, name string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/proxies", url.Values{"name": {name}}, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
+
+// GetProxyMetrics returns the delivery counters, last error and latency of the proxy.
+func (c *Client) GetProxyMetrics(ctx context.Context, name string) (logar.ProxyMetrics, error) {
+	var metrics logar.ProxyMetrics
+	err := c.get(ctx, "/proxies/metrics", url.Values{"name": {name}}, &metrics)
+	return metrics, err
+}
+
+func (c *Client) GetAllProxyMetrics(ctx context.Context) ([]logar.ProxyMetrics, error) {
+	var metrics []logar.ProxyMetrics
+	err := c.get(ctx, "/proxies/metrics", nil, &metrics)
+	return metrics, err
+}
+
+// GetDeadLetters returns the most recent logs that the proxy failed to deliver, or that every proxy
+// failed to deliver if proxyName is empty. A limit of 0 uses the default of the server.
+func (c *Client) GetDeadLetters(ctx context.Context, proxyName string, limit int) ([]models.DeadLetter, error) {
+	query := url.Values{}
+	if proxyName != "" {
+		query.Set("proxy", proxyName)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var deadLetters []models.DeadLetter
+	err := c.get(ctx, "/proxies/dead-letters", query, &deadLetters)
+	return deadLetters, err
+}
+
+// ReplayDeadLetter queues the log of the dead letter for delivery again.
+func (c *Client) ReplayDeadLetter(ctx context.Context, id uint) error {
+	return c.doForm(ctx, http.MethodPost, "/proxies/dead-letters/replay", url.Values{"id": {strconv.FormatUint(uint64(id), 10)}}, nil)
+}
+
+// ReplayDeadLetters replays the dead letters of the proxy, or of every proxy if proxyName is empty,
+// and returns how many were queued. Dead letters of proxies whose queue is full are kept.
+func (c *Client) ReplayDeadLetters(ctx context.Context, proxyName string) (int, error) {
+	var data struct {
+		Replayed int `json:"replayed"`
+	}
+	err := c.doForm(ctx, http.MethodPost, "/proxies/dead-letters/replay", url.Values{"proxy": {proxyName}}, &data)
+	return data.Replayed, err
+}
+
+func (c *Client) DeleteDeadLetter(ctx context.Context, id uint) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/proxies/dead-letters", url.Values{"id": {strconv.FormatUint(uint64(id), 10)}}, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
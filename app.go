@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"reflect"
+	"sync"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -25,6 +26,9 @@ type App interface {
 	GetWebPanel() WebPanel
 	GetAnalytics() Analytics
 	GetFeatureFlags() FeatureFlags
+	GetIssues() Issues
+	GetTracer() Tracer
+	GetProxies() Proxies
 
 	Close() error
 	GetAllModels() LogModels
@@ -39,6 +43,11 @@ type App interface {
 	AddContextValue(ctx context.Context, key string, value any) App
 
 	IsSSEEnabled() bool
+	IsIngestEnabled() bool
+	IsOTLPEnabled() bool
+	GetIngestLimits() IngestLimits
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
 }
 
 type AppImpl struct {
@@ -47,18 +56,32 @@ type AppImpl struct {
 	webPanel      WebPanel
 	analytics     Analytics
 	featureFlags  FeatureFlags
+	issues        *IssuesImpl
+	tracer        *TracerImpl
+	proxies       *ProxiesImpl
 
 	db        *gorm.DB
 	config    Config
-	proxies   []proxy.Proxy
 	actions   Actions
 	typeKinds map[string]TypeKind
+	sampler   *sampler
+	dedup     *deduplicator
+
+	done      chan struct{}
+	workers   sync.WaitGroup
+	closeOnce sync.Once
 }
 
 var defaultWebPanelConfig = WebPanelConfig{
 	SessionDuration: time.Hour * 24 * 7,
 }
 
+var defaultIngestLimits = IngestLimits{
+	MaxBodySize:    5 << 20,
+	MaxEntries:     1000,
+	MaxMessageSize: 64 << 10,
+}
+
 func New(opts ...ConfigOpt) (App, error) {
 	cfg := Config{
 		AppName:         "logger",
@@ -72,6 +95,12 @@ func New(opts ...ConfigOpt) (App, error) {
 		WebPanelConfig:  defaultWebPanelConfig,
 		SSEEnabled:      true,
 		MainFilter:      logfilter.NewFilter(),
+		IssueTracking:   true,
+		IngestEnabled:   true,
+		OTLPEnabled:     true,
+		IngestLimits:    defaultIngestLimits,
+
+		ProxyDeliveryConfig: defaultProxyDeliveryConfig,
 	}
 
 	for _, opt := range opts {
@@ -104,6 +133,11 @@ func New(opts ...ConfigOpt) (App, error) {
 		&models.RequestLog{},
 		&models.FeatureFlag{},
 		&models.Global{},
+		&models.Issue{},
+		&models.Span{},
+		&models.IngestToken{},
+		&models.DeadLetter{},
+		&models.ProxyDefinition{},
 	)
 	if err != nil {
 		return nil, err
@@ -118,9 +152,10 @@ func New(opts ...ConfigOpt) (App, error) {
 	logger := &AppImpl{
 		db:        db,
 		config:    cfg,
-		proxies:   cfg.Proxies,
 		actions:   cfg.Actions,
 		typeKinds: map[string]TypeKind{},
+		sampler:   newSampler(cfg.SamplingConfig.Filter),
+		done:      make(chan struct{}),
 	}
 
 	logger.logger = &LoggerImpl{core: logger}
@@ -128,6 +163,12 @@ func New(opts ...ConfigOpt) (App, error) {
 	logger.webPanel = &WebPanelImpl{core: logger}
 	logger.analytics = &AnalyticsImpl{core: logger}
 	logger.featureFlags = &FeatureFlagsImpl{core: logger}
+	logger.issues = &IssuesImpl{core: logger}
+	logger.tracer = &TracerImpl{core: logger, exporters: cfg.SpanExporters}
+	logger.proxies, err = newProxies(logger, cfg.Proxies)
+	if err != nil {
+		return nil, err
+	}
 
 	// Default type kinds
 	logger.SetTypeKind(reflect.TypeOf(string("")), TypeKind_Text)
@@ -146,10 +187,36 @@ func New(opts ...ConfigOpt) (App, error) {
 	logger.SetTypeKind(reflect.TypeOf(bool(false)), TypeKind_Bool)
 	logger.SetTypeKind(reflect.TypeOf(time.Time{}), TypeKind_Time)
 	logger.SetTypeKind(reflect.TypeOf(time.Duration(0)), TypeKind_Duration)
+
+	if cfg.DedupWindow > 0 {
+		logger.dedup = newDeduplicator(cfg.DedupWindow)
+		logger.runDedupPruner()
+	}
+	if cfg.SamplingConfig.SummaryInterval > 0 {
+		logger.runSamplingSummary(cfg.SamplingConfig.SummaryInterval)
+	}
+	if cfg.RetentionConfig.enabled() {
+		logger.runRetention()
+	}
+	logger.proxies.start()
 	return logger, nil
 }
 
+// runWorker runs fn in a goroutine. done is closed when the app is closed, and Close waits for fn to return.
+func (l *AppImpl) runWorker(fn func(done <-chan struct{})) {
+	l.workers.Add(1)
+	go func() {
+		defer l.workers.Done()
+		fn(l.done)
+	}()
+}
+
 func (l *AppImpl) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	l.workers.Wait()
+
 	sqlDB, err := l.db.DB()
 	if err != nil {
 		return err
@@ -200,15 +267,20 @@ func (l *AppImpl) GetFeatureFlags() FeatureFlags {
 	return l.featureFlags
 }
 
+func (l *AppImpl) GetIssues() Issues {
+	return l.issues
+}
+
+func (l *AppImpl) GetTracer() Tracer {
+	return l.tracer
+}
+
+func (l *AppImpl) GetProxies() Proxies {
+	return l.proxies
+}
+
 func (l *AppImpl) PrepareContext(parent context.Context, values Map) context.Context {
-	if parent == nil {
-		parent = context.Background()
-	}
-	value := Map{}
-	for k, v := range values {
-		value[k] = v
-	}
-	return context.WithValue(parent, logarContextKey, &value)
+	return PrepareContext(parent, values)
 }
 
 func (l *AppImpl) AddContextValue(ctx context.Context, key string, value any) App {
@@ -224,6 +296,24 @@ func (l *AppImpl) AddContextValue(ctx context.Context, key string, value any) Ap
 }
 
 func (l *AppImpl) GetContextValues(ctx context.Context) (Map, bool) {
+	return GetContextValues(ctx)
+}
+
+// PrepareContext returns a context that carries values added to the message of every log written
+// with it. It's App.PrepareContext for loggers without an App, such as remote clients.
+func PrepareContext(parent context.Context, values Map) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	value := Map{}
+	for k, v := range values {
+		value[k] = v
+	}
+	return context.WithValue(parent, logarContextKey, &value)
+}
+
+// GetContextValues returns the values of a context created by PrepareContext.
+func GetContextValues(ctx context.Context) (Map, bool) {
 	if ctx == nil {
 		return nil, false
 	}
@@ -345,3 +435,23 @@ func (l *AppImpl) GetGlobalBool(key string) (bool, error) {
 func (l *AppImpl) IsSSEEnabled() bool {
 	return l.config.SSEEnabled
 }
+
+func (l *AppImpl) IsIngestEnabled() bool {
+	return l.config.IngestEnabled
+}
+
+func (l *AppImpl) IsOTLPEnabled() bool {
+	return l.config.OTLPEnabled
+}
+
+func (l *AppImpl) Ping(ctx context.Context) error {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (l *AppImpl) GetIngestLimits() IngestLimits {
+	return l.config.IngestLimits
+}
@@ -0,0 +1,66 @@
+package logar
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Frames from these packages never count as the caller of a log.
+var skippedCallerPackages = []string{
+	"sadk.dev/logar.",
+	"sadk.dev/logar/gormlogger.",
+	"sadk.dev/logar/remotelogger.",
+	"gorm.io/",
+	"runtime.",
+}
+
+func isSkippedFrame(frame runtime.Frame) bool {
+	for _, pkg := range skippedCallerPackages {
+		if strings.HasPrefix(frame.Function, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerFrame returns the first frame outside of logar, skipping additional `skip` frames after it.
+func callerFrame(skip int) (runtime.Frame, bool) {
+	pcs := [32]uintptr{}
+	// skip runtime.Callers and callerFrame
+	len := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:len])
+	for {
+		frame, more := frames.Next()
+		if !isSkippedFrame(frame) {
+			if skip <= 0 {
+				return frame, true
+			}
+			skip--
+		}
+		if !more {
+			break
+		}
+	}
+
+	return runtime.Frame{}, false
+}
+
+func formatCaller(frame runtime.Frame) string {
+	return string(strconv.AppendInt(append([]byte(frame.File), ':'), int64(frame.Line), 10))
+}
+
+// Caller returns the file:line and function of the first frame outside of logar, skipping additional
+// `skip` frames after it. It's the caller of WithCaller, for loggers without an App, such as remote clients.
+func Caller(skip int) (string, string) {
+	frame, ok := callerFrame(skip)
+	if !ok {
+		return "", ""
+	}
+	return formatCaller(frame), frame.Function
+}
+
+func currentStackTrace() string {
+	return string(debug.Stack())
+}
@@ -6,6 +6,7 @@ import (
 
 	"gorm.io/gorm"
 	"sadk.dev/logar/logfilter"
+	"sadk.dev/logar/models"
 	"sadk.dev/logar/proxy"
 )
 
@@ -23,6 +24,17 @@ type Config struct {
 	DefaultLanguage Language
 	WebPanelConfig  WebPanelConfig
 	SSEEnabled      bool
+	CallerConfig    CallerConfig
+	IssueTracking   bool
+	SamplingConfig  SamplingConfig
+	DedupWindow     time.Duration
+	SpanExporters   []SpanExporter
+	IngestEnabled   bool
+	OTLPEnabled     bool
+	IngestLimits    IngestLimits
+	RetentionConfig RetentionConfig
+
+	ProxyDeliveryConfig ProxyDeliveryConfig
 }
 
 type LogModel struct {
@@ -49,6 +61,30 @@ type WebPanelConfig struct {
 
 type WebPanelConfigOpt func(*WebPanelConfig)
 
+// SamplingConfig controls which logs are dropped before they reach MainFilter.
+type SamplingConfig struct {
+	Filter logfilter.Filter
+
+	// Interval of the LogarLogs summary of how many logs were suppressed. Zero disables the summary.
+	SummaryInterval time.Duration
+}
+
+// IngestLimits bounds requests of remote producers to the ingest endpoints.
+type IngestLimits struct {
+	MaxBodySize    int64 // bytes of a request body, default 5 MiB
+	MaxEntries     int   // entries in a single request, default 1000
+	MaxMessageSize int   // bytes of a single encoded message, default 64 KiB
+}
+
+// CallerConfig controls which source information is captured on log entries.
+type CallerConfig struct {
+	Enabled bool // capture file:line and function of the caller
+	Skip    int  // number of additional caller frames to skip, for logging helpers wrapping logar
+
+	// Minimum severity that captures the goroutine stack trace. Severity_None disables stack traces.
+	StackTraceSeverity models.Severity
+}
+
 func WithSessionDuration(duration time.Duration) WebPanelConfigOpt {
 	return func(cfg *WebPanelConfig) {
 		cfg.SessionDuration = duration
@@ -146,6 +182,86 @@ func WithSSEEnabled(enabled bool) ConfigOpt {
 	}
 }
 
+// WithCaller enables capturing the file:line and function that produced each log.
+// Frames inside logar are skipped automatically, skip can be used to skip additional
+// frames of logging helpers.
+func WithCaller(skip int) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.CallerConfig.Enabled = true
+		cfg.CallerConfig.Skip = skip
+	}
+}
+
+// WithStackTrace enables capturing the goroutine stack trace for logs with at least the given severity.
+func WithStackTrace(minSeverity models.Severity) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.CallerConfig.StackTraceSeverity = minSeverity
+	}
+}
+
+// WithSampling drops logs that don't match the given conditions before they are filtered and stored,
+// see logfilter.Sample, logfilter.RateLimit and logfilter.FirstThenEvery.
+// Every summaryInterval, the number of suppressed logs per model and category is logged to LogarLogs.
+func WithSampling(summaryInterval time.Duration, conditions ...logfilter.Condition) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.SamplingConfig = SamplingConfig{
+			Filter:          logfilter.NewFilter(conditions...),
+			SummaryInterval: summaryInterval,
+		}
+	}
+}
+
+// WithDeduplication merges identical logs (same model, category, severity and message) within the window
+// into a single stored log with a repeat count. Duplicates are not sent to proxies.
+func WithDeduplication(window time.Duration) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.DedupWindow = window
+	}
+}
+
+// WithIssueTracking enables or disables grouping Error and Fatal logs into issues. Enabled by default.
+func WithIssueTracking(enabled bool) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.IssueTracking = enabled
+	}
+}
+
+// WithSpanExporter sends every finished span to the exporter, in addition to storing it.
+func WithSpanExporter(exporter SpanExporter) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.SpanExporters = append(cfg.SpanExporters, exporter)
+	}
+}
+
+// WithIngestEnabled enables or disables the POST /ingest endpoint of the API. Enabled by default.
+func WithIngestEnabled(enabled bool) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.IngestEnabled = enabled
+	}
+}
+
+// WithOTLPEnabled enables or disables the OTLP/HTTP receivers of the API. Enabled by default.
+func WithOTLPEnabled(enabled bool) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.OTLPEnabled = enabled
+	}
+}
+
+// WithIngestLimits sets the limits of the ingest endpoints. Zero values keep the defaults.
+func WithIngestLimits(limits IngestLimits) ConfigOpt {
+	return func(cfg *Config) {
+		if limits.MaxBodySize > 0 {
+			cfg.IngestLimits.MaxBodySize = limits.MaxBodySize
+		}
+		if limits.MaxEntries > 0 {
+			cfg.IngestLimits.MaxEntries = limits.MaxEntries
+		}
+		if limits.MaxMessageSize > 0 {
+			cfg.IngestLimits.MaxMessageSize = limits.MaxMessageSize
+		}
+	}
+}
+
 func Combine(opts ...ConfigOpt) ConfigOpt {
 	return func(cfg *Config) {
 		for _, opt := range opts {
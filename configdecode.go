@@ -0,0 +1,354 @@
+package logar
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/internal/configformat"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeConfig decodes the parsed values of a config file into v, a pointer to a struct.
+func decodeConfig(values map[string]any, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return errors.New("config: target must be a pointer to a struct")
+	}
+	return decodeConfigValue("", values, target.Elem())
+}
+
+func joinConfigKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// configFields maps the keys of a struct to the index of their field. Fields of embedded
+// structs without a json name are promoted, as with encoding/json.
+func configFields(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for key, index := range configFields(field.Type) {
+				if _, ok := fields[key]; !ok {
+					fields[key] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = []int{i}
+	}
+	return fields
+}
+
+func decodeConfigValue(key string, value any, target reflect.Value) error {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	if target.Type() == durationType {
+		return decodeConfigDuration(key, value, target)
+	}
+
+	if s, ok := value.(string); ok && target.Kind() != reflect.String && target.Kind() != reflect.Interface &&
+		reflect.PointerTo(target.Type()).Implements(textUnmarshalerType) {
+		if err := target.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return &ConfigError{Key: key, Err: err}
+		}
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(target.Type().Elem())
+		if err := decodeConfigValue(key, value, elem.Elem()); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+
+	case reflect.Interface:
+		target.Set(reflect.ValueOf(value))
+		return nil
+
+	case reflect.Struct:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return configTypeError(key, "a mapping", value)
+		}
+		fields := configFields(target.Type())
+		for _, name := range sortedKeys(values) {
+			index, ok := fields[name]
+			if !ok {
+				return &ConfigError{Key: joinConfigKey(key, name), Err: errors.New("unknown key")}
+			}
+			if err := decodeConfigValue(joinConfigKey(key, name), values[name], target.FieldByIndex(index)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		values, ok := value.(map[string]any)
+		if !ok {
+			return configTypeError(key, "a mapping", value)
+		}
+		if target.Type().Key().Kind() != reflect.String {
+			return &ConfigError{Key: key, Err: fmt.Errorf("unsupported map type %s", target.Type())}
+		}
+		result := reflect.MakeMap(target.Type())
+		for _, name := range sortedKeys(values) {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := decodeConfigValue(joinConfigKey(key, name), values[name], elem); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(name).Convert(target.Type().Key()), elem)
+		}
+		target.Set(result)
+		return nil
+
+	case reflect.Slice:
+		values, ok := value.([]any)
+		if !ok {
+			return configTypeError(key, "a list", value)
+		}
+		slice := reflect.MakeSlice(target.Type(), len(values), len(values))
+		for i, item := range values {
+			if err := decodeConfigValue(fmt.Sprintf("%s[%d]", key, i), item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+		return nil
+
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			target.SetString(v)
+		case int64, float64, bool:
+			target.SetString(fmt.Sprint(v))
+		default:
+			return configTypeError(key, "a string", value)
+		}
+		return nil
+
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			target.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return &ConfigError{Key: key, Err: fmt.Errorf("expected true or false, got %q", v)}
+			}
+			target.SetBool(b)
+		default:
+			return configTypeError(key, "true or false", value)
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch v := value.(type) {
+		case int64:
+			i = v
+		case string:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return &ConfigError{Key: key, Err: fmt.Errorf("expected an integer, got %q", v)}
+			}
+			i = parsed
+		default:
+			return configTypeError(key, "an integer", value)
+		}
+		if target.OverflowInt(i) {
+			return &ConfigError{Key: key, Err: fmt.Errorf("%d is out of range", i)}
+		}
+		target.SetInt(i)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case int64:
+			target.SetFloat(float64(v))
+		case float64:
+			target.SetFloat(v)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return &ConfigError{Key: key, Err: fmt.Errorf("expected a number, got %q", v)}
+			}
+			target.SetFloat(f)
+		default:
+			return configTypeError(key, "a number", value)
+		}
+		return nil
+	}
+
+	return &ConfigError{Key: key, Err: fmt.Errorf("unsupported type %s", target.Type())}
+}
+
+// decodeConfigDuration accepts Go durations such as "1h30m", and days such as "30d".
+func decodeConfigDuration(key string, value any, target reflect.Value) error {
+	s, ok := value.(string)
+	if !ok {
+		return configTypeError(key, `a duration such as "24h"`, value)
+	}
+
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			target.SetInt(int64(time.Duration(n) * 24 * time.Hour))
+			return nil
+		}
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return &ConfigError{Key: key, Err: fmt.Errorf(`invalid duration %q, expected a value such as "90s", "24h" or "30d"`, s)}
+	}
+	target.SetInt(int64(duration))
+	return nil
+}
+
+func configTypeError(key, expected string, value any) error {
+	var got string
+	switch value.(type) {
+	case map[string]any:
+		got = "a mapping"
+	case []any:
+		got = "a list"
+	case string:
+		got = fmt.Sprintf("%q", value)
+	default:
+		got = fmt.Sprint(value)
+	}
+	return &ConfigError{Key: key, Err: fmt.Errorf("expected %s, got %s", expected, got)}
+}
+
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeConfigEnv decodes the LOGAR_* variables of environ into v. Lists and mappings are written as JSON,
+// or as comma separated values: "a,b" for lists and "key=value,key2=value2" for mappings.
+func decodeConfigEnv(environ []string, v any) error {
+	env := map[string]string{}
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, EnvPrefix+"_") {
+			env[name] = value
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return errors.New("config: target must be a pointer to a struct")
+	}
+	return decodeConfigEnvStruct(env, EnvPrefix, target.Elem())
+}
+
+func decodeConfigEnvStruct(env map[string]string, prefix string, target reflect.Value) error {
+	fields := configFields(target.Type())
+	for _, name := range sortedFieldNames(fields) {
+		field := target.FieldByIndex(fields[name])
+		envName := prefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			if err := decodeConfigEnvStruct(env, envName, field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := env[envName]
+		if !ok {
+			continue
+		}
+		value, err := parseConfigEnvValue(raw, field.Type())
+		if err != nil {
+			return &ConfigError{Key: envName, Err: err}
+		}
+		if err := decodeConfigValue(envName, value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedFieldNames(fields map[string][]int) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseConfigEnvValue converts the value of an environment variable to the values of a config file.
+func parseConfigEnvValue(raw string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return raw, nil
+	}
+
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "[") || strings.HasPrefix(raw, "{") {
+		values, err := configformat.ParseJSON([]byte(`{"value":` + raw + `}`))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return values["value"], nil
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	if t.Kind() == reflect.Slice {
+		items := []any{}
+		for _, item := range strings.Split(raw, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+		return items, nil
+	}
+
+	values := map[string]any{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key=value pairs separated by commas, got %q", pair)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,372 @@
+package logar
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"sadk.dev/logar/internal/configformat"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+// EnvPrefix is the prefix of the environment variables read by LoadConfig.
+// Keys map to variables by joining their path with underscores, e.g. admin.username is LOGAR_ADMIN_USERNAME.
+const EnvPrefix = "LOGAR"
+
+// ConfigError is an invalid value in a config file or environment variable.
+type ConfigError struct {
+	Key string // path of the key, such as "proxies[1].settings", or the environment variable
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return "config: " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigFile is the configuration read by LoadConfig. Unset fields keep the defaults of New.
+type ConfigFile struct {
+	AppName string `json:"app_name"`
+
+	Admin struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"admin"`
+
+	Database struct {
+		Driver string `json:"driver"` // defaults to "sqlite", see RegisterDatabaseDriver
+		DSN    string `json:"dsn"`
+	} `json:"database"`
+
+	Models          []ModelDefinition `json:"models"`
+	DefaultLanguage Language          `json:"default_language"`
+	SessionDuration time.Duration     `json:"session_duration"`
+	SSEEnabled      *bool             `json:"sse_enabled"`
+	IssueTracking   *bool             `json:"issue_tracking"`
+	DedupWindow     time.Duration     `json:"dedup_window"`
+
+	Caller struct {
+		Enabled            bool   `json:"enabled"`
+		Skip               int    `json:"skip"`
+		StackTraceSeverity string `json:"stack_trace_severity"` // severity name, such as "error"
+	} `json:"caller"`
+
+	Ingest struct {
+		Enabled        *bool `json:"enabled"`
+		MaxBodySize    int64 `json:"max_body_size"`
+		MaxEntries     int   `json:"max_entries"`
+		MaxMessageSize int   `json:"max_message_size"`
+	} `json:"ingest"`
+
+	OTLP struct {
+		Enabled *bool `json:"enabled"`
+	} `json:"otlp"`
+
+	Retention struct {
+		MaxAge   time.Duration           `json:"max_age"`
+		Interval time.Duration           `json:"interval"`
+		Models   map[Model]time.Duration `json:"models"`
+	} `json:"retention"`
+
+	Proxies []proxy.Definition `json:"proxies"`
+
+	ProxyDelivery struct {
+		QueueSize      int           `json:"queue_size"`
+		MaxAttempts    int           `json:"max_attempts"`
+		InitialBackoff time.Duration `json:"initial_backoff"`
+		MaxBackoff     time.Duration `json:"max_backoff"`
+		UnhealthyAfter int           `json:"unhealthy_after"`
+	} `json:"proxy_delivery"`
+}
+
+// ModelDefinition is a model declared in configuration. In environment variables,
+// models are written as "id", "id:Display Name" or "id:Display Name:icon", separated by commas.
+type ModelDefinition struct {
+	ID   Model  `json:"id"`
+	Name string `json:"name"` // defaults to the id
+	Icon string `json:"icon"`
+}
+
+func (m *ModelDefinition) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), ":", 3)
+	m.ID = Model(strings.TrimSpace(parts[0]))
+	if len(parts) > 1 {
+		m.Name = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		m.Icon = strings.TrimSpace(parts[2])
+	}
+	return nil
+}
+
+// DialectorFunc opens a database from its DSN.
+type DialectorFunc func(dsn string) gorm.Dialector
+
+var (
+	databaseDriversMu sync.RWMutex
+	databaseDrivers   = map[string]DialectorFunc{
+		"sqlite": sqlite.Open,
+	}
+)
+
+// RegisterDatabaseDriver makes a gorm driver available to the database.driver key of config files.
+// "sqlite" is registered by default.
+//
+//	logar.RegisterDatabaseDriver("postgres", postgres.Open)
+func RegisterDatabaseDriver(driver string, open DialectorFunc) {
+	databaseDriversMu.Lock()
+	defer databaseDriversMu.Unlock()
+	databaseDrivers[driver] = open
+}
+
+func databaseDriver(driver string) (DialectorFunc, bool) {
+	databaseDriversMu.RLock()
+	defer databaseDriversMu.RUnlock()
+	open, ok := databaseDrivers[driver]
+	return open, ok
+}
+
+// LoadConfig reads a YAML, JSON or TOML config file and the LOGAR_* environment variables, which take
+// precedence, and returns the options to pass to New. If path is empty, the file named by LOGAR_CONFIG
+// is read, if any. Proxy types are the targets registered with proxy.RegisterTarget, so the packages
+// of the targets must be imported.
+//
+//	opts, err := logar.LoadConfig("logar.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	app, err := logar.New(opts...)
+func LoadConfig(path string) ([]ConfigOpt, error) {
+	var file ConfigFile
+	if err := LoadConfigFile(path, &file); err != nil {
+		return nil, err
+	}
+	return file.Options()
+}
+
+// LoadConfigFile decodes a config file and the LOGAR_* environment variables into v, a pointer to a struct
+// with json tags. Embedding ConfigFile allows applications to add their own keys next to the ones of logar.
+// Unknown keys are an error.
+func LoadConfigFile(path string, v any) error {
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "_CONFIG")
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		values, err := configformat.Parse(path, data)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		if err := decodeConfig(values, v); err != nil {
+			return err
+		}
+	}
+
+	return decodeConfigEnv(os.Environ(), v)
+}
+
+// Options validates the config and returns the options to pass to New.
+func (c *ConfigFile) Options() ([]ConfigOpt, error) {
+	var opts []ConfigOpt
+
+	if c.AppName != "" {
+		opts = append(opts, WithAppName(c.AppName))
+	}
+
+	if c.Admin.Username != "" || c.Admin.Password != "" {
+		if c.Admin.Username == "" {
+			return nil, &ConfigError{Key: "admin.username", Err: errors.New("required when admin.password is set")}
+		}
+		if c.Admin.Password == "" {
+			return nil, &ConfigError{Key: "admin.password", Err: errors.New("required when admin.username is set")}
+		}
+		opts = append(opts, WithAdminCredentials(c.Admin.Username, c.Admin.Password))
+	}
+
+	if c.Database.DSN != "" {
+		driver := c.Database.Driver
+		if driver == "" {
+			driver = "sqlite"
+		}
+		open, ok := databaseDriver(driver)
+		if !ok {
+			return nil, &ConfigError{Key: "database.driver", Err: fmt.Errorf("unknown driver %q, it must be registered with RegisterDatabaseDriver", driver)}
+		}
+		opts = append(opts, WithDatabase(open(c.Database.DSN)))
+	} else if c.Database.Driver != "" {
+		return nil, &ConfigError{Key: "database.dsn", Err: errors.New("required when database.driver is set")}
+	}
+
+	seen := map[Model]bool{}
+	for i, model := range c.Models {
+		if model.ID == "" {
+			return nil, &ConfigError{Key: fmt.Sprintf("models[%d].id", i), Err: errors.New("required")}
+		}
+		if model.ID == LogarLogs {
+			return nil, &ConfigError{Key: fmt.Sprintf("models[%d].id", i), Err: fmt.Errorf("%q is reserved", model.ID)}
+		}
+		if seen[model.ID] {
+			return nil, &ConfigError{Key: fmt.Sprintf("models[%d].id", i), Err: fmt.Errorf("duplicate model %q", model.ID)}
+		}
+		seen[model.ID] = true
+
+		name := model.Name
+		if name == "" {
+			name = string(model.ID)
+		}
+		if model.Icon != "" {
+			opts = append(opts, AddModel(name, model.ID, model.Icon))
+		} else {
+			opts = append(opts, AddModel(name, model.ID))
+		}
+	}
+
+	if c.DefaultLanguage != "" {
+		if !slices.Contains([]Language{English, Chinese, Russian, Turkish, Kazakh, Azerbaijani}, c.DefaultLanguage) {
+			return nil, &ConfigError{Key: "default_language", Err: fmt.Errorf("unsupported language %q", c.DefaultLanguage)}
+		}
+		opts = append(opts, WithDefaultLanguage(c.DefaultLanguage))
+	}
+
+	if c.SessionDuration < 0 {
+		return nil, &ConfigError{Key: "session_duration", Err: errors.New("must not be negative")}
+	}
+	if c.SessionDuration > 0 {
+		opts = append(opts, WithWebPanelConfig(WithSessionDuration(c.SessionDuration)))
+	}
+
+	if c.SSEEnabled != nil {
+		opts = append(opts, WithSSEEnabled(*c.SSEEnabled))
+	}
+	if c.IssueTracking != nil {
+		opts = append(opts, WithIssueTracking(*c.IssueTracking))
+	}
+
+	if c.DedupWindow < 0 {
+		return nil, &ConfigError{Key: "dedup_window", Err: errors.New("must not be negative")}
+	}
+	if c.DedupWindow > 0 {
+		opts = append(opts, WithDeduplication(c.DedupWindow))
+	}
+
+	if c.Caller.Skip < 0 {
+		return nil, &ConfigError{Key: "caller.skip", Err: errors.New("must not be negative")}
+	}
+	if c.Caller.Enabled {
+		opts = append(opts, WithCaller(c.Caller.Skip))
+	}
+	if c.Caller.StackTraceSeverity != "" {
+		severity, ok := models.ParseSeverity(c.Caller.StackTraceSeverity)
+		if !ok {
+			return nil, &ConfigError{Key: "caller.stack_trace_severity", Err: fmt.Errorf("invalid severity %q", c.Caller.StackTraceSeverity)}
+		}
+		opts = append(opts, WithStackTrace(severity))
+	}
+
+	if c.Ingest.Enabled != nil {
+		opts = append(opts, WithIngestEnabled(*c.Ingest.Enabled))
+	}
+	for key, value := range map[string]int64{
+		"ingest.max_body_size":    c.Ingest.MaxBodySize,
+		"ingest.max_entries":      int64(c.Ingest.MaxEntries),
+		"ingest.max_message_size": int64(c.Ingest.MaxMessageSize),
+	} {
+		if value < 0 {
+			return nil, &ConfigError{Key: key, Err: errors.New("must not be negative")}
+		}
+	}
+	opts = append(opts, WithIngestLimits(IngestLimits{
+		MaxBodySize:    c.Ingest.MaxBodySize,
+		MaxEntries:     c.Ingest.MaxEntries,
+		MaxMessageSize: c.Ingest.MaxMessageSize,
+	}))
+
+	if c.OTLP.Enabled != nil {
+		opts = append(opts, WithOTLPEnabled(*c.OTLP.Enabled))
+	}
+
+	if c.Retention.MaxAge < 0 {
+		return nil, &ConfigError{Key: "retention.max_age", Err: errors.New("must not be negative")}
+	}
+	if c.Retention.Interval < 0 {
+		return nil, &ConfigError{Key: "retention.interval", Err: errors.New("must not be negative")}
+	}
+	if c.Retention.MaxAge > 0 {
+		opts = append(opts, WithRetention(c.Retention.MaxAge))
+	}
+	for model, maxAge := range c.Retention.Models {
+		if maxAge < 0 {
+			return nil, &ConfigError{Key: "retention.models." + string(model), Err: errors.New("must not be negative")}
+		}
+		opts = append(opts, WithModelRetention(model, maxAge))
+	}
+	if c.Retention.Interval > 0 {
+		opts = append(opts, WithRetentionInterval(c.Retention.Interval))
+	}
+
+	for i, definition := range c.Proxies {
+		p, err := buildProxyDefinition(definition)
+		if err != nil {
+			err.Key = fmt.Sprintf("proxies[%d].%s", i, err.Key)
+			return nil, err
+		}
+		opts = append(opts, AddProxy(p))
+	}
+
+	for key, value := range map[string]int64{
+		"proxy_delivery.queue_size":      int64(c.ProxyDelivery.QueueSize),
+		"proxy_delivery.max_attempts":    int64(c.ProxyDelivery.MaxAttempts),
+		"proxy_delivery.initial_backoff": int64(c.ProxyDelivery.InitialBackoff),
+		"proxy_delivery.max_backoff":     int64(c.ProxyDelivery.MaxBackoff),
+		"proxy_delivery.unhealthy_after": int64(c.ProxyDelivery.UnhealthyAfter),
+	} {
+		if value < 0 {
+			return nil, &ConfigError{Key: key, Err: errors.New("must not be negative")}
+		}
+	}
+	opts = append(opts, WithProxyDelivery(ProxyDeliveryConfig{
+		QueueSize:      c.ProxyDelivery.QueueSize,
+		MaxAttempts:    c.ProxyDelivery.MaxAttempts,
+		InitialBackoff: c.ProxyDelivery.InitialBackoff,
+		MaxBackoff:     c.ProxyDelivery.MaxBackoff,
+		UnhealthyAfter: c.ProxyDelivery.UnhealthyAfter,
+	}))
+
+	return opts, nil
+}
+
+// buildProxyDefinition builds the proxy, reporting which key of the definition is invalid.
+func buildProxyDefinition(definition proxy.Definition) (proxy.Proxy, *ConfigError) {
+	if definition.Type == "" {
+		return proxy.Proxy{}, &ConfigError{Key: "type", Err: fmt.Errorf("required, one of %s", strings.Join(proxy.TargetTypes(), ", "))}
+	}
+	if !slices.Contains(proxy.TargetTypes(), definition.Type) {
+		return proxy.Proxy{}, &ConfigError{Key: "type", Err: fmt.Errorf("unknown proxy type %q, one of %s. The package of the target must be imported", definition.Type, strings.Join(proxy.TargetTypes(), ", "))}
+	}
+
+	filter, err := definition.Filter.Filter()
+	if err != nil {
+		return proxy.Proxy{}, &ConfigError{Key: "filter", Err: err}
+	}
+
+	target, err := proxy.NewTarget(definition.Type, definition.Settings)
+	if err != nil {
+		return proxy.Proxy{}, &ConfigError{Key: "settings", Err: err}
+	}
+
+	return proxy.NewProxy(target, filter).WithName(definition.Name), nil
+}
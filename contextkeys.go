@@ -2,4 +2,7 @@ package logar
 
 type contextKey string
 
-const logarContextKey contextKey = "logarContext"
+const (
+	logarContextKey contextKey = "logarContext"
+	spanContextKey  contextKey = "logarSpan"
+)
@@ -0,0 +1,139 @@
+package logar
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"sadk.dev/logar/models"
+)
+
+type dedupKey struct {
+	model    models.Model
+	category string
+	severity models.Severity
+	message  string
+}
+
+type dedupEntry struct {
+	id        uint
+	firstSeen time.Time
+	stored    chan struct{} // set while the log is being stored, closed once it is
+}
+
+// deduplicator remembers recently stored logs, so identical logs within the window
+// update the stored row instead of inserting new ones.
+type deduplicator struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[dedupKey]dedupEntry
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{
+		window:  window,
+		entries: map[dedupKey]dedupEntry{},
+	}
+}
+
+func keyOf(log models.Log) dedupKey {
+	return dedupKey{
+		model:    log.Model,
+		category: log.Category,
+		severity: log.Severity,
+		message:  log.Message,
+	}
+}
+
+// reserve returns the id of the stored log that is identical to log, if it was stored within the window.
+// Otherwise it reserves log, so that identical logs sent meanwhile wait for it to be stored instead of
+// being stored as well. The caller must store the log and call remember, or call forget if it failed.
+func (d *deduplicator) reserve(log models.Log) (uint, bool) {
+	key := keyOf(log)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[key]
+	for ok && entry.stored != nil {
+		// An identical log is being stored, wait for its id
+		d.mu.Unlock()
+		<-entry.stored
+		d.mu.Lock()
+		entry, ok = d.entries[key]
+	}
+
+	// Logs may be sent out of order, an older log within the window is a repeat as well
+	if ok && log.CreatedAt.Sub(entry.firstSeen).Abs() <= d.window {
+		return entry.id, true
+	}
+	d.entries[key] = dedupEntry{
+		firstSeen: log.CreatedAt,
+		stored:    make(chan struct{}),
+	}
+	return 0, false
+}
+
+// remember records the id of the stored log reserved by reserve.
+func (d *deduplicator) remember(log models.Log) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := keyOf(log)
+	if entry, ok := d.entries[key]; ok && entry.stored != nil {
+		close(entry.stored)
+	}
+	d.entries[key] = dedupEntry{
+		id:        log.ID,
+		firstSeen: log.CreatedAt,
+	}
+}
+
+// forget releases the reservation of a log that couldn't be stored.
+func (d *deduplicator) forget(log models.Log) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := keyOf(log)
+	if entry, ok := d.entries[key]; ok && entry.stored != nil {
+		close(entry.stored)
+		delete(d.entries, key)
+	}
+}
+
+// prune forgets logs whose window has passed.
+func (d *deduplicator) prune(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, entry := range d.entries {
+		// Reservations are settled by the logs being stored
+		if entry.stored == nil && now.Sub(entry.firstSeen) > d.window {
+			delete(d.entries, key)
+		}
+	}
+}
+
+func (l *AppImpl) runDedupPruner() {
+	l.runWorker(func(done <-chan struct{}) {
+		ticker := time.NewTicker(l.dedup.window)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				l.dedup.prune(now)
+			case <-done:
+				return
+			}
+		}
+	})
+}
+
+// incrementRepeat records another occurrence of the stored log with the given id.
+func (l *AppImpl) incrementRepeat(id uint, seenAt time.Time) error {
+	return l.db.Model(&models.Log{}).Where("id = ?", id).Updates(map[string]any{
+		"repeat_count": gorm.Expr("repeat_count + 1"),
+		"last_seen_at": seenAt,
+	}).Error
+}
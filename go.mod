@@ -3,10 +3,13 @@ module sadk.dev/logar
 go 1.23.3
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/expr-lang/expr v1.17.2
 	github.com/google/uuid v1.6.0
 	github.com/labstack/echo/v4 v4.13.3
+	github.com/mattn/go-isatty v0.0.20
 	golang.org/x/crypto v0.36.0
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/sqlite v1.5.7
 	gorm.io/gorm v1.25.12
 	sadk.dev/logar-web v0.0.0-00010101000000-000000000000
@@ -17,7 +20,6 @@ replace sadk.dev/logar-web => ../logar-web
 require (
 	github.com/labstack/gommon v0.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	golang.org/x/net v0.37.0 // indirect
@@ -0,0 +1,88 @@
+package logar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/models"
+)
+
+const ingestTokenPrefix = "lgr_"
+
+var ErrInvalidIngestToken = errors.New("invalid ingest token")
+
+// CreateIngestToken creates a token for remote producers, allowed to write to the given models
+// or to every model if none are given. The token is returned only once, only its hash is stored.
+func (w *WebPanelImpl) CreateIngestToken(name string, allowedModels []Model) (models.IngestToken, string, error) {
+	names := make([]string, 0, len(allowedModels))
+	for _, model := range allowedModels {
+		model := strings.TrimSpace(string(model))
+		if strings.Contains(model, ",") {
+			return models.IngestToken{}, "", errors.New("model names in ingest tokens can't contain commas")
+		}
+		if model != "" {
+			names = append(names, model)
+		}
+	}
+
+	token := ingestTokenPrefix + randomHex(24)
+	ingestToken := models.IngestToken{
+		Name:      name,
+		TokenHash: hashIngestToken(token),
+		Prefix:    token[:len(ingestTokenPrefix)+6],
+		Models:    strings.Join(names, ","),
+	}
+
+	err := w.core.db.Create(&ingestToken).Error
+	if err != nil {
+		return models.IngestToken{}, "", err
+	}
+	return ingestToken, token, nil
+}
+
+func (w *WebPanelImpl) GetIngestTokens() ([]models.IngestToken, error) {
+	var tokens []models.IngestToken
+	err := w.core.db.Order("created_at DESC").Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (w *WebPanelImpl) DeleteIngestToken(id uint) error {
+	return w.core.db.Delete(&models.IngestToken{}, id).Error
+}
+
+// ValidateIngestToken returns the ingest token matching token and records its use.
+func (w *WebPanelImpl) ValidateIngestToken(token string) (*models.IngestToken, error) {
+	if !strings.HasPrefix(token, ingestTokenPrefix) {
+		return nil, ErrInvalidIngestToken
+	}
+
+	var ingestToken models.IngestToken
+	err := w.core.db.Where("token_hash = ?", hashIngestToken(token)).Limit(1).Find(&ingestToken).Error
+	if err != nil {
+		return nil, err
+	}
+	if ingestToken.ID == 0 {
+		return nil, ErrInvalidIngestToken
+	}
+
+	// Only update the column once a minute, tokens are used for every request
+	now := time.Now()
+	if now.Sub(ingestToken.LastUsedAt) > time.Minute {
+		ingestToken.LastUsedAt = now
+		w.core.db.Model(&ingestToken).Update("last_used_at", now)
+	}
+
+	return &ingestToken, nil
+}
+
+func hashIngestToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,111 @@
+package batch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Poster posts request bodies to a URL, retrying failures that may succeed later with exponential backoff.
+type Poster struct {
+	URL         string
+	ContentType string
+	Header      http.Header
+	Gzip        bool          // compress request bodies
+	MaxRetries  int           // retries after the first attempt
+	Backoff     time.Duration // delay before the first retry, doubled after every attempt
+	Client      *http.Client
+
+	// Done stops the waits between retries when it is closed, so that closing doesn't hang.
+	// The remaining retries are still made.
+	Done <-chan struct{}
+}
+
+// Retry calls fn until it succeeds, returns an error that must not be retried, or the retries run out.
+func (p *Poster) Retry(fn func() (retry bool, err error)) error {
+	backoff := p.Backoff
+	var err error
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-p.Done:
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		retry, err = fn()
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// Post posts the body with retries and returns the body of the successful response.
+func (p *Poster) Post(body []byte) ([]byte, error) {
+	var response []byte
+	err := p.Retry(func() (bool, error) {
+		var retry bool
+		var err error
+		response, retry, err = p.Try(body)
+		return retry, err
+	})
+	return response, err
+}
+
+// Try posts the body once. retry reports whether the error may go away by posting again.
+func (p *Poster) Try(body []byte) (response []byte, retry bool, err error) {
+	reader := io.Reader(bytes.NewReader(body))
+	if p.Gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		if err := gz.Close(); err != nil {
+			return nil, false, err
+		}
+		reader = &buf
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.URL, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	for key, values := range p.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", p.ContentType)
+	if p.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		response, err = io.ReadAll(resp.Body)
+		return response, err != nil, err
+	}
+
+	reason, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	io.Copy(io.Discard, resp.Body)
+	err = fmt.Errorf("server responded with %s: %s", resp.Status, strings.TrimSpace(string(reason)))
+	return nil, RetryableStatus(resp.StatusCode), err
+}
+
+// RetryableStatus reports whether a request that failed with the HTTP status may succeed later.
+func RetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Package batch holds the batching and retrying shared by the proxy targets of bulk APIs.
+package batch
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Config is how a Queue batches items.
+type Config struct {
+	BatchSize     int           // items per export, defaults to 100
+	FlushInterval time.Duration // how often queued items are exported even if the batch isn't full, defaults to 5 seconds
+	MaxQueueSize  int           // items that can wait to be exported, defaults to 10000
+}
+
+// Queue collects items and exports them in batches from a background goroutine, in order.
+type Queue[T any] struct {
+	config Config
+	export func([]T) error
+
+	mu       sync.Mutex
+	items    []T
+	onResult func(items []T, err error, elapsed time.Duration)
+
+	// exportMu keeps batches in order
+	exportMu    sync.Mutex
+	flushSignal chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+// NewQueue starts a queue exporting batches with export. Close must be called to export the remaining items.
+func NewQueue[T any](config Config, export func([]T) error) *Queue[T] {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = 10000
+	}
+
+	q := &Queue[T]{
+		config:      config,
+		export:      export,
+		flushSignal: make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+// Add queues the item, it returns false if the queue is full.
+func (q *Queue[T]) Add(item T) bool {
+	q.mu.Lock()
+	if len(q.items) >= q.config.MaxQueueSize {
+		q.mu.Unlock()
+		return false
+	}
+	q.items = append(q.items, item)
+	full := len(q.items) >= q.config.BatchSize
+	q.mu.Unlock()
+
+	if full {
+		select {
+		case q.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// OnResult sets a function called with every exported batch and the error of its export, including
+// batches exported by the background goroutine, whose errors aren't returned anywhere else.
+func (q *Queue[T]) OnResult(fn func(items []T, err error, elapsed time.Duration)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onResult = fn
+}
+
+// Done is closed when the queue is closed, so that retries can stop waiting.
+func (q *Queue[T]) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *Queue[T]) run() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+		case <-q.flushSignal:
+		}
+		// Errors are reported to the OnResult function
+		q.Flush()
+	}
+}
+
+// Flush exports all queued items and waits for the exports to finish.
+func (q *Queue[T]) Flush() error {
+	q.exportMu.Lock()
+	defer q.exportMu.Unlock()
+
+	var errs []error
+	for {
+		q.mu.Lock()
+		n := min(len(q.items), q.config.BatchSize)
+		batch := q.items[:n:n]
+		q.items = q.items[n:]
+		onResult := q.onResult
+		q.mu.Unlock()
+
+		if n == 0 {
+			break
+		}
+		start := time.Now()
+		err := q.export(batch)
+		if onResult != nil {
+			onResult(batch, err, time.Since(start))
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the background goroutine and exports the remaining items.
+func (q *Queue[T]) Close() error {
+	q.closeOnce.Do(func() {
+		close(q.done)
+	})
+	q.wg.Wait()
+	return q.Flush()
+}
@@ -0,0 +1,113 @@
+package configformat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data string
+		want map[string]any
+	}{
+		{
+			name: "yaml scalars",
+			path: "logar.yaml",
+			data: "name: app\nport: 8080\nratio: 0.5\nenabled: true\nempty:\nmode: 0755\nquoted: \"0755\"\n",
+			want: map[string]any{"name": "app", "port": int64(8080), "ratio": 0.5, "enabled": true, "empty": nil, "mode": int64(0755), "quoted": "0755"},
+		},
+		{
+			name: "yaml nested",
+			path: "logar.yml",
+			data: "admin:\n  username: admin\nproxies:\n  - type: file\n    settings: {path: logs.jsonl}\n  - type: loki\n",
+			want: map[string]any{
+				"admin": map[string]any{"username": "admin"},
+				"proxies": []any{
+					map[string]any{"type": "file", "settings": map[string]any{"path": "logs.jsonl"}},
+					map[string]any{"type": "loki"},
+				},
+			},
+		},
+		{
+			name: "yaml anchors and timestamps",
+			path: "logar.yaml",
+			data: "base: &base\n  retry: 3\nproxy:\n  <<: *base\n  type: file\nsince: 2024-01-02\n",
+			want: map[string]any{
+				"base":  map[string]any{"retry": int64(3)},
+				"proxy": map[string]any{"retry": int64(3), "type": "file"},
+				"since": "2024-01-02",
+			},
+		},
+		{
+			name: "yaml empty",
+			path: "logar.yaml",
+			data: "# only a comment\n",
+			want: map[string]any{},
+		},
+		{
+			name: "toml",
+			path: "logar.toml",
+			data: "name = \"app\"\nport = 8080\nratio = 0.5\n\n[admin]\nusername = \"admin\"\n\n[[proxies]]\ntype = \"file\"\nsettings = { path = \"logs.jsonl\" }\n\n[[proxies]]\ntype = \"loki\"\ntags = [\"a\", \"b\"]\n",
+			want: map[string]any{
+				"name":  "app",
+				"port":  int64(8080),
+				"ratio": 0.5,
+				"admin": map[string]any{"username": "admin"},
+				"proxies": []any{
+					map[string]any{"type": "file", "settings": map[string]any{"path": "logs.jsonl"}},
+					map[string]any{"type": "loki", "tags": []any{"a", "b"}},
+				},
+			},
+		},
+		{
+			name: "toml dates",
+			path: "logar.toml",
+			data: "offset = 2024-01-02T03:04:05Z\nlocal = 2024-01-02T03:04:05\ndate = 2024-01-02\ntime = 03:04:05\n",
+			want: map[string]any{"offset": "2024-01-02T03:04:05Z", "local": "2024-01-02T03:04:05", "date": "2024-01-02", "time": "03:04:05"},
+		},
+		{
+			name: "json",
+			path: "logar.json",
+			data: `{"name": "app", "port": 8080, "ratio": 0.5, "tags": ["a"]}`,
+			want: map[string]any{"name": "app", "port": int64(8080), "ratio": 0.5, "tags": []any{"a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.path, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data string
+	}{
+		{"yaml root sequence", "logar.yaml", "- a\n- b\n"},
+		{"yaml duplicate key", "logar.yaml", "a: 1\na: 2\n"},
+		{"yaml tab indentation", "logar.yaml", "a:\n\tb: 1\n"},
+		{"toml redefined table", "logar.toml", "[a]\nb = 1\n[a]\nc = 2\n"},
+		{"toml missing value", "logar.toml", "a =\n"},
+		{"json trailing comma", "logar.json", `{"a": 1,}`},
+		{"unknown extension", "logar.ini", "a = 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.path, []byte(tt.data)); err == nil {
+				t.Error("Parse() error = nil, want an error")
+			}
+		})
+	}
+}
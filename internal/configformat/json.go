@@ -0,0 +1,59 @@
+package configformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ParseJSON parses a JSON document whose root is an object. Integers are returned as int64.
+func ParseJSON(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var root map[string]any
+	if err := decoder.Decode(&root); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("line %d: %w", 1+bytes.Count(data[:syntaxErr.Offset], []byte("\n")), err)
+		}
+		return nil, err
+	}
+	return normalizeJSON(root).(map[string]any), nil
+}
+
+func normalizeJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeJSON(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeJSON(item)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// Parse parses the file contents in the format of its extension: .json, .yaml, .yml or .toml.
+func Parse(path string, data []byte) (map[string]any, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	case ".toml":
+		return ParseTOML(data)
+	}
+	return nil, fmt.Errorf("unsupported config file extension %q, expected .json, .yaml, .yml or .toml", filepath.Ext(path))
+}
@@ -0,0 +1,76 @@
+package configformat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ParseTOML parses a TOML document.
+func ParseTOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	if _, err := toml.Decode(string(data), &root); err != nil {
+		return nil, err
+	}
+
+	normalized, err := normalizeTOML(root)
+	if err != nil {
+		return nil, err
+	}
+	return normalized.(map[string]any), nil
+}
+
+// normalizeTOML converts the values decoded by toml to the generic values of the package.
+func normalizeTOML(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			normalized, err := normalizeTOML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case []map[string]any:
+		// Arrays of tables
+		items := make([]any, len(v))
+		for i, item := range v {
+			normalized, err := normalizeTOML(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = normalized
+		}
+		return items, nil
+	case []any:
+		for i, item := range v {
+			normalized, err := normalizeTOML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	case time.Time:
+		return formatTOMLTime(v), nil
+	case string, int64, float64, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported TOML value %T", value)
+}
+
+// formatTOMLTime formats a date or time as it's written in TOML. Local dates and times are decoded
+// in zones named after their type.
+func formatTOMLTime(t time.Time) string {
+	switch t.Location().String() {
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
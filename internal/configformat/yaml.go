@@ -0,0 +1,96 @@
+// Package configformat parses configuration files into generic values: map[string]any, []any,
+// string, int64, float64, bool and nil.
+//
+// YAML is parsed with gopkg.in/yaml.v3 and TOML with github.com/BurntSushi/toml. Dates and times
+// are returned as strings, as written in the file.
+package configformat
+
+import (
+	"fmt"
+	"math"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAML parses a YAML document whose root is a mapping. Only the first document of the file is read.
+func ParseYAML(data []byte) (map[string]any, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return map[string]any{}, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: the document must be a mapping", root.Line)
+	}
+	timestampsAsStrings(root)
+
+	var value any
+	if err := root.Decode(&value); err != nil {
+		return nil, err
+	}
+	normalized, err := normalizeYAML(value)
+	if err != nil {
+		return nil, err
+	}
+	return normalized.(map[string]any), nil
+}
+
+// timestampsAsStrings tags the timestamps of the tree as strings, so they are decoded as written
+// instead of as time.Time. Aliases aren't followed, the nodes they refer to are in the tree.
+func timestampsAsStrings(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+		node.Tag = "!!str"
+	}
+	for _, child := range node.Content {
+		timestampsAsStrings(child)
+	}
+}
+
+// normalizeYAML converts the values decoded by yaml.v3 to the generic values of the package.
+func normalizeYAML(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case map[any]any:
+		// Mappings with keys that aren't strings, such as numbers or booleans
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			result[fmt.Sprint(key)] = normalized
+		}
+		return result, nil
+	case []any:
+		for i, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	case int:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("integer %d is too large", v)
+		}
+		return int64(v), nil
+	case string, int64, float64, bool, nil:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported YAML value %T", value)
+}
@@ -0,0 +1,641 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+)
+
+// Field numbers follow opentelemetry-proto (common/v1, resource/v1, logs/v1, trace/v1 and the collector services).
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// maxValueDepth is how deeply array and key-value list values can be nested.
+const maxValueDepth = 32
+
+var (
+	ErrInvalidProtobuf = errors.New("otlp: invalid protobuf payload")
+	ErrValueTooDeep    = errors.New("otlp: values are nested too deeply")
+)
+
+func MarshalLogs(req *ExportLogsServiceRequest) []byte {
+	var b []byte
+	for _, rl := range req.ResourceLogs {
+		b = appendMessage(b, 1, marshalResourceLogs(rl))
+	}
+	return b
+}
+
+func MarshalTraces(req *ExportTraceServiceRequest) []byte {
+	var b []byte
+	for _, rs := range req.ResourceSpans {
+		b = appendMessage(b, 1, marshalResourceSpans(rs))
+	}
+	return b
+}
+
+func MarshalLogsResponse(resp *ExportLogsServiceResponse) []byte {
+	if resp.PartialSuccess == nil {
+		return nil
+	}
+	var partial []byte
+	partial = appendVarintField(partial, 1, uint64(resp.PartialSuccess.RejectedLogRecords))
+	partial = appendString(partial, 2, resp.PartialSuccess.ErrorMessage)
+	return appendMessage(nil, 1, partial)
+}
+
+func MarshalTracesResponse(resp *ExportTraceServiceResponse) []byte {
+	if resp.PartialSuccess == nil {
+		return nil
+	}
+	var partial []byte
+	partial = appendVarintField(partial, 1, uint64(resp.PartialSuccess.RejectedSpans))
+	partial = appendString(partial, 2, resp.PartialSuccess.ErrorMessage)
+	return appendMessage(nil, 1, partial)
+}
+
+// MarshalStatus encodes a google.rpc.Status, the body of OTLP/HTTP error responses.
+func MarshalStatus(code int32, message string) []byte {
+	var b []byte
+	b = appendVarintField(b, 1, uint64(code))
+	b = appendString(b, 2, message)
+	return b
+}
+
+func marshalResourceLogs(rl ResourceLogs) []byte {
+	var b []byte
+	b = appendMessage(b, 1, marshalResource(rl.Resource))
+	for _, sl := range rl.ScopeLogs {
+		b = appendMessage(b, 2, marshalScopeLogs(sl))
+	}
+	b = appendString(b, 3, rl.SchemaURL)
+	return b
+}
+
+func marshalResourceSpans(rs ResourceSpans) []byte {
+	var b []byte
+	b = appendMessage(b, 1, marshalResource(rs.Resource))
+	for _, ss := range rs.ScopeSpans {
+		b = appendMessage(b, 2, marshalScopeSpans(ss))
+	}
+	b = appendString(b, 3, rs.SchemaURL)
+	return b
+}
+
+func marshalResource(r Resource) []byte {
+	var b []byte
+	for _, kv := range r.Attributes {
+		b = appendMessage(b, 1, marshalKeyValue(kv))
+	}
+	return b
+}
+
+func marshalScope(s Scope) []byte {
+	var b []byte
+	b = appendString(b, 1, s.Name)
+	b = appendString(b, 2, s.Version)
+	return b
+}
+
+func marshalScopeLogs(sl ScopeLogs) []byte {
+	var b []byte
+	b = appendMessage(b, 1, marshalScope(sl.Scope))
+	for _, lr := range sl.LogRecords {
+		b = appendMessage(b, 2, marshalLogRecord(lr))
+	}
+	b = appendString(b, 3, sl.SchemaURL)
+	return b
+}
+
+func marshalScopeSpans(ss ScopeSpans) []byte {
+	var b []byte
+	b = appendMessage(b, 1, marshalScope(ss.Scope))
+	for _, span := range ss.Spans {
+		b = appendMessage(b, 2, marshalSpan(span))
+	}
+	b = appendString(b, 3, ss.SchemaURL)
+	return b
+}
+
+func marshalLogRecord(lr LogRecord) []byte {
+	var b []byte
+	b = appendFixed64(b, 1, uint64(lr.TimeUnixNano))
+	b = appendVarintField(b, 2, uint64(lr.SeverityNumber))
+	b = appendString(b, 3, lr.SeverityText)
+	if lr.Body != nil {
+		b = appendMessage(b, 5, marshalAnyValue(*lr.Body))
+	}
+	for _, kv := range lr.Attributes {
+		b = appendMessage(b, 6, marshalKeyValue(kv))
+	}
+	b = appendFixed32(b, 8, lr.Flags)
+	b = appendHexBytes(b, 9, lr.TraceID)
+	b = appendHexBytes(b, 10, lr.SpanID)
+	b = appendFixed64(b, 11, uint64(lr.ObservedTimeUnixNano))
+	return b
+}
+
+func marshalSpan(span Span) []byte {
+	var b []byte
+	b = appendHexBytes(b, 1, span.TraceID)
+	b = appendHexBytes(b, 2, span.SpanID)
+	b = appendString(b, 3, span.TraceState)
+	b = appendHexBytes(b, 4, span.ParentSpanID)
+	b = appendString(b, 5, span.Name)
+	b = appendVarintField(b, 6, uint64(span.Kind))
+	b = appendFixed64(b, 7, uint64(span.StartTimeUnixNano))
+	b = appendFixed64(b, 8, uint64(span.EndTimeUnixNano))
+	for _, kv := range span.Attributes {
+		b = appendMessage(b, 9, marshalKeyValue(kv))
+	}
+	if span.Status != nil {
+		var status []byte
+		status = appendString(status, 2, span.Status.Message)
+		status = appendVarintField(status, 3, uint64(span.Status.Code))
+		b = appendMessage(b, 15, status)
+	}
+	return b
+}
+
+func marshalKeyValue(kv KeyValue) []byte {
+	var b []byte
+	b = appendString(b, 1, kv.Key)
+	b = appendMessage(b, 2, marshalAnyValue(kv.Value))
+	return b
+}
+
+// marshalAnyValue writes the set field of the oneof, even if it has its zero value.
+func marshalAnyValue(v AnyValue) []byte {
+	var b []byte
+	switch {
+	case v.StringValue != nil:
+		b = appendMessage(b, 1, []byte(*v.StringValue))
+	case v.BoolValue != nil:
+		value := uint64(0)
+		if *v.BoolValue {
+			value = 1
+		}
+		b = appendTag(b, 2, wireVarint)
+		b = binary.AppendUvarint(b, value)
+	case v.IntValue != nil:
+		b = appendTag(b, 3, wireVarint)
+		b = binary.AppendUvarint(b, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		b = appendTag(b, 4, wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(*v.DoubleValue))
+	case v.ArrayValue != nil:
+		var array []byte
+		for _, item := range v.ArrayValue.Values {
+			array = appendMessage(array, 1, marshalAnyValue(item))
+		}
+		b = appendMessage(b, 5, array)
+	case v.KvlistValue != nil:
+		var list []byte
+		for _, kv := range v.KvlistValue.Values {
+			list = appendMessage(list, 1, marshalKeyValue(kv))
+		}
+		b = appendMessage(b, 6, list)
+	case v.BytesValue != nil:
+		b = appendMessage(b, 7, v.BytesValue)
+	}
+	return b
+}
+
+func appendTag(b []byte, num int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(wireType))
+}
+
+func appendMessage(b []byte, num int, data []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendMessage(b, num, []byte(s))
+}
+
+func appendHexBytes(b []byte, num int, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return b
+	}
+	return appendMessage(b, num, data)
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, num, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendFixed64(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, num, wireFixed64)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendFixed32(b []byte, num int, v uint32) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, num, wireFixed32)
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+// field is a single decoded protobuf field. data is set for length-delimited fields, value for the others.
+type field struct {
+	num      int
+	wireType int
+	value    uint64
+	data     []byte
+}
+
+func forEachField(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return ErrInvalidProtobuf
+		}
+		b = b[n:]
+
+		f := field{num: int(tag >> 3), wireType: int(tag & 7)}
+		switch f.wireType {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return ErrInvalidProtobuf
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return ErrInvalidProtobuf
+			}
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return ErrInvalidProtobuf
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return ErrInvalidProtobuf
+			}
+			f.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			// groups are deprecated and not used by OTLP
+			return ErrInvalidProtobuf
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func UnmarshalLogs(b []byte) (*ExportLogsServiceRequest, error) {
+	req := &ExportLogsServiceRequest{}
+	err := forEachField(b, func(f field) error {
+		if f.num != 1 || f.wireType != wireBytes {
+			return nil
+		}
+		rl, err := unmarshalResourceLogs(f.data)
+		req.ResourceLogs = append(req.ResourceLogs, rl)
+		return err
+	})
+	return req, err
+}
+
+func UnmarshalTraces(b []byte) (*ExportTraceServiceRequest, error) {
+	req := &ExportTraceServiceRequest{}
+	err := forEachField(b, func(f field) error {
+		if f.num != 1 || f.wireType != wireBytes {
+			return nil
+		}
+		rs, err := unmarshalResourceSpans(f.data)
+		req.ResourceSpans = append(req.ResourceSpans, rs)
+		return err
+	})
+	return req, err
+}
+
+// UnmarshalLogsResponse decodes the response of a logs export, whose partial success is only set if
+// records were rejected.
+func UnmarshalLogsResponse(b []byte) (*ExportLogsServiceResponse, error) {
+	resp := &ExportLogsServiceResponse{}
+	err := forEachField(b, func(f field) error {
+		if f.num != 1 || f.wireType != wireBytes {
+			return nil
+		}
+		resp.PartialSuccess = &ExportLogsPartialSuccess{}
+		return forEachField(f.data, func(f field) error {
+			switch {
+			case f.num == 1 && f.wireType == wireVarint:
+				resp.PartialSuccess.RejectedLogRecords = Int64(f.value)
+			case f.num == 2 && f.wireType == wireBytes:
+				resp.PartialSuccess.ErrorMessage = string(f.data)
+			}
+			return nil
+		})
+	})
+	return resp, err
+}
+
+// UnmarshalTracesResponse decodes the response of a traces export, whose partial success is only set
+// if spans were rejected.
+func UnmarshalTracesResponse(b []byte) (*ExportTraceServiceResponse, error) {
+	resp := &ExportTraceServiceResponse{}
+	err := forEachField(b, func(f field) error {
+		if f.num != 1 || f.wireType != wireBytes {
+			return nil
+		}
+		resp.PartialSuccess = &ExportTracePartialSuccess{}
+		return forEachField(f.data, func(f field) error {
+			switch {
+			case f.num == 1 && f.wireType == wireVarint:
+				resp.PartialSuccess.RejectedSpans = Int64(f.value)
+			case f.num == 2 && f.wireType == wireBytes:
+				resp.PartialSuccess.ErrorMessage = string(f.data)
+			}
+			return nil
+		})
+	})
+	return resp, err
+}
+
+func unmarshalResourceLogs(b []byte) (ResourceLogs, error) {
+	rl := ResourceLogs{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			rl.Resource, err = unmarshalResource(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			var sl ScopeLogs
+			sl, err = unmarshalScopeLogs(f.data)
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		case f.num == 3 && f.wireType == wireBytes:
+			rl.SchemaURL = string(f.data)
+		}
+		return err
+	})
+	return rl, err
+}
+
+func unmarshalResourceSpans(b []byte) (ResourceSpans, error) {
+	rs := ResourceSpans{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			rs.Resource, err = unmarshalResource(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			var ss ScopeSpans
+			ss, err = unmarshalScopeSpans(f.data)
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		case f.num == 3 && f.wireType == wireBytes:
+			rs.SchemaURL = string(f.data)
+		}
+		return err
+	})
+	return rs, err
+}
+
+func unmarshalResource(b []byte) (Resource, error) {
+	r := Resource{}
+	err := forEachField(b, func(f field) error {
+		if f.num != 1 || f.wireType != wireBytes {
+			return nil
+		}
+		kv, err := unmarshalKeyValue(f.data, 0)
+		r.Attributes = append(r.Attributes, kv)
+		return err
+	})
+	return r, err
+}
+
+func unmarshalScope(b []byte) (Scope, error) {
+	s := Scope{}
+	err := forEachField(b, func(f field) error {
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			s.Name = string(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			s.Version = string(f.data)
+		}
+		return nil
+	})
+	return s, err
+}
+
+func unmarshalScopeLogs(b []byte) (ScopeLogs, error) {
+	sl := ScopeLogs{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			sl.Scope, err = unmarshalScope(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			var lr LogRecord
+			lr, err = unmarshalLogRecord(f.data)
+			sl.LogRecords = append(sl.LogRecords, lr)
+		case f.num == 3 && f.wireType == wireBytes:
+			sl.SchemaURL = string(f.data)
+		}
+		return err
+	})
+	return sl, err
+}
+
+func unmarshalScopeSpans(b []byte) (ScopeSpans, error) {
+	ss := ScopeSpans{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			ss.Scope, err = unmarshalScope(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			var span Span
+			span, err = unmarshalSpan(f.data)
+			ss.Spans = append(ss.Spans, span)
+		case f.num == 3 && f.wireType == wireBytes:
+			ss.SchemaURL = string(f.data)
+		}
+		return err
+	})
+	return ss, err
+}
+
+func unmarshalLogRecord(b []byte) (LogRecord, error) {
+	lr := LogRecord{}
+	err := forEachField(b, func(f field) error {
+		switch {
+		case f.num == 1 && f.wireType == wireFixed64:
+			lr.TimeUnixNano = Uint64(f.value)
+		case f.num == 2 && f.wireType == wireVarint:
+			lr.SeverityNumber = int32(f.value)
+		case f.num == 3 && f.wireType == wireBytes:
+			lr.SeverityText = string(f.data)
+		case f.num == 5 && f.wireType == wireBytes:
+			body, err := unmarshalAnyValue(f.data, 0)
+			if err != nil {
+				return err
+			}
+			lr.Body = &body
+		case f.num == 6 && f.wireType == wireBytes:
+			kv, err := unmarshalKeyValue(f.data, 0)
+			if err != nil {
+				return err
+			}
+			lr.Attributes = append(lr.Attributes, kv)
+		case f.num == 8 && f.wireType == wireFixed32:
+			lr.Flags = uint32(f.value)
+		case f.num == 9 && f.wireType == wireBytes:
+			lr.TraceID = hex.EncodeToString(f.data)
+		case f.num == 10 && f.wireType == wireBytes:
+			lr.SpanID = hex.EncodeToString(f.data)
+		case f.num == 11 && f.wireType == wireFixed64:
+			lr.ObservedTimeUnixNano = Uint64(f.value)
+		}
+		return nil
+	})
+	return lr, err
+}
+
+func unmarshalSpan(b []byte) (Span, error) {
+	span := Span{}
+	err := forEachField(b, func(f field) error {
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			span.TraceID = hex.EncodeToString(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			span.SpanID = hex.EncodeToString(f.data)
+		case f.num == 3 && f.wireType == wireBytes:
+			span.TraceState = string(f.data)
+		case f.num == 4 && f.wireType == wireBytes:
+			span.ParentSpanID = hex.EncodeToString(f.data)
+		case f.num == 5 && f.wireType == wireBytes:
+			span.Name = string(f.data)
+		case f.num == 6 && f.wireType == wireVarint:
+			span.Kind = int32(f.value)
+		case f.num == 7 && f.wireType == wireFixed64:
+			span.StartTimeUnixNano = Uint64(f.value)
+		case f.num == 8 && f.wireType == wireFixed64:
+			span.EndTimeUnixNano = Uint64(f.value)
+		case f.num == 9 && f.wireType == wireBytes:
+			kv, err := unmarshalKeyValue(f.data, 0)
+			if err != nil {
+				return err
+			}
+			span.Attributes = append(span.Attributes, kv)
+		case f.num == 15 && f.wireType == wireBytes:
+			status := &Status{}
+			err := forEachField(f.data, func(f field) error {
+				switch {
+				case f.num == 2 && f.wireType == wireBytes:
+					status.Message = string(f.data)
+				case f.num == 3 && f.wireType == wireVarint:
+					status.Code = int32(f.value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			span.Status = status
+		}
+		return nil
+	})
+	return span, err
+}
+
+// unmarshalKeyValue decodes an attribute whose value is nested in depth arrays or key-value lists.
+func unmarshalKeyValue(b []byte, depth int) (KeyValue, error) {
+	kv := KeyValue{}
+	err := forEachField(b, func(f field) error {
+		var err error
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			kv.Key = string(f.data)
+		case f.num == 2 && f.wireType == wireBytes:
+			kv.Value, err = unmarshalAnyValue(f.data, depth)
+		}
+		return err
+	})
+	return kv, err
+}
+
+// unmarshalAnyValue decodes a value nested in depth arrays or key-value lists, up to maxValueDepth.
+func unmarshalAnyValue(b []byte, depth int) (AnyValue, error) {
+	if depth > maxValueDepth {
+		return AnyValue{}, ErrValueTooDeep
+	}
+
+	v := AnyValue{}
+	err := forEachField(b, func(f field) error {
+		switch {
+		case f.num == 1 && f.wireType == wireBytes:
+			s := string(f.data)
+			v.StringValue = &s
+		case f.num == 2 && f.wireType == wireVarint:
+			value := f.value != 0
+			v.BoolValue = &value
+		case f.num == 3 && f.wireType == wireVarint:
+			value := Int64(int64(f.value))
+			v.IntValue = &value
+		case f.num == 4 && f.wireType == wireFixed64:
+			value := math.Float64frombits(f.value)
+			v.DoubleValue = &value
+		case f.num == 5 && f.wireType == wireBytes:
+			array := &ArrayValue{}
+			err := forEachField(f.data, func(f field) error {
+				if f.num != 1 || f.wireType != wireBytes {
+					return nil
+				}
+				item, err := unmarshalAnyValue(f.data, depth+1)
+				array.Values = append(array.Values, item)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			v.ArrayValue = array
+		case f.num == 6 && f.wireType == wireBytes:
+			list := &KeyValueList{}
+			err := forEachField(f.data, func(f field) error {
+				if f.num != 1 || f.wireType != wireBytes {
+					return nil
+				}
+				kv, err := unmarshalKeyValue(f.data, depth+1)
+				list.Values = append(list.Values, kv)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			v.KvlistValue = list
+		case f.num == 7 && f.wireType == wireBytes:
+			v.BytesValue = append([]byte{}, f.data...)
+		}
+		return nil
+	})
+	return v, err
+}
@@ -0,0 +1,78 @@
+package otlp
+
+import (
+	"strings"
+
+	"sadk.dev/logar/models"
+)
+
+// SeverityNumber maps a logar severity to the first OTLP severity number of its range.
+func SeverityNumber(severity models.Severity) int32 {
+	switch severity {
+	case models.Severity_Trace:
+		return 1 // TRACE
+	case models.Severity_Log:
+		return 5 // DEBUG
+	case models.Severity_Info:
+		return 9 // INFO
+	case models.Severity_Warning:
+		return 13 // WARN
+	case models.Severity_Error:
+		return 17 // ERROR
+	case models.Severity_Fatal:
+		return 21 // FATAL
+	}
+	return 0
+}
+
+// SeverityText is the OTLP short name of a logar severity.
+func SeverityText(severity models.Severity) string {
+	switch severity {
+	case models.Severity_Trace:
+		return "TRACE"
+	case models.Severity_Log:
+		return "DEBUG"
+	case models.Severity_Info:
+		return "INFO"
+	case models.Severity_Warning:
+		return "WARN"
+	case models.Severity_Error:
+		return "ERROR"
+	case models.Severity_Fatal:
+		return "FATAL"
+	}
+	return ""
+}
+
+// Severity maps an OTLP severity number to a logar severity.
+// If the number is unspecified, the severity text is used instead, defaulting to Log.
+func Severity(number int32, text string) models.Severity {
+	switch {
+	case number >= 1 && number <= 4:
+		return models.Severity_Trace
+	case number >= 5 && number <= 8:
+		return models.Severity_Log
+	case number >= 9 && number <= 12:
+		return models.Severity_Info
+	case number >= 13 && number <= 16:
+		return models.Severity_Warning
+	case number >= 17 && number <= 20:
+		return models.Severity_Error
+	case number >= 21:
+		return models.Severity_Fatal
+	}
+
+	switch strings.ToUpper(text) {
+	case "TRACE":
+		return models.Severity_Trace
+	case "INFO", "INFORMATION", "NOTICE":
+		return models.Severity_Info
+	case "WARN", "WARNING":
+		return models.Severity_Warning
+	case "ERROR", "ERR":
+		return models.Severity_Error
+	case "FATAL", "CRITICAL", "CRIT", "ALERT", "EMERGENCY", "PANIC":
+		return models.Severity_Fatal
+	}
+	return models.Severity_Log
+}
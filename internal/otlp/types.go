@@ -0,0 +1,327 @@
+// Package otlp contains the subset of the OpenTelemetry protocol (OTLP) used by logar,
+// with JSON encoding through encoding/json and a minimal protobuf encoder and decoder.
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+
+	LogsPath   = "/v1/logs"
+	TracesPath = "/v1/traces"
+)
+
+type ExportLogsServiceRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+	SchemaURL string      `json:"schemaUrl,omitempty"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type Scope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type ScopeLogs struct {
+	Scope      Scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+	SchemaURL  string      `json:"schemaUrl,omitempty"`
+}
+
+type LogRecord struct {
+	TimeUnixNano         Uint64     `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano Uint64     `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber       int32      `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 *AnyValue  `json:"body,omitempty"`
+	Attributes           []KeyValue `json:"attributes,omitempty"`
+	Flags                uint32     `json:"flags,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"` // hex encoded, also in JSON
+	SpanID               string     `json:"spanId,omitempty"`  // hex encoded, also in JSON
+}
+
+type ExportLogsServiceResponse struct {
+	PartialSuccess *ExportLogsPartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+type ExportLogsPartialSuccess struct {
+	RejectedLogRecords Int64  `json:"rejectedLogRecords,omitempty"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+type ExportTraceServiceRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+	SchemaURL  string       `json:"schemaUrl,omitempty"`
+}
+
+type ScopeSpans struct {
+	Scope     Scope  `json:"scope"`
+	Spans     []Span `json:"spans"`
+	SchemaURL string `json:"schemaUrl,omitempty"`
+}
+
+type ExportTraceServiceResponse struct {
+	PartialSuccess *ExportTracePartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+type ExportTracePartialSuccess struct {
+	RejectedSpans Int64  `json:"rejectedSpans,omitempty"`
+	ErrorMessage  string `json:"errorMessage,omitempty"`
+}
+
+type Span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	TraceState        string     `json:"traceState,omitempty"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int32      `json:"kind,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	EndTimeUnixNano   Uint64     `json:"endTimeUnixNano,omitempty"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            *Status    `json:"status,omitempty"`
+}
+
+type Status struct {
+	Message string `json:"message,omitempty"`
+	Code    int32  `json:"code,omitempty"`
+}
+
+const (
+	SpanKind_Unspecified int32 = iota
+	SpanKind_Internal
+	SpanKind_Server
+	SpanKind_Client
+	SpanKind_Producer
+	SpanKind_Consumer
+)
+
+const (
+	StatusCode_Unset int32 = iota
+	StatusCode_Ok
+	StatusCode_Error
+)
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *Int64        `json:"intValue,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  []byte        `json:"bytesValue,omitempty"`
+}
+
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+type KeyValueList struct {
+	Values []KeyValue `json:"values"`
+}
+
+// Int64 is encoded as a decimal string in JSON, as required by the protobuf JSON mapping.
+type Int64 int64
+
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	v, err := parseJSONInt(data, 64, true)
+	*i = Int64(v)
+	return err
+}
+
+// Uint64 is encoded as a decimal string in JSON, as required by the protobuf JSON mapping.
+type Uint64 uint64
+
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(u), 10))
+}
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	v, err := parseJSONInt(data, 64, false)
+	*u = Uint64(v)
+	return err
+}
+
+// parseJSONInt accepts both numbers and decimal strings.
+func parseJSONInt(data []byte, bits int, signed bool) (int64, error) {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, err
+		}
+	} else {
+		s = string(data)
+	}
+
+	if signed {
+		return strconv.ParseInt(s, 10, bits)
+	}
+	v, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %s: %w", data, err)
+	}
+	return int64(v), nil
+}
+
+func String(s string) AnyValue {
+	return AnyValue{StringValue: &s}
+}
+
+// ValueOf converts a Go value, such as one decoded by encoding/json, to an AnyValue.
+func ValueOf(value any) AnyValue {
+	switch v := value.(type) {
+	case nil:
+		return AnyValue{}
+	case string:
+		return String(v)
+	case bool:
+		return AnyValue{BoolValue: &v}
+	case int:
+		i := Int64(v)
+		return AnyValue{IntValue: &i}
+	case int32:
+		i := Int64(v)
+		return AnyValue{IntValue: &i}
+	case int64:
+		i := Int64(v)
+		return AnyValue{IntValue: &i}
+	case uint:
+		i := Int64(v)
+		return AnyValue{IntValue: &i}
+	case uint32:
+		i := Int64(v)
+		return AnyValue{IntValue: &i}
+	case uint64:
+		i := Int64(v)
+		return AnyValue{IntValue: &i}
+	case float32:
+		f := float64(v)
+		return AnyValue{DoubleValue: &f}
+	case float64:
+		if v == float64(int64(v)) && v < 1<<53 && v > -(1<<53) {
+			i := Int64(v)
+			return AnyValue{IntValue: &i}
+		}
+		return AnyValue{DoubleValue: &v}
+	case []byte:
+		return AnyValue{BytesValue: v}
+	case []any:
+		values := make([]AnyValue, len(v))
+		for i, item := range v {
+			values[i] = ValueOf(item)
+		}
+		return AnyValue{ArrayValue: &ArrayValue{Values: values}}
+	case map[string]any:
+		return AnyValue{KvlistValue: &KeyValueList{Values: Attributes(v)}}
+	default:
+		// Named map and slice types, such as logar.Map
+		rv := reflect.ValueOf(value)
+		switch {
+		case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+			values := make(map[string]any, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				values[iter.Key().String()] = iter.Value().Interface()
+			}
+			return ValueOf(values)
+		case rv.Kind() == reflect.Slice:
+			values := make([]any, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).Interface()
+			}
+			return ValueOf(values)
+		}
+		return String(fmt.Sprint(v))
+	}
+}
+
+// Interface converts the value back to a Go value.
+func (v AnyValue) Interface() any {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.ArrayValue != nil:
+		values := make([]any, len(v.ArrayValue.Values))
+		for i, item := range v.ArrayValue.Values {
+			values[i] = item.Interface()
+		}
+		return values
+	case v.KvlistValue != nil:
+		return AttributeMap(v.KvlistValue.Values)
+	case v.BytesValue != nil:
+		return v.BytesValue
+	}
+	return nil
+}
+
+func Attribute(key string, value any) KeyValue {
+	return KeyValue{Key: key, Value: ValueOf(value)}
+}
+
+// Attributes converts the map to attributes, sorted by key.
+func Attributes(values map[string]any) []KeyValue {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]KeyValue, 0, len(values))
+	for _, k := range keys {
+		attributes = append(attributes, Attribute(k, values[k]))
+	}
+	return attributes
+}
+
+func AttributeMap(attributes []KeyValue) map[string]any {
+	values := make(map[string]any, len(attributes))
+	for _, attr := range attributes {
+		values[attr.Key] = attr.Value.Interface()
+	}
+	return values
+}
+
+// Lookup returns the string form of the attribute with the given key.
+func Lookup(attributes []KeyValue, key string) (string, bool) {
+	for _, attr := range attributes {
+		if attr.Key == key {
+			return fmt.Sprint(attr.Value.Interface()), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+// Package ratelimit spaces out requests to endpoints that limit how often clients may call them,
+// such as chat webhooks.
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxDelay is the longest a limiter waits, so that a long Retry-After doesn't block closing the app.
+const MaxDelay = 30 * time.Second
+
+// Limiter lets requests through at most once every interval, and holds them back after the endpoint
+// asked to slow down. The zero interval only holds requests back when asked to.
+type Limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest time of the next request
+}
+
+func New(interval time.Duration) *Limiter {
+	return &Limiter{interval: interval}
+}
+
+// Wait blocks until a request may be made, and reserves the time for it.
+func (l *Limiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	at := now
+	if l.next.After(now) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Delay holds the next requests back for d, up to MaxDelay.
+func (l *Limiter) Delay(d time.Duration) {
+	d = min(d, MaxDelay)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at := time.Now().Add(d); at.After(l.next) {
+		l.next = at
+	}
+}
+
+// RetryAfter parses the Retry-After header, in seconds or as an HTTP date. Fractional seconds, as
+// sent by Discord, are accepted.
+func RetryAfter(header http.Header) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(min(seconds, MaxDelay.Seconds()) * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,177 @@
+package logar
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"sadk.dev/logar/models"
+)
+
+// Category of the LogarLogs entries reporting issue regressions.
+const issuesCategory = "issues"
+
+type Issues interface {
+	Common
+
+	GetIssues(statuses ...models.IssueStatus) ([]models.Issue, error)
+	GetIssue(id uint) (models.Issue, error)
+	GetIssueByFingerprint(fingerprint string) (models.Issue, error)
+	SetIssueStatus(id uint, status models.IssueStatus) error
+	DeleteIssue(id uint) error
+}
+
+type IssuesImpl struct {
+	core *AppImpl
+	mu   sync.Mutex
+}
+
+func (i *IssuesImpl) GetApp() App {
+	return i.core
+}
+
+// GetIssues returns issues with the given statuses, most recently seen first.
+// If no status is given, all issues except ignored ones are returned.
+func (i *IssuesImpl) GetIssues(statuses ...models.IssueStatus) ([]models.Issue, error) {
+	query := i.core.db.Model(&models.Issue{})
+	if len(statuses) > 0 {
+		query = query.Where("status IN (?)", statuses)
+	} else {
+		query = query.Where("status != ?", models.IssueStatus_Ignored)
+	}
+
+	var issues []models.Issue
+	err := query.Order("last_seen DESC").Find(&issues).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
+
+func (i *IssuesImpl) GetIssue(id uint) (models.Issue, error) {
+	var issue models.Issue
+	err := i.core.db.Where("id = ?", id).First(&issue).Error
+	if err != nil {
+		return models.Issue{}, err
+	}
+
+	return issue, nil
+}
+
+func (i *IssuesImpl) GetIssueByFingerprint(fingerprint string) (models.Issue, error) {
+	var issue models.Issue
+	err := i.core.db.Where("fingerprint = ?", fingerprint).First(&issue).Error
+	if err != nil {
+		return models.Issue{}, err
+	}
+
+	return issue, nil
+}
+
+func (i *IssuesImpl) SetIssueStatus(id uint, status models.IssueStatus) error {
+	if !status.IsValid() {
+		return fmt.Errorf("invalid issue status '%s'", status)
+	}
+
+	updates := map[string]any{
+		"status":      status,
+		"resolved_at": nil,
+	}
+	if status == models.IssueStatus_Resolved {
+		updates["resolved_at"] = time.Now()
+		updates["regression"] = false
+	}
+
+	return i.core.db.Model(&models.Issue{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (i *IssuesImpl) DeleteIssue(id uint) error {
+	return i.core.db.Where("id = ?", id).Delete(&models.Issue{}).Error
+}
+
+// track records the log under its issue, creating the issue if it doesn't exist yet.
+// It returns the updated issue and whether a resolved issue occurred again.
+func (i *IssuesImpl) track(log models.Log, template string) (models.Issue, bool, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	issue, err := i.GetIssueByFingerprint(log.Fingerprint)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		issue = models.Issue{
+			Fingerprint: log.Fingerprint,
+			Model:       log.Model,
+			Category:    log.Category,
+			Severity:    log.Severity,
+			Template:    template,
+			Caller:      log.Caller,
+			FirstSeen:   log.CreatedAt,
+			LastSeen:    log.CreatedAt,
+			Count:       1,
+			LastLogID:   log.ID,
+			Status:      models.IssueStatus_Open,
+		}
+		return issue, false, i.core.db.Create(&issue).Error
+	}
+	if err != nil {
+		return models.Issue{}, false, err
+	}
+
+	regressed := issue.Status == models.IssueStatus_Resolved
+	issue.Count++
+	issue.LastSeen = log.CreatedAt
+	issue.LastLogID = log.ID
+	if log.Severity > issue.Severity {
+		issue.Severity = log.Severity
+	}
+	if regressed {
+		issue.Status = models.IssueStatus_Open
+		issue.Regression = true
+		issue.ResolvedAt = nil
+	}
+
+	return issue, regressed, i.core.db.Save(&issue).Error
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexPattern    = regexp.MustCompile(`\b(0[xX][0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`)
+	numberPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+)
+
+// normalizeMessage replaces variable parts of a message, such as ids and numbers,
+// so messages produced by the same code share the same template.
+func normalizeMessage(message string) string {
+	message = uuidPattern.ReplaceAllString(message, "<uuid>")
+	message = hexPattern.ReplaceAllStringFunc(message, func(s string) string {
+		// plain words and numbers are not hex ids
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") || (strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefABCDEF")) {
+			return "<hex>"
+		}
+		return s
+	})
+	message = numberPattern.ReplaceAllString(message, "<n>")
+	return message
+}
+
+// fingerprint identifies an issue by its model, category, message template and caller.
+// The calling function is used instead of file:line when known, so issues survive unrelated code changes.
+func fingerprint(log models.Log, template string) string {
+	location := log.Function
+	if location == "" {
+		location = log.Caller
+	}
+
+	hash := sha1.New()
+	for _, part := range []string{string(log.Model), log.Category, template, location} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -46,6 +46,12 @@ func ModelContains(substr string) Condition {
 	}
 }
 
+func CallerContains(substr string) Condition {
+	return func(log models.Log) bool {
+		return strings.Contains(log.Caller, substr)
+	}
+}
+
 func IsModel(model models.Model) Condition {
 	return func(log models.Log) bool {
 		return log.Model == model
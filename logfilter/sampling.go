@@ -0,0 +1,106 @@
+package logfilter
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"sadk.dev/logar/models"
+)
+
+// KeyFunc groups logs for stateful conditions such as RateLimit.
+type KeyFunc func(log models.Log) string
+
+func ByModel(log models.Log) string {
+	return string(log.Model)
+}
+
+func ByCategory(log models.Log) string {
+	return log.Category
+}
+
+func ByModelAndCategory(log models.Log) string {
+	return string(log.Model) + "\x00" + log.Category
+}
+
+func ByMessage(log models.Log) string {
+	return string(log.Model) + "\x00" + log.Category + "\x00" + log.Message
+}
+
+// Sample lets through the given fraction of logs, chosen randomly. rate is between 0 and 1.
+func Sample(rate float64) Condition {
+	return func(log models.Log) bool {
+		return rand.Float64() < rate
+	}
+}
+
+// maxKeys bounds the number of keys stateful conditions keep track of.
+const maxKeys = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimit lets through at most perSecond logs per second for each key, allowing bursts of up to burst logs.
+func RateLimit(perSecond float64, burst int, key KeyFunc) Condition {
+	var mu sync.Mutex
+	buckets := map[string]*tokenBucket{}
+
+	return func(log models.Log) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		k := key(log)
+		bucket, ok := buckets[k]
+		if !ok {
+			if len(buckets) >= maxKeys {
+				// Buckets that are full again behave the same as new ones
+				for k, b := range buckets {
+					if b.tokens+now.Sub(b.last).Seconds()*perSecond >= float64(burst) {
+						delete(buckets, k)
+					}
+				}
+			}
+			bucket = &tokenBucket{tokens: float64(burst), last: now}
+			buckets[k] = bucket
+		}
+
+		bucket.tokens += now.Sub(bucket.last).Seconds() * perSecond
+		if bucket.tokens > float64(burst) {
+			bucket.tokens = float64(burst)
+		}
+		bucket.last = now
+
+		if bucket.tokens < 1 {
+			return false
+		}
+		bucket.tokens--
+		return true
+	}
+}
+
+// FirstThenEvery lets through the first n logs of each key, and every m-th log after that.
+// If m is zero, only the first n logs are let through.
+func FirstThenEvery(n, m int, key KeyFunc) Condition {
+	var mu sync.Mutex
+	counts := map[string]int{}
+
+	return func(log models.Log) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		k := key(log)
+		if _, ok := counts[k]; !ok && len(counts) >= maxKeys {
+			clear(counts)
+		}
+		counts[k]++
+		count := counts[k]
+
+		if count <= n {
+			return true
+		}
+		return m > 0 && (count-n)%m == 0
+	}
+}
@@ -0,0 +1,51 @@
+package logfilter
+
+import (
+	"fmt"
+
+	"sadk.dev/logar/models"
+)
+
+// Spec is a filter that can be stored or written in configuration files.
+// All of its set fields must match.
+type Spec struct {
+	MinSeverity     string   `json:"min_severity,omitempty"` // severity name, such as "warn"
+	Models          []string `json:"models,omitempty"`       // any of the models
+	Categories      []string `json:"categories,omitempty"`   // any of the categories
+	MessageContains string   `json:"message_contains,omitempty"`
+}
+
+// Filter builds the filter of the spec. It fails if the spec has an invalid severity.
+func (s Spec) Filter() (Filter, error) {
+	var conditions []Condition
+
+	if s.MinSeverity != "" {
+		severity, ok := models.ParseSeverity(s.MinSeverity)
+		if !ok {
+			return Filter{}, fmt.Errorf("invalid severity %q", s.MinSeverity)
+		}
+		conditions = append(conditions, IsSeverityAtLeast(severity))
+	}
+
+	if len(s.Models) > 0 {
+		modelConditions := make([]Condition, len(s.Models))
+		for i, model := range s.Models {
+			modelConditions[i] = IsModel(models.Model(model))
+		}
+		conditions = append(conditions, Or(modelConditions...))
+	}
+
+	if len(s.Categories) > 0 {
+		categoryConditions := make([]Condition, len(s.Categories))
+		for i, category := range s.Categories {
+			categoryConditions[i] = IsCategory(category)
+		}
+		conditions = append(conditions, Or(categoryConditions...))
+	}
+
+	if s.MessageContains != "" {
+		conditions = append(conditions, MessageContains(s.MessageContains))
+	}
+
+	return NewFilter(conditions...), nil
+}
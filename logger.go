@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
 	"time"
 
 	"sadk.dev/logar/models"
@@ -20,10 +22,41 @@ type Logger interface {
 	Error(model Model, message any, category string) error
 	Fatal(model Model, message any, category string) error
 	Trace(model Model, message any, category string) error
+	// Write writes a log with explicit values, such as one received from another process.
+	// Unlike Print, it doesn't capture the caller or stack trace of the Go code calling it.
+	Write(entry Entry) error
+
+	// Recover recovers from a panic and logs it as Fatal. It must be deferred directly:
+	//
+	//	defer logger.Recover("app", "panic", false)
+	//
+	// If rePanic is true, the panic is resumed after it is logged.
+	Recover(model Model, category string, rePanic bool)
+	// Go runs fn in a new goroutine, logging any panic that escapes it.
+	Go(model Model, category string, rePanic bool, fn func())
+	// RecoverMiddleware returns a net/http middleware that logs panics of the next handler
+	// together with request details. Unless rePanic is true, a 500 response is written instead.
+	RecoverMiddleware(model Model, category string, rePanic bool) func(next http.Handler) http.Handler
 
 	NewTimer() *Timer
 }
 
+// Entry is a log with explicit values. Zero values are filled in the same way as for Print.
+type Entry struct {
+	Model    Model
+	Message  any
+	Category string
+	Severity models.Severity
+
+	Time    time.Time // defaults to now
+	TraceID string    // defaults to the span active in the logger's context
+	SpanID  string
+
+	Caller     string
+	Function   string
+	StackTrace string
+}
+
 type LoggerImpl struct {
 	core *AppImpl
 	ctx  context.Context
@@ -38,6 +71,37 @@ func (l *LoggerImpl) WithContext(ctx context.Context) Logger {
 }
 
 func (l *LoggerImpl) Print(model Model, message any, category string, severity models.Severity) error {
+	return l.print(model, message, category, severity, "")
+}
+
+// print writes the log entry. If stackTrace is empty, it is captured according to CallerConfig.
+func (l *LoggerImpl) print(model Model, message any, category string, severity models.Severity, stackTrace string) error {
+	return l.write(Entry{
+		Model:      model,
+		Message:    message,
+		Category:   category,
+		Severity:   severity,
+		StackTrace: stackTrace,
+	}, true)
+}
+
+func (l *LoggerImpl) Write(entry Entry) error {
+	return l.write(entry, false)
+}
+
+// write runs the log pipeline. If local is set, the entry comes from Go code of this process
+// and caller information is captured according to CallerConfig.
+func (l *LoggerImpl) write(entry Entry, local bool) error {
+	model, message, category, severity := entry.Model, entry.Message, entry.Category, entry.Severity
+
+	// The issue template is built before context values are merged into the message,
+	// so per-request values don't split an issue.
+	trackIssue := l.core.config.IssueTracking && severity >= models.Severity_Error && !(model == LogarLogs && category == issuesCategory)
+	var template string
+	if trackIssue {
+		template = normalizeMessage(encodeMessage(message))
+	}
+
 	var contextualMessage any
 
 	values, ok := l.core.GetContextValues(l.ctx)
@@ -60,44 +124,116 @@ func (l *LoggerImpl) Print(model Model, message any, category string, severity m
 		contextualMessage = message
 	}
 
-	var msg string
+	msg := encodeMessage(contextualMessage)
 
-	switch m := contextualMessage.(type) {
-	case string:
-		msg = m
-	default:
-		var buf bytes.Buffer
-		encoder := json.NewEncoder(&buf)
-		encoder.SetEscapeHTML(false)
-		encoder.Encode(m)
-		msg = buf.String()
+	now := entry.Time
+	if now.IsZero() {
+		now = time.Now()
 	}
-
-	now := time.Now()
 	logEntry := models.Log{
-		CreatedAt: now,
-		Model:     models.Model(model),
-		Message:   msg,
-		Category:  category,
-		Severity:  severity,
+		CreatedAt:  now,
+		Model:      models.Model(model),
+		Message:    msg,
+		Category:   category,
+		Severity:   severity,
+		TraceID:    entry.TraceID,
+		SpanID:     entry.SpanID,
+		Caller:     entry.Caller,
+		Function:   entry.Function,
+		StackTrace: entry.StackTrace,
+	}
+	if span, ok := l.core.tracer.SpanFromContext(l.ctx); ok && logEntry.TraceID == "" {
+		logEntry.TraceID = span.TraceID()
+		logEntry.SpanID = span.SpanID()
+	}
+
+	if !l.core.sampler.allow(logEntry) {
+		return nil
+	}
+
+	callerConfig := l.core.config.CallerConfig
+	if local && callerConfig.Enabled {
+		if frame, ok := callerFrame(callerConfig.Skip); ok {
+			logEntry.Caller = formatCaller(frame)
+			logEntry.Function = frame.Function
+		}
+	}
+	if local && logEntry.StackTrace == "" && callerConfig.StackTraceSeverity != models.Severity_None && severity >= callerConfig.StackTraceSeverity {
+		logEntry.StackTrace = currentStackTrace()
+	}
+	if trackIssue {
+		logEntry.Fingerprint = fingerprint(logEntry, template)
 	}
 
 	if !l.core.config.MainFilter.Evaluate(logEntry) {
 		return nil
 	}
 
-	err := l.core.db.Create(&logEntry).Error
-	if err != nil {
-		return err
+	// Identical logs within the dedup window only bump the repeat count of the stored row
+	duplicate := false
+	if l.core.dedup != nil {
+		logEntry.ID, duplicate = l.core.dedup.reserve(logEntry)
+	}
+
+	if duplicate {
+		err := l.core.incrementRepeat(logEntry.ID, now)
+		if err != nil {
+			return err
+		}
+	} else {
+		logEntry.RepeatCount = 1
+		logEntry.LastSeenAt = now
+		err := l.core.db.Create(&logEntry).Error
+		if l.core.dedup != nil {
+			if err != nil {
+				l.core.dedup.forget(logEntry)
+			} else {
+				l.core.dedup.remember(logEntry)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	notify := !duplicate
+	var issue models.Issue
+	regressed := false
+	if trackIssue {
+		var err error
+		issue, regressed, err = l.core.issues.track(logEntry, template)
+		if err != nil {
+			return err
+		}
+		if issue.Status == models.IssueStatus_Muted || issue.Status == models.IssueStatus_Ignored {
+			notify = false
+		}
+	}
+
+	if notify {
+		l.core.proxies.enqueue(logEntry, msg)
 	}
 
-	for _, p := range l.core.proxies {
-		p.TrySend(logEntry, msg)
+	if regressed {
+		l.core.logger.Error(LogarLogs, fmt.Sprintf("Issue #%d regressed: %s", issue.ID, issue.Template), issuesCategory)
 	}
 
 	return nil
 }
 
+func encodeMessage(message any) string {
+	switch m := message.(type) {
+	case string:
+		return m
+	default:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(m)
+		return buf.String()
+	}
+}
+
 func (l *LoggerImpl) Log(model Model, message any, category string) error {
 	return l.Print(model, message, category, models.Severity_Log)
 }
@@ -123,5 +259,5 @@ func (l *LoggerImpl) Trace(model Model, message any, category string) error {
 }
 
 func (l *LoggerImpl) NewTimer() *Timer {
-	return l.core.NewTimer()
+	return NewTimer(l)
 }
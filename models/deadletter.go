@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"sadk.dev/logar/internal/tableprefix"
+)
+
+// DeadLetter is a log that a proxy failed to deliver, kept so it can be inspected and replayed.
+type DeadLetter struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Proxy    string   `json:"proxy" gorm:"index"` // name of the proxy
+	LogID    uint     `json:"log_id"`
+	Model    Model    `json:"model"`
+	Category string   `json:"category"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"` // raw message given to the target
+	Log      string   `json:"-"`       // JSON encoded log, so that it can be replayed after the log is deleted
+
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"` // error of the last attempt
+}
+
+func (DeadLetter) TableName() string {
+	return tableprefix.Get() + "dead_letters"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/internal/tableprefix"
+)
+
+// IngestToken authenticates remote producers sending logs to the ingest endpoints.
+type IngestToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Name       string    `json:"name"`
+	TokenHash  string    `json:"-" gorm:"not null;unique"` // sha256 of the token, the token itself is only shown once
+	Prefix     string    `json:"prefix"`                   // first characters of the token, to tell tokens apart
+	Models     string    `json:"models"`                   // comma separated allowlist, empty allows every model
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func (IngestToken) TableName() string {
+	return tableprefix.Get() + "ingest_tokens"
+}
+
+func (t IngestToken) AllowedModels() []string {
+	if t.Models == "" {
+		return nil
+	}
+	return strings.Split(t.Models, ",")
+}
+
+func (t IngestToken) AllowsModel(model Model) bool {
+	return t.Models == "" || slices.Contains(t.AllowedModels(), string(model))
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"sadk.dev/logar/internal/tableprefix"
+)
+
+type IssueStatus string
+
+const (
+	IssueStatus_Open     IssueStatus = "open"
+	IssueStatus_Resolved IssueStatus = "resolved"
+	IssueStatus_Ignored  IssueStatus = "ignored" // hidden from the issue list, logs are not sent to proxies
+	IssueStatus_Muted    IssueStatus = "muted"   // still listed, logs are not sent to proxies
+)
+
+// Issue groups Error and Fatal logs sharing the same fingerprint.
+type Issue struct {
+	ID uint `json:"id" gorm:"primarykey"`
+
+	Fingerprint string   `json:"fingerprint" gorm:"not null;unique"`
+	Model       Model    `json:"model"`
+	Category    string   `json:"category"`
+	Severity    Severity `json:"severity"`
+	Template    string   `json:"template"` // normalized message
+	Caller      string   `json:"caller"`
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" gorm:"index"`
+	Count     int64     `json:"count"`
+	LastLogID uint      `json:"last_log_id"`
+
+	Status     IssueStatus `json:"status" gorm:"not null;default:open"`
+	Regression bool        `json:"regression"` // set when a resolved issue occurs again
+	ResolvedAt *time.Time  `json:"resolved_at"`
+}
+
+func (Issue) TableName() string {
+	return tableprefix.Get() + "issues"
+}
+
+func (s IssueStatus) IsValid() bool {
+	switch s {
+	case IssueStatus_Open, IssueStatus_Resolved, IssueStatus_Ignored, IssueStatus_Muted:
+		return true
+	}
+	return false
+}
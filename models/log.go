@@ -1,6 +1,8 @@
 package models
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"sadk.dev/logar/internal/tableprefix"
@@ -13,6 +15,18 @@ type Log struct {
 	Message   string
 	Category  string
 	Severity  Severity
+
+	Caller     string // file:line of the code that produced the log, if caller capture is enabled
+	Function   string // fully qualified function name of the caller
+	StackTrace string // goroutine stack trace, if stack traces are enabled for the severity
+
+	Fingerprint string `gorm:"index"` // groups Error and Fatal logs into issues
+
+	RepeatCount int       `gorm:"not null;default:1"` // number of identical logs merged into this one
+	LastSeenAt  time.Time // time of the last merged log, CreatedAt is the time of the first one
+
+	TraceID string `gorm:"index"` // trace and span that were active when the log was written
+	SpanID  string `gorm:"index"`
 }
 
 func (Log) TableName() string {
@@ -26,6 +40,12 @@ func (l Log) FieldNames() []string {
 		"message",
 		"category",
 		"severity",
+		"caller",
+		"function",
+		"fingerprint",
+		"repeat_count",
+		"trace_id",
+		"span_id",
 	}
 }
 
@@ -57,3 +77,28 @@ func (s *Severity) Clamp() {
 		*s = 0
 	}
 }
+
+// ParseSeverity parses a severity name, such as "warn" or "Error", or its number.
+func ParseSeverity(s string) (Severity, bool) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		severity := Severity(n)
+		return severity, severity > Severity_None && severity < Severity_Max
+	}
+
+	switch strings.ToLower(s) {
+	case "trace":
+		return Severity_Trace, true
+	case "log", "debug":
+		return Severity_Log, true
+	case "info":
+		return Severity_Info, true
+	case "warn", "warning":
+		return Severity_Warning, true
+	case "error":
+		return Severity_Error, true
+	case "fatal":
+		return Severity_Fatal, true
+	}
+	return Severity_None, false
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"sadk.dev/logar/internal/tableprefix"
+)
+
+// ProxyDefinition is a proxy added at runtime. It is stored so that the proxy is created again
+// when the app starts.
+type ProxyDefinition struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name     string `json:"name" gorm:"not null;unique"`
+	Type     string `json:"type"`
+	Enabled  bool   `json:"enabled"`
+	Filter   string `json:"filter"`   // logfilter.Spec as JSON
+	Settings string `json:"settings"` // proxy.Settings as JSON
+}
+
+func (ProxyDefinition) TableName() string {
+	return tableprefix.Get() + "proxy_definitions"
+}
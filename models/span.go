@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"sadk.dev/logar/internal/tableprefix"
+)
+
+type SpanStatus string
+
+const (
+	SpanStatus_Unset SpanStatus = "unset"
+	SpanStatus_Ok    SpanStatus = "ok"
+	SpanStatus_Error SpanStatus = "error"
+)
+
+type SpanKind string
+
+const (
+	SpanKind_Internal SpanKind = "internal"
+	SpanKind_Server   SpanKind = "server"
+	SpanKind_Client   SpanKind = "client"
+)
+
+// Span is a single timed operation of a trace.
+type Span struct {
+	ID uint `json:"-" gorm:"primarykey"`
+
+	TraceID  string `json:"trace_id" gorm:"not null;index"`      // 32 hex characters
+	SpanID   string `json:"span_id" gorm:"not null;uniqueIndex"` // 16 hex characters
+	ParentID string `json:"parent_id" gorm:"index"`              // empty for root spans
+
+	Name       string   `json:"name"`
+	Kind       SpanKind `json:"kind"`
+	Attributes string   `json:"attributes"` // JSON object
+
+	StartTime time.Time     `json:"start_time" gorm:"index"`
+	EndTime   time.Time     `json:"end_time"`
+	Duration  time.Duration `json:"duration"` // Stored as int64 (nanoseconds)
+
+	Status        SpanStatus `json:"status"`
+	StatusMessage string     `json:"status_message"`
+}
+
+func (Span) TableName() string {
+	return tableprefix.Get() + "spans"
+}
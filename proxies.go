@@ -0,0 +1,421 @@
+package logar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var (
+	ErrProxyNotFound  = errors.New("proxy not found")
+	ErrProxyQueueFull = errors.New("proxy queue is full")
+)
+
+// Category of the LogarLogs entries reporting proxy failures.
+const proxiesCategory = "proxies"
+
+// ProxyDeliveryConfig controls how logs are delivered to proxies. Each proxy has its own queue and
+// worker, logs that can't be delivered after MaxAttempts are stored as dead letters.
+type ProxyDeliveryConfig struct {
+	QueueSize      int           // logs waiting per proxy, default 1000. Logs over it become dead letters
+	MaxAttempts    int           // attempts per log, default 5
+	InitialBackoff time.Duration // wait after the first failed attempt, doubled after every other one. Default 1s
+	MaxBackoff     time.Duration // default 1m
+	// UnhealthyAfter is the number of attempts in a row that must fail for the proxy to be reported
+	// unhealthy and logged to LogarLogs. Default 5
+	UnhealthyAfter int
+}
+
+var defaultProxyDeliveryConfig = ProxyDeliveryConfig{
+	QueueSize:      1000,
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	UnhealthyAfter: 5,
+}
+
+// WithProxyDelivery sets the queue size and retries of proxies. Zero values keep the defaults.
+func WithProxyDelivery(delivery ProxyDeliveryConfig) ConfigOpt {
+	return func(cfg *Config) {
+		if delivery.QueueSize > 0 {
+			cfg.ProxyDeliveryConfig.QueueSize = delivery.QueueSize
+		}
+		if delivery.MaxAttempts > 0 {
+			cfg.ProxyDeliveryConfig.MaxAttempts = delivery.MaxAttempts
+		}
+		if delivery.InitialBackoff > 0 {
+			cfg.ProxyDeliveryConfig.InitialBackoff = delivery.InitialBackoff
+		}
+		if delivery.MaxBackoff > 0 {
+			cfg.ProxyDeliveryConfig.MaxBackoff = delivery.MaxBackoff
+		}
+		if delivery.UnhealthyAfter > 0 {
+			cfg.ProxyDeliveryConfig.UnhealthyAfter = delivery.UnhealthyAfter
+		}
+	}
+}
+
+type Proxies interface {
+	Common
+
+	// GetDeadLetters returns the most recent dead letters of the proxy, or of every proxy if proxyName is empty.
+	GetDeadLetters(proxyName string, limit int) ([]models.DeadLetter, error)
+	GetDeadLetter(id uint) (models.DeadLetter, error)
+	// ReplayDeadLetter queues the log of the dead letter again and deletes the dead letter.
+	// If delivery fails again, a new dead letter is stored.
+	ReplayDeadLetter(id uint) error
+	// ReplayDeadLetters replays the dead letters of the proxy, or of every proxy if proxyName is empty,
+	// and returns how many were queued. Dead letters of proxies whose queue is full are kept.
+	ReplayDeadLetters(proxyName string) (int, error)
+	DeleteDeadLetter(id uint) error
+
+	// ListProxies returns the proxies passed to New or declared in the config file, then the ones
+	// added at runtime.
+	ListProxies() []ProxyInfo
+	GetProxy(name string) (ProxyInfo, error)
+	// CreateProxy adds a proxy and stores its definition, so that it is created again when the app
+	// starts. The name is required, the target type must be registered with proxy.RegisterTarget.
+	CreateProxy(definition proxy.Definition) (ProxyInfo, error)
+	// UpdateProxy replaces the type, filter and settings of a proxy added at runtime. Logs queued for
+	// the old target are delivered to it before it is closed. Settings left as proxy.Redacted keep
+	// their stored value.
+	UpdateProxy(name string, definition proxy.Definition) (ProxyInfo, error)
+	// RemoveProxy removes a proxy added at runtime, after delivering the logs queued for it.
+	RemoveProxy(name string) error
+	// SetProxyEnabled sets whether the proxy gets logs. It is stored for proxies added at runtime,
+	// other proxies are enabled again when the app restarts.
+	SetProxyEnabled(name string, enabled bool) error
+
+	// GetProxyMetrics returns the delivery counters, last error and latency of the proxy.
+	GetProxyMetrics(name string) (ProxyMetrics, error)
+	GetAllProxyMetrics() []ProxyMetrics
+}
+
+type ProxiesImpl struct {
+	core *AppImpl
+
+	mu      sync.RWMutex
+	queues  []*proxyQueue
+	started bool
+	// stored definitions that couldn't be built when the app started, by name
+	invalid map[string]invalidDefinition
+}
+
+// proxyQueue holds the logs waiting to be delivered to a proxy.
+type proxyQueue struct {
+	proxy   proxy.Proxy
+	entries chan proxyEntry
+	enabled atomic.Bool
+	metrics *proxyMetrics
+	// batched is whether the target sends logs in batches, see proxy.BatchTarget
+	batched bool
+
+	// definition is set for proxies managed at runtime, which can be updated and removed
+	definition *models.ProxyDefinition
+
+	stop    chan struct{} // closed when the proxy is removed
+	stopped chan struct{} // closed when the worker has delivered the queued logs and returned
+}
+
+type proxyEntry struct {
+	log        models.Log
+	rawMessage string
+}
+
+func (p *ProxiesImpl) GetApp() App {
+	return p.core
+}
+
+func newProxies(core *AppImpl, proxies []proxy.Proxy) (*ProxiesImpl, error) {
+	impl := &ProxiesImpl{core: core, invalid: map[string]invalidDefinition{}}
+
+	names := map[string]bool{}
+	for i, p := range proxies {
+		if p.Name() == "" {
+			p = p.WithName(fmt.Sprintf("proxy-%d", i+1))
+		}
+		if names[p.Name()] {
+			return nil, fmt.Errorf("duplicate proxy name %q", p.Name())
+		}
+		names[p.Name()] = true
+
+		impl.queues = append(impl.queues, impl.newQueue(p, nil))
+	}
+
+	if err := impl.loadDefinitions(); err != nil {
+		return nil, err
+	}
+	impl.registerMetricsAction()
+
+	return impl, nil
+}
+
+func (p *ProxiesImpl) newQueue(target proxy.Proxy, definition *models.ProxyDefinition) *proxyQueue {
+	queue := &proxyQueue{
+		proxy:      target,
+		entries:    make(chan proxyEntry, p.core.config.ProxyDeliveryConfig.QueueSize),
+		definition: definition,
+		metrics:    newProxyMetrics(),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	queue.enabled.Store(definition == nil || definition.Enabled)
+	queue.batched = target.OnBatchResult(func(result proxy.BatchResult) {
+		p.recordBatch(queue, result)
+	})
+	return queue
+}
+
+func (p *ProxiesImpl) start() {
+	p.mu.Lock()
+	p.started = true
+	for _, queue := range p.queues {
+		p.startQueue(queue)
+	}
+	var failures []string
+	for name, invalid := range p.invalid {
+		failures = append(failures, "Failed to create stored proxy "+name+": "+invalid.err.Error())
+	}
+	p.mu.Unlock()
+
+	// Logged after unlocking, as the logs are proxied too
+	for _, failure := range failures {
+		p.core.logger.Error(LogarLogs, failure, proxiesCategory)
+	}
+}
+
+// startQueue runs the worker of the queue. The target is closed when the worker returns, after the
+// queued logs are delivered, so that targets that batch or buffer logs send the remaining ones.
+func (p *ProxiesImpl) startQueue(queue *proxyQueue) {
+	p.core.runWorker(func(done <-chan struct{}) {
+		defer close(queue.stopped)
+		p.runQueue(queue, done)
+
+		if err := queue.proxy.Close(); err != nil {
+			p.core.logger.Error(LogarLogs, "Failed to close proxy "+queue.proxy.Name()+": "+err.Error(), proxiesCategory)
+		}
+	})
+}
+
+// findQueue returns the queue of the proxy. p.mu must be held.
+func (p *ProxiesImpl) findQueue(name string) (*proxyQueue, bool) {
+	index := p.queueIndex(name)
+	if index < 0 {
+		return nil, false
+	}
+	return p.queues[index], true
+}
+
+// enqueue queues the log for every enabled proxy whose filter it passes. It doesn't wait for delivery.
+func (p *ProxiesImpl) enqueue(log models.Log, rawMessage string) {
+	// Held while sending, so that removed proxies don't get logs after their queue is drained
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, queue := range p.queues {
+		if !queue.enabled.Load() || !queue.proxy.Matches(log) {
+			continue
+		}
+		queue.metrics.matched.Add(1)
+
+		select {
+		case queue.entries <- proxyEntry{log: log, rawMessage: rawMessage}:
+		default:
+			p.storeDeadLetter(queue, proxyEntry{log: log, rawMessage: rawMessage}, 0, ErrProxyQueueFull)
+		}
+	}
+}
+
+func (p *ProxiesImpl) runQueue(queue *proxyQueue, done <-chan struct{}) {
+	for {
+		select {
+		case entry := <-queue.entries:
+			p.deliver(queue, entry, done)
+			continue
+		case <-done:
+		case <-queue.stop:
+		}
+
+		// Try the logs that are still queued once, so that none are lost when the app is closed
+		// or the proxy is removed
+		for {
+			select {
+			case entry := <-queue.entries:
+				p.deliver(queue, entry, done)
+			default:
+				return
+			}
+		}
+	}
+}
+
+// deliver sends the entry, retrying with exponential backoff until it is delivered, the attempts
+// run out, or the app is closed or the proxy removed. Undelivered entries are stored as dead letters.
+func (p *ProxiesImpl) deliver(queue *proxyQueue, entry proxyEntry, done <-chan struct{}) {
+	cfg := p.core.config.ProxyDeliveryConfig
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := send(queue.proxy, entry)
+		p.recordAttempt(queue, attempt, time.Since(start), err)
+		if err == nil {
+			return
+		}
+
+		if attempt >= cfg.MaxAttempts {
+			p.storeDeadLetter(queue, entry, attempt, err)
+			return
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			p.storeDeadLetter(queue, entry, attempt, err)
+			return
+		case <-queue.stop:
+			timer.Stop()
+			p.storeDeadLetter(queue, entry, attempt, err)
+			return
+		}
+
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
+
+// send sends the entry to the target, turning a panic of the target into an error.
+func send(p proxy.Proxy, entry proxyEntry) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = fmt.Errorf("panic: %v", value)
+		}
+	}()
+
+	return p.Send(entry.log, entry.rawMessage)
+}
+
+func (p *ProxiesImpl) storeDeadLetter(queue *proxyQueue, entry proxyEntry, attempts int, err error) {
+	queue.metrics.dropped.Add(1)
+
+	data, _ := json.Marshal(entry.log)
+	p.core.db.Create(&models.DeadLetter{
+		Proxy:    queue.proxy.Name(),
+		LogID:    entry.log.ID,
+		Model:    entry.log.Model,
+		Category: entry.log.Category,
+		Severity: entry.log.Severity,
+		Message:  entry.rawMessage,
+		Log:      string(data),
+		Attempts: attempts,
+		Error:    err.Error(),
+	})
+}
+
+func (p *ProxiesImpl) GetDeadLetters(proxyName string, limit int) ([]models.DeadLetter, error) {
+	query := p.core.db.Model(&models.DeadLetter{})
+	if proxyName != "" {
+		query = query.Where("proxy = ?", proxyName)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var deadLetters []models.DeadLetter
+	err := query.Order("id DESC").Find(&deadLetters).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+func (p *ProxiesImpl) GetDeadLetter(id uint) (models.DeadLetter, error) {
+	var deadLetter models.DeadLetter
+	err := p.core.db.Where("id = ?", id).First(&deadLetter).Error
+	if err != nil {
+		return models.DeadLetter{}, err
+	}
+
+	return deadLetter, nil
+}
+
+func (p *ProxiesImpl) ReplayDeadLetter(id uint) error {
+	deadLetter, err := p.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	return p.replay(deadLetter)
+}
+
+func (p *ProxiesImpl) ReplayDeadLetters(proxyName string) (int, error) {
+	deadLetters, err := p.GetDeadLetters(proxyName, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	// Oldest first, so that logs are delivered in the order they were written
+	replayed := 0
+	for i := len(deadLetters) - 1; i >= 0; i-- {
+		err := p.replay(deadLetters[i])
+		if errors.Is(err, ErrProxyQueueFull) {
+			// Kept until the queue has room again
+			continue
+		}
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func (p *ProxiesImpl) replay(deadLetter models.DeadLetter) error {
+	select {
+	case <-p.core.done:
+		return errors.New("app is closed")
+	default:
+	}
+
+	var log models.Log
+	if err := json.Unmarshal([]byte(deadLetter.Log), &log); err != nil {
+		return fmt.Errorf("invalid log of dead letter #%d: %w", deadLetter.ID, err)
+	}
+
+	if err := p.requeue(deadLetter.Proxy, proxyEntry{log: log, rawMessage: deadLetter.Message}); err != nil {
+		return err
+	}
+
+	return p.DeleteDeadLetter(deadLetter.ID)
+}
+
+// requeue queues the entry for the proxy, without waiting for room in the queue.
+func (p *ProxiesImpl) requeue(proxyName string, entry proxyEntry) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	queue, ok := p.findQueue(proxyName)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrProxyNotFound, proxyName)
+	}
+
+	select {
+	case queue.entries <- entry:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrProxyQueueFull, proxyName)
+	}
+}
+
+func (p *ProxiesImpl) DeleteDeadLetter(id uint) error {
+	return p.core.db.Where("id = ?", id).Delete(&models.DeadLetter{}).Error
+}
@@ -1,22 +1,224 @@
+// Package consolelogger prints logs to the terminal, or any writer, colored by severity.
 package consolelogger
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/mattn/go-isatty"
+
 	"sadk.dev/logar/models"
 	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+// ColorMode is whether output is colored with ANSI escape codes.
+type ColorMode string
+
+const (
+	Color_Auto   ColorMode = "auto"   // colored if the writer is a terminal and NO_COLOR isn't set
+	Color_Always ColorMode = "always" // colored, e.g. for pagers that understand colors
+	Color_Never  ColorMode = "never"
 )
 
+// Column is a part of a printed log. The message is always printed, after the columns before it.
+type Column string
+
+const (
+	Column_Time     Column = "time"
+	Column_Severity Column = "severity"
+	Column_Model    Column = "model"
+	Column_Category Column = "category"
+	Column_Fields   Column = "fields" // context values of structured messages, after the message
+	Column_Caller   Column = "caller" // file:line of the log, after the message
+)
+
+// DefaultColumns are the columns printed unless WithColumns is used.
+var DefaultColumns = []Column{Column_Time, Column_Severity, Column_Model, Column_Category, Column_Fields}
+
 type consoleLogger struct {
+	writer     io.Writer
+	colorMode  ColorMode
+	color      bool
+	columns    map[Column]bool
+	timeFormat string
+	prettyJSON bool
+	formatter  format.Formatter
+
+	mu sync.Mutex
 }
 
-func New() proxy.ProxyTarget {
-	return &consoleLogger{}
+func init() {
+	proxy.RegisterTarget("console", newFromSettings)
+}
+
+type Option func(*consoleLogger)
+
+// WithWriter prints to w instead of standard output.
+func WithWriter(w io.Writer) Option {
+	return func(l *consoleLogger) {
+		l.writer = w
+	}
+}
+
+// WithColor sets whether output is colored. Defaults to Color_Auto.
+func WithColor(mode ColorMode) Option {
+	return func(l *consoleLogger) {
+		l.colorMode = mode
+	}
+}
+
+// WithColumns sets the columns that are printed.
+func WithColumns(columns ...Column) Option {
+	return func(l *consoleLogger) {
+		l.columns = map[Column]bool{}
+		for _, column := range columns {
+			l.columns[column] = true
+		}
+	}
+}
+
+// WithTimeFormat sets the layout of the time column. Defaults to time.DateTime.
+func WithTimeFormat(layout string) Option {
+	return func(l *consoleLogger) {
+		l.timeFormat = layout
+	}
+}
+
+// WithPrettyJSON indents messages that are JSON, other than structured messages with a "message" key,
+// on the lines after the log.
+func WithPrettyJSON() Option {
+	return func(l *consoleLogger) {
+		l.prettyJSON = true
+	}
+}
+
+// WithJSON prints every log as a JSON line instead of columns.
+func WithJSON() Option {
+	return WithFormatter(format.JSON)
+}
+
+// WithFormatter prints logs rendered by the formatter instead of columns, every log as a line.
+func WithFormatter(formatter format.Formatter) Option {
+	return func(l *consoleLogger) {
+		l.formatter = formatter
+	}
+}
+
+func New(opts ...Option) proxy.ProxyTarget {
+	l := &consoleLogger{
+		writer:     os.Stdout,
+		colorMode:  Color_Auto,
+		timeFormat: time.DateTime,
+	}
+	WithColumns(DefaultColumns...)(l)
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	switch l.colorMode {
+	case Color_Always:
+		l.color = true
+	case Color_Never:
+		l.color = false
+	default:
+		l.color = isTerminal(l.writer) && os.Getenv("NO_COLOR") == ""
+	}
+
+	return l
+}
+
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
 }
 
 func (l *consoleLogger) Send(log models.Log, rawMesage string) error {
-	fmt.Print("["+strings.ToUpper(log.Severity.String())+"] ", log.CreatedAt.Format(time.DateTime), " ", rawMesage, "\n")
-	return nil
+	var line string
+	if l.formatter != nil {
+		var err error
+		line, err = l.formatter.Format(log, rawMesage)
+		if err != nil {
+			return err
+		}
+	} else {
+		line = l.render(log, rawMesage)
+	}
+
+	// Lines of concurrent logs must not interleave
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := io.WriteString(l.writer, line+"\n")
+	return err
+}
+
+type settings struct {
+	Format     string   `json:"format"`   // text, logfmt, json or markdown instead of columns
+	Template   string   `json:"template"` // text/template, see format.NewTemplate
+	Output     string   `json:"output"`   // stdout or stderr
+	Color      string   `json:"color"`    // auto, always or never
+	Columns    []string `json:"columns"`  // time, severity, model, category, fields and caller
+	TimeFormat string   `json:"time_format"`
+	PrettyJSON bool     `json:"pretty_json"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+
+	var opts []Option
+	if cfg.Format != "" || cfg.Template != "" {
+		formatter, err := format.FromSettings(cfg.Format, cfg.Template, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithFormatter(formatter))
+	}
+
+	switch strings.ToLower(cfg.Output) {
+	case "", "stdout":
+	case "stderr":
+		opts = append(opts, WithWriter(os.Stderr))
+	default:
+		return nil, fmt.Errorf("output: expected stdout or stderr, got %q", cfg.Output)
+	}
+
+	switch mode := ColorMode(strings.ToLower(cfg.Color)); mode {
+	case "":
+	case Color_Auto, Color_Always, Color_Never:
+		opts = append(opts, WithColor(mode))
+	default:
+		return nil, fmt.Errorf("color: expected auto, always or never, got %q", cfg.Color)
+	}
+
+	if cfg.Columns != nil {
+		columns := make([]Column, len(cfg.Columns))
+		for i, name := range cfg.Columns {
+			columns[i] = Column(strings.ToLower(name))
+			switch columns[i] {
+			case Column_Time, Column_Severity, Column_Model, Column_Category, Column_Fields, Column_Caller:
+			default:
+				return nil, fmt.Errorf("columns: unknown column %q", name)
+			}
+		}
+		opts = append(opts, WithColumns(columns...))
+	}
+
+	if cfg.TimeFormat != "" {
+		opts = append(opts, WithTimeFormat(cfg.TimeFormat))
+	}
+	if cfg.PrettyJSON {
+		opts = append(opts, WithPrettyJSON())
+	}
+
+	return New(opts...), nil
 }
@@ -0,0 +1,111 @@
+package consolelogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy/format"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+	ansiGray   = "\x1b[90m"
+	ansiFatal  = "\x1b[1;97;41m" // bold white on red
+)
+
+func severityColor(severity models.Severity) string {
+	switch severity {
+	case models.Severity_Trace, models.Severity_Log:
+		return ansiGray
+	case models.Severity_Info:
+		return ansiBlue
+	case models.Severity_Warning:
+		return ansiYellow
+	case models.Severity_Error:
+		return ansiBold + ansiRed
+	case models.Severity_Fatal:
+		return ansiFatal
+	}
+	return ""
+}
+
+func (l *consoleLogger) paint(color, s string) string {
+	if !l.color || color == "" || s == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// render prints the columns of the log, with the message after the columns before it and
+// the context fields and caller after it.
+func (l *consoleLogger) render(log models.Log, rawMessage string) string {
+	message, fields := format.Split(rawMessage)
+
+	var pretty string
+	if l.prettyJSON && fields == nil {
+		pretty = indentJSON(message)
+		if pretty != "" {
+			message = ""
+		}
+	}
+
+	var parts []string
+	if l.columns[Column_Time] {
+		parts = append(parts, l.paint(ansiDim, log.CreatedAt.Format(l.timeFormat)))
+	}
+	if l.columns[Column_Severity] {
+		name := strings.ToUpper(log.Severity.String())
+		parts = append(parts, l.paint(severityColor(log.Severity), name)+strings.Repeat(" ", max(5-len(name), 0)))
+	}
+
+	var source []string
+	if l.columns[Column_Model] && log.Model != "" {
+		source = append(source, string(log.Model))
+	}
+	if l.columns[Column_Category] && log.Category != "" {
+		source = append(source, log.Category)
+	}
+	if len(source) > 0 {
+		parts = append(parts, l.paint(ansiCyan, strings.Join(source, "/")))
+	}
+
+	if message != "" {
+		parts = append(parts, message)
+	}
+
+	if l.columns[Column_Fields] {
+		for _, field := range fields {
+			parts = append(parts, l.paint(ansiDim, field.Name+"=")+format.LogfmtValue(field.Value))
+		}
+	}
+	if l.columns[Column_Caller] && log.Caller != "" {
+		parts = append(parts, l.paint(ansiDim, "caller="+log.Caller))
+	}
+
+	line := strings.Join(parts, " ")
+	if pretty != "" {
+		line += "\n" + pretty
+	}
+	return line
+}
+
+// indentJSON returns the message indented if it is a JSON object or array, otherwise an empty string.
+func indentJSON(message string) string {
+	if !strings.HasPrefix(message, "{") && !strings.HasPrefix(message, "[") {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(message), "  ", "  "); err != nil {
+		return ""
+	}
+	return "  " + buf.String()
+}
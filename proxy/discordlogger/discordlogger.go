@@ -0,0 +1,275 @@
+// Package discordlogger posts logs to a Discord webhook as embeds colored by severity.
+package discordlogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sadk.dev/logar/internal/ratelimit"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+// Limits of Discord embeds, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	maxTitleLength       = 256
+	maxDescriptionLength = 4096
+	maxFields            = 25
+	maxFieldNameLength   = 256
+	maxFieldValueLength  = 1024
+	maxEmbedLength       = 6000
+)
+
+func init() {
+	proxy.RegisterTarget("discord", newFromSettings, "webhook_url")
+}
+
+type Option func(*discordLogger)
+
+// WithPanelURL links every message to the logs of its model in the web panel served at panelURL,
+// such as "https://example.com/logar".
+func WithPanelURL(panelURL string) Option {
+	return func(l *discordLogger) {
+		l.panelURL = panelURL
+	}
+}
+
+// WithUsername overrides the name the webhook posts as.
+func WithUsername(username string) Option {
+	return func(l *discordLogger) {
+		l.username = username
+	}
+}
+
+// WithAvatarURL overrides the avatar the webhook posts with.
+func WithAvatarURL(avatarURL string) Option {
+	return func(l *discordLogger) {
+		l.avatarURL = avatarURL
+	}
+}
+
+// WithRateLimit sends at most one message every interval. Defaults to 400 milliseconds, as Discord allows about five requests every two seconds per webhook.
+// Messages are held back anyway when the webhook responds with 429, for as long as it asks.
+func WithRateLimit(interval time.Duration) Option {
+	return func(l *discordLogger) {
+		l.rateLimit = interval
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *discordLogger) {
+		l.client = client
+	}
+}
+
+// New creates a target posting every log to the webhook URL.
+func New(webhookURL string, opts ...Option) (*discordLogger, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("discordlogger: invalid webhook url %q", webhookURL)
+	}
+
+	l := &discordLogger{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		rateLimit:  400 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.limiter = ratelimit.New(l.rateLimit)
+
+	return l, nil
+}
+
+type discordLogger struct {
+	webhookURL string
+	panelURL   string
+	username   string
+	avatarURL  string
+	client     *http.Client
+	rateLimit  time.Duration
+	limiter    *ratelimit.Limiter
+}
+
+type message struct {
+	Username  string  `json:"username,omitempty"`
+	AvatarURL string  `json:"avatar_url,omitempty"`
+	Embeds    []embed `json:"embeds"`
+}
+
+type embed struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	URL         string  `json:"url,omitempty"`
+	Color       int     `json:"color"`
+	Timestamp   string  `json:"timestamp"`
+	Fields      []field `json:"fields,omitempty"`
+	Footer      footer  `json:"footer"`
+}
+
+type field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type footer struct {
+	Text string `json:"text"`
+}
+
+func (l *discordLogger) Send(log models.Log, rawMessage string) error {
+	body, err := json.Marshal(l.message(log, rawMessage))
+	if err != nil {
+		return err
+	}
+
+	l.limiter.Wait()
+	resp, err := l.client.Post(l.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("discordlogger: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// The proxy retries the log, after the delay asked by Discord
+		delay, ok := ratelimit.RetryAfter(resp.Header)
+		if !ok {
+			delay = time.Second
+		}
+		l.limiter.Delay(delay)
+	case resp.Header.Get("X-RateLimit-Remaining") == "0":
+		// The bucket of the webhook is empty, wait for it to refill instead of being rejected
+		if seconds, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset-After"), 64); err == nil && seconds > 0 {
+			l.limiter.Delay(time.Duration(min(seconds, ratelimit.MaxDelay.Seconds()) * float64(time.Second)))
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("discordlogger: webhook responded with %s: %s", resp.Status, strings.TrimSpace(string(reason)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (l *discordLogger) message(log models.Log, rawMessage string) message {
+	text, contextFields := format.Split(rawMessage)
+	title := fmt.Sprintf("[%s] %s", strings.ToUpper(log.Severity.String()), log.Model)
+	if log.Category != "" {
+		title += " / " + log.Category
+	}
+
+	fields := []field{
+		{Name: "Model", Value: orDash(string(log.Model)), Inline: true},
+		{Name: "Category", Value: orDash(log.Category), Inline: true},
+	}
+	if log.Caller != "" {
+		fields = append(fields, field{Name: "Caller", Value: log.Caller, Inline: true})
+	}
+	if log.TraceID != "" {
+		fields = append(fields, field{Name: "Trace", Value: log.TraceID, Inline: true})
+	}
+	for _, f := range contextFields {
+		fields = append(fields, field{
+			Name:   format.Truncate(orDash(f.Name), maxFieldNameLength),
+			Value:  format.Truncate(orDash(f.Value), maxFieldValueLength),
+			Inline: len(f.Value) <= 40,
+		})
+	}
+
+	e := embed{
+		Title:       format.Truncate(title, maxTitleLength),
+		Description: format.Truncate(orDash(text), maxDescriptionLength),
+		URL:         format.LogsURL(l.panelURL, log.Model),
+		Color:       format.Color(log.Severity),
+		Timestamp:   log.CreatedAt.UTC().Format(time.RFC3339),
+		Fields:      fields,
+		Footer:      footer{Text: "logar"},
+	}
+	fit(&e)
+
+	return message{
+		Username:  l.username,
+		AvatarURL: l.avatarURL,
+		Embeds:    []embed{e},
+	}
+}
+
+// fit drops fields and shortens the description until the embed is within the limits of Discord.
+func fit(e *embed) {
+	if len(e.Fields) > maxFields {
+		e.Fields = e.Fields[:maxFields]
+	}
+
+	length := func() int {
+		n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description) + utf8.RuneCountInString(e.Footer.Text)
+		for _, f := range e.Fields {
+			n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+		}
+		return n
+	}
+
+	// Model and category are kept
+	for length() > maxEmbedLength && len(e.Fields) > 2 {
+		e.Fields = e.Fields[:len(e.Fields)-1]
+	}
+	if over := length() - maxEmbedLength; over > 0 {
+		e.Description = format.Truncate(e.Description, utf8.RuneCountInString(e.Description)-over)
+	}
+}
+
+// Discord rejects embeds with empty field names and values
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+type settings struct {
+	WebhookURL string `json:"webhook_url"`
+	PanelURL   string `json:"panel_url"`
+	RateLimit  string `json:"rate_limit"` // such as "1s", "0" only waits when the webhook responds with 429
+	Username   string `json:"username"`
+	AvatarURL  string `json:"avatar_url"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookURL == "" {
+		return nil, errors.New("webhook_url is required")
+	}
+
+	opts := []Option{
+		WithPanelURL(cfg.PanelURL),
+		WithUsername(cfg.Username),
+		WithAvatarURL(cfg.AvatarURL),
+	}
+	if cfg.RateLimit != "" {
+		interval, err := time.ParseDuration(cfg.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit: %w", err)
+		}
+		opts = append(opts, WithRateLimit(interval))
+	}
+
+	target, err := New(cfg.WebhookURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,259 @@
+package discordlogger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var testLog = models.Log{
+	CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)),
+	Model:     "payments",
+	Category:  "checkout",
+	Severity:  models.Severity_Warning,
+	Caller:    "main.go:12",
+}
+
+// webhook is a Discord webhook recording the messages it receives. Requests are answered by the
+// functions of responses in turn, and with 204 once they run out.
+type webhook struct {
+	t         *testing.T
+	mu        sync.Mutex
+	messages  []message
+	times     []time.Time
+	responses []func(w http.ResponseWriter)
+}
+
+func newWebhook(t *testing.T, responses ...func(w http.ResponseWriter)) (*webhook, string) {
+	t.Helper()
+	h := &webhook{t: t, responses: responses}
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return h, server.URL
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		h.t.Errorf("invalid body: %v", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, msg)
+	h.times = append(h.times, time.Now())
+	if len(h.responses) > 0 {
+		respond := h.responses[0]
+		h.responses = h.responses[1:]
+		respond(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestSendPayload(t *testing.T) {
+	hook, url := newWebhook(t)
+	target, err := New(url, WithPanelURL("https://example.com/logar"), WithUsername("logar"), WithAvatarURL("https://example.com/logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, `{"message":"slow checkout","duration":"3s","empty":""}`); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(hook.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(hook.messages))
+	}
+	msg := hook.messages[0]
+	if msg.Username != "logar" || msg.AvatarURL != "https://example.com/logo.png" {
+		t.Errorf("username, avatar = %q, %q", msg.Username, msg.AvatarURL)
+	}
+	if len(msg.Embeds) != 1 {
+		t.Fatalf("got %d embeds, want 1", len(msg.Embeds))
+	}
+
+	e := msg.Embeds[0]
+	if e.Title != "[WARN] payments / checkout" {
+		t.Errorf("title = %q", e.Title)
+	}
+	if e.Description != "slow checkout" {
+		t.Errorf("description = %q", e.Description)
+	}
+	if e.URL != "https://example.com/logar/logs?model=payments" {
+		t.Errorf("url = %q", e.URL)
+	}
+	if e.Color != 0xff9800 {
+		t.Errorf("color = %#x, want the color of warnings", e.Color)
+	}
+	if e.Timestamp != "2024-01-02T02:04:05Z" {
+		t.Errorf("timestamp = %q, want it in UTC", e.Timestamp)
+	}
+	if e.Footer.Text != "logar" {
+		t.Errorf("footer = %q", e.Footer.Text)
+	}
+
+	want := []field{
+		{Name: "Model", Value: "payments", Inline: true},
+		{Name: "Category", Value: "checkout", Inline: true},
+		{Name: "Caller", Value: "main.go:12", Inline: true},
+		{Name: "duration", Value: "3s", Inline: true},
+		{Name: "empty", Value: "-", Inline: true}, // Discord rejects empty values
+	}
+	if len(e.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %+v", e.Fields, want)
+	}
+	for i := range want {
+		if e.Fields[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, e.Fields[i], want[i])
+		}
+	}
+}
+
+func TestSendLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields int
+		value  int // length of field values
+	}{
+		{"too many fields", 40, 10},
+		{"embed too long", 10, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, url := newWebhook(t)
+			target, err := New(url, WithRateLimit(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values := map[string]string{"message": strings.Repeat("é", 5000), "": "empty name"}
+			for i := 0; i < tt.fields; i++ {
+				values[strings.Repeat("k", i+1)] = strings.Repeat("v", tt.value)
+			}
+			values[strings.Repeat("n", 300)] = strings.Repeat("v", 2000)
+			raw, _ := json.Marshal(values)
+			if err := target.Send(testLog, string(raw)); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			e := hook.messages[0].Embeds[0]
+			if n := utf8.RuneCountInString(e.Description); n > maxDescriptionLength {
+				t.Errorf("description has %d characters, want at most %d", n, maxDescriptionLength)
+			}
+			if len(e.Fields) > maxFields {
+				t.Errorf("got %d fields, want at most %d", len(e.Fields), maxFields)
+			}
+			if n := utf8.RuneCountInString(e.Title); n > maxTitleLength {
+				t.Errorf("title has %d characters, want at most %d", n, maxTitleLength)
+			}
+			for _, f := range e.Fields {
+				name, value := utf8.RuneCountInString(f.Name), utf8.RuneCountInString(f.Value)
+				if name == 0 || name > maxFieldNameLength || value == 0 || value > maxFieldValueLength {
+					t.Errorf("field has a name of %d and a value of %d characters, want 1 to %d and 1 to %d", name, value, maxFieldNameLength, maxFieldValueLength)
+				}
+			}
+			if len(e.Fields) < 2 || e.Fields[0].Name != "Model" || e.Fields[1].Name != "Category" {
+				t.Errorf("fields = %+v, want model and category kept", e.Fields)
+			}
+
+			length := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description) + utf8.RuneCountInString(e.Footer.Text)
+			for _, f := range e.Fields {
+				length += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+			}
+			if length > maxEmbedLength {
+				t.Errorf("embed has %d characters, want at most %d", length, maxEmbedLength)
+			}
+		})
+	}
+}
+
+func TestSendRateLimit(t *testing.T) {
+	hook, url := newWebhook(t)
+	target, err := New(url, WithRateLimit(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := target.Send(testLog, "message"); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	for i := 1; i < len(hook.times); i++ {
+		if gap := hook.times[i].Sub(hook.times[i-1]); gap < 90*time.Millisecond {
+			t.Errorf("message %d was sent %v after the previous one, want at least the rate limit", i, gap)
+		}
+	}
+}
+
+func TestSendRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		wantErr bool
+	}{
+		{
+			name: "retry after",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "0.5")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.5, "global": false}`))
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty bucket",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset-After", "0.5")
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, url := newWebhook(t, tt.respond)
+			target, err := New(url, WithRateLimit(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := target.Send(testLog, "message"); (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err := target.Send(testLog, "message"); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			if gap := hook.times[1].Sub(hook.times[0]); gap < 450*time.Millisecond {
+				t.Errorf("next message was sent %v after the first one, want the delay asked by Discord", gap)
+			}
+		})
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	if _, err := proxy.NewTarget("discord", proxy.Settings{"webhook_url": "discord.com/api/webhooks/1"}); err == nil {
+		t.Error("NewTarget() error = nil, want an error for a url without scheme")
+	}
+
+	target, err := proxy.NewTarget("discord", proxy.Settings{"webhook_url": "https://discord.com/api/webhooks/1", "rate_limit": "0"})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	if got := target.(*discordLogger).rateLimit; got != 0 {
+		t.Errorf("rate limit = %v, want 0", got)
+	}
+}
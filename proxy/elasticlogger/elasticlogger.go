@@ -0,0 +1,467 @@
+// Package elasticlogger indexes logs into Elasticsearch in batches through the _bulk API.
+package elasticlogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/internal/batch"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+const bulkPath = "/_bulk"
+
+var ErrQueueFull = errors.New("elasticlogger: queue is full, log dropped")
+
+func init() {
+	proxy.RegisterTarget("elasticsearch", newFromSettings, "password", "api_key", "headers")
+}
+
+type Option func(*elasticLogger)
+
+// WithIndex sets the index logs are written to. A Go time layout in braces is replaced with the time
+// of the log in UTC, the default "logar-{2006.01.02}" writes to a new index every day.
+func WithIndex(index string) Option {
+	return func(l *elasticLogger) {
+		l.index = index
+	}
+}
+
+// WithBasicAuth authenticates every request with a username and password.
+func WithBasicAuth(username, password string) Option {
+	return func(l *elasticLogger) {
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		l.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// WithAPIKey authenticates every request with an encoded API key.
+func WithAPIKey(apiKey string) Option {
+	return func(l *elasticLogger) {
+		l.header.Set("Authorization", "ApiKey "+apiKey)
+	}
+}
+
+// WithHeader adds a header to every bulk request.
+func WithHeader(key, value string) Option {
+	return func(l *elasticLogger) {
+		l.header.Set(key, value)
+	}
+}
+
+// WithGzip compresses bulk requests.
+func WithGzip() Option {
+	return func(l *elasticLogger) {
+		l.gzip = true
+	}
+}
+
+// WithBatchSize sets how many logs are indexed in a single request. Defaults to 100.
+func WithBatchSize(size int) Option {
+	return func(l *elasticLogger) {
+		l.batch.BatchSize = size
+	}
+}
+
+// WithFlushInterval sets how often queued logs are indexed, even if the batch isn't full. Defaults to 5 seconds.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(l *elasticLogger) {
+		l.batch.FlushInterval = interval
+	}
+}
+
+// WithMaxQueueSize sets how many logs can wait to be indexed. Logs sent to a full queue are dropped. Defaults to 10000.
+func WithMaxQueueSize(size int) Option {
+	return func(l *elasticLogger) {
+		l.batch.MaxQueueSize = size
+	}
+}
+
+// WithRetry sets how many times a failed request, or the documents rejected because Elasticsearch was
+// overloaded, are retried and the delay before the first retry, which doubles after every attempt.
+// Defaults to 5 retries starting at 500ms.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(l *elasticLogger) {
+		l.maxRetries = max(maxRetries, 0)
+		if backoff > 0 {
+			l.backoff = backoff
+		}
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *elasticLogger) {
+		l.client = client
+	}
+}
+
+// New creates a target indexing logs into the Elasticsearch cluster at endpoint, such as "http://localhost:9200".
+// Logs are indexed in the background, Close indexes the remaining ones. Proxies close their target when the app is closed.
+func New(endpoint string, opts ...Option) (*elasticLogger, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("elasticlogger: invalid endpoint %q", endpoint)
+	}
+
+	l := &elasticLogger{
+		index:      "logar-{2006.01.02}",
+		header:     http.Header{},
+		maxRetries: 5,
+		backoff:    500 * time.Millisecond,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := validateIndex(l.index); err != nil {
+		return nil, fmt.Errorf("elasticlogger: %w", err)
+	}
+
+	l.queue = batch.NewQueue(l.batch, l.Index)
+	l.poster = &batch.Poster{
+		URL:         strings.TrimSuffix(endpoint, "/") + bulkPath,
+		ContentType: "application/x-ndjson",
+		Header:      l.header,
+		Gzip:        l.gzip,
+		MaxRetries:  l.maxRetries,
+		Backoff:     l.backoff,
+		Client:      l.client,
+		Done:        l.queue.Done(),
+	}
+
+	return l, nil
+}
+
+type elasticLogger struct {
+	index      string
+	header     http.Header
+	gzip       bool
+	batch      batch.Config
+	maxRetries int
+	backoff    time.Duration
+	client     *http.Client
+
+	queue  *batch.Queue[Entry]
+	poster *batch.Poster
+}
+
+// Entry is a log waiting to be indexed.
+type Entry = proxy.Entry
+
+func (l *elasticLogger) Send(log models.Log, rawMessage string) error {
+	if !l.queue.Add(Entry{Log: log, RawMessage: rawMessage}) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// OnResult passes the result of every bulk request made from the queue to fn.
+func (l *elasticLogger) OnResult(fn func(proxy.BatchResult)) {
+	l.queue.OnResult(func(entries []Entry, err error, elapsed time.Duration) {
+		result := proxy.BatchResult{Entries: entries, Err: err, Latency: elapsed}
+		var bulkErr *BulkError
+		if errors.As(err, &bulkErr) {
+			result.Failed = bulkErr.Failed
+		} else if err != nil {
+			result.Failed = entries
+		}
+		fn(result)
+	})
+}
+
+// Flush indexes all queued logs and waits for the requests to finish.
+func (l *elasticLogger) Flush() error {
+	return l.queue.Flush()
+}
+
+// Close stops the background worker and indexes the remaining logs.
+func (l *elasticLogger) Close() error {
+	return l.queue.Close()
+}
+
+// BulkError is returned by Index when some documents weren't indexed.
+type BulkError struct {
+	Failed []Entry // the logs that weren't indexed
+	Err    error
+}
+
+func (e *BulkError) Error() string {
+	return "elasticlogger: " + e.Err.Error()
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+// Index indexes the logs in a single bulk request, bypassing the queue. Documents rejected because
+// the cluster is overloaded are retried, other rejected documents are reported in a *BulkError.
+func (l *elasticLogger) Index(entries []Entry) error {
+	type operation struct {
+		entry Entry
+		data  []byte
+	}
+
+	pending := make([]operation, 0, len(entries))
+	for _, entry := range entries {
+		data, err := l.operation(entry)
+		if err != nil {
+			return fmt.Errorf("elasticlogger: %w", err)
+		}
+		pending = append(pending, operation{entry: entry, data: data})
+	}
+
+	var rejected []error
+	var failed []Entry
+	err := l.poster.Retry(func() (bool, error) {
+		body := make([]byte, 0, len(pending)*256)
+		for _, op := range pending {
+			body = append(body, op.data...)
+		}
+		response, retry, err := l.poster.Try(body)
+		if err != nil {
+			return retry, err
+		}
+
+		var result bulkResponse
+		if err := json.Unmarshal(response, &result); err != nil {
+			return false, fmt.Errorf("invalid bulk response: %w", err)
+		}
+		if !result.Errors && len(result.Items) >= len(pending) {
+			pending = nil
+			return false, nil
+		}
+
+		var retryable []operation
+		for i, item := range result.Items {
+			if i >= len(pending) {
+				break
+			}
+			for _, status := range item {
+				if status.Status >= 200 && status.Status < 300 {
+					continue
+				}
+				if batch.RetryableStatus(status.Status) {
+					retryable = append(retryable, pending[i])
+				} else {
+					rejected = append(rejected, status.err())
+					failed = append(failed, pending[i].entry)
+				}
+			}
+		}
+		if missing := len(pending) - len(result.Items); missing > 0 {
+			// Documents without a status can't be known to be indexed
+			rejected = append(rejected, fmt.Errorf("bulk response has no status for %d documents", missing))
+			for _, op := range pending[len(result.Items):] {
+				failed = append(failed, op.entry)
+			}
+		}
+		pending = retryable
+		if len(retryable) == 0 {
+			return false, nil
+		}
+		return true, fmt.Errorf("%d documents rejected by an overloaded cluster", len(retryable))
+	})
+
+	if err != nil {
+		// The documents of the last attempt weren't indexed
+		rejected = append(rejected, err)
+		for _, op := range pending {
+			failed = append(failed, op.entry)
+		}
+	}
+	if len(rejected) > 0 {
+		return &BulkError{Failed: failed, Err: errors.Join(rejected...)}
+	}
+	return nil
+}
+
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemStatus `json:"items"` // keyed by the operation, "create"
+}
+
+type bulkItemStatus struct {
+	Index  string `json:"_index"`
+	Status int    `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+func (s bulkItemStatus) err() error {
+	return fmt.Errorf("document rejected by index %s with status %d: %s: %s", s.Index, s.Status, s.Error.Type, s.Error.Reason)
+}
+
+type document struct {
+	Timestamp   string                     `json:"@timestamp"`
+	Message     string                     `json:"message"`
+	Level       string                     `json:"level"`
+	Severity    models.Severity            `json:"severity"`
+	Model       models.Model               `json:"model"`
+	Category    string                     `json:"category"`
+	LogID       uint                       `json:"log_id,omitempty"`
+	Caller      string                     `json:"caller,omitempty"`
+	Function    string                     `json:"function,omitempty"`
+	StackTrace  string                     `json:"stack_trace,omitempty"`
+	Fingerprint string                     `json:"fingerprint,omitempty"`
+	TraceID     string                     `json:"trace_id,omitempty"`
+	SpanID      string                     `json:"span_id,omitempty"`
+	Fields      map[string]json.RawMessage `json:"fields,omitempty"`
+}
+
+// operation returns the create action and the document of the log as two NDJSON lines.
+// create is used instead of index, so that data streams can be written to as well.
+func (l *elasticLogger) operation(entry Entry) ([]byte, error) {
+	log := entry.Log
+	timestamp := log.CreatedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	doc := document{
+		Timestamp:   timestamp.UTC().Format(time.RFC3339Nano),
+		Message:     strings.TrimSpace(entry.RawMessage),
+		Level:       strings.ToLower(log.Severity.String()),
+		Severity:    log.Severity,
+		Model:       log.Model,
+		Category:    log.Category,
+		LogID:       log.ID,
+		Caller:      log.Caller,
+		Function:    log.Function,
+		StackTrace:  log.StackTrace,
+		Fingerprint: log.Fingerprint,
+		TraceID:     log.TraceID,
+		SpanID:      log.SpanID,
+	}
+
+	// Context values of structured messages keep their JSON types
+	var values map[string]json.RawMessage
+	if json.Unmarshal([]byte(entry.RawMessage), &values) == nil {
+		if message, ok := values["message"]; ok {
+			delete(values, "message")
+			doc.Message = string(message)
+			json.Unmarshal(message, &doc.Message)
+			if len(values) > 0 {
+				doc.Fields = values
+			}
+		}
+	}
+
+	action := map[string]map[string]string{
+		"create": {"_index": indexName(l.index, timestamp)},
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if err := encoder.Encode(action); err != nil {
+		return nil, err
+	}
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// indexName replaces the time layouts in braces of the index with t.
+func indexName(index string, t time.Time) string {
+	var name strings.Builder
+	for {
+		start := strings.IndexByte(index, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(index[start:], '}')
+		if end < 0 {
+			break
+		}
+		name.WriteString(index[:start])
+		name.WriteString(t.UTC().Format(index[start+1 : start+end]))
+		index = index[start+end+1:]
+	}
+	name.WriteString(index)
+	return name.String()
+}
+
+func validateIndex(index string) error {
+	if index == "" {
+		return errors.New("empty index")
+	}
+	name := indexName(index, time.Now())
+	if name != strings.ToLower(name) {
+		return fmt.Errorf("index %q must be lowercase", name)
+	}
+	if strings.ContainsAny(name, `\/*?"<>| ,#:`) {
+		return fmt.Errorf("index %q contains invalid characters", name)
+	}
+	return nil
+}
+
+type settings struct {
+	Endpoint      string            `json:"endpoint"`
+	Index         string            `json:"index"` // such as "logs-{2006.01}"
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	APIKey        string            `json:"api_key"`
+	Headers       map[string]string `json:"headers"`
+	Gzip          bool              `json:"gzip"`
+	BatchSize     int               `json:"batch_size"`
+	FlushInterval string            `json:"flush_interval"` // such as "5s"
+	MaxQueueSize  int               `json:"max_queue_size"`
+	MaxRetries    *int              `json:"max_retries"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+
+	opts := []Option{
+		WithBatchSize(cfg.BatchSize),
+		WithMaxQueueSize(cfg.MaxQueueSize),
+	}
+	if cfg.Index != "" {
+		opts = append(opts, WithIndex(cfg.Index))
+	}
+	if cfg.Username != "" {
+		opts = append(opts, WithBasicAuth(cfg.Username, cfg.Password))
+	}
+	if cfg.APIKey != "" {
+		opts = append(opts, WithAPIKey(cfg.APIKey))
+	}
+	for key, value := range cfg.Headers {
+		opts = append(opts, WithHeader(key, value))
+	}
+	if cfg.Gzip {
+		opts = append(opts, WithGzip())
+	}
+	if cfg.FlushInterval != "" {
+		interval, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("flush_interval: %w", err)
+		}
+		opts = append(opts, WithFlushInterval(interval))
+	}
+	if cfg.MaxRetries != nil {
+		opts = append(opts, WithRetry(*cfg.MaxRetries, 0))
+	}
+
+	target, err := New(cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
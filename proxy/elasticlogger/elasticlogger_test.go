@@ -0,0 +1,360 @@
+package elasticlogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// cluster is an Elasticsearch _bulk endpoint recording the requests it receives. The statuses of
+// the documents of a request are returned by respond, all documents are created by default.
+type cluster struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests [][]string // the messages of the documents of every request
+	respond  func(request int, messages []string) []int
+}
+
+func newCluster(t *testing.T, respond func(request int, messages []string) []int) (*cluster, string) {
+	t.Helper()
+	c := &cluster{t: t, respond: respond}
+	ts := httptest.NewServer(c)
+	t.Cleanup(ts.Close)
+	return c, ts.URL
+}
+
+func (c *cluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != bulkPath {
+		c.t.Errorf("path = %q, want %q", r.URL.Path, bulkPath)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/x-ndjson" {
+		c.t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+	}
+
+	var messages []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || action["create"] == nil {
+			c.t.Errorf("action line = %s, want a create action", scanner.Text())
+		}
+		if !scanner.Scan() {
+			c.t.Error("action line without a document")
+			break
+		}
+		var doc document
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			c.t.Errorf("invalid document %s: %v", scanner.Text(), err)
+		}
+		messages = append(messages, doc.Message)
+	}
+
+	c.mu.Lock()
+	request := len(c.requests)
+	c.requests = append(c.requests, messages)
+	c.mu.Unlock()
+
+	statuses := make([]int, len(messages))
+	for i := range statuses {
+		statuses[i] = http.StatusCreated
+	}
+	if c.respond != nil {
+		statuses = c.respond(request, messages)
+	}
+
+	var resp bulkResponse
+	for _, status := range statuses {
+		item := bulkItemStatus{Index: "logs", Status: status}
+		if status >= 300 {
+			resp.Errors = true
+			item.Error.Type = "mapper_parsing_exception"
+			item.Error.Reason = "failed to parse"
+		}
+		resp.Items = append(resp.Items, map[string]bulkItemStatus{"create": item})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newTarget returns a target indexing only when flushed.
+func newTarget(t *testing.T, url string, opts ...Option) *elasticLogger {
+	t.Helper()
+	opts = append([]Option{WithFlushInterval(time.Hour), WithRetry(2, time.Millisecond)}, opts...)
+	target, err := New(url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { target.Close() })
+	return target
+}
+
+func TestOperation(t *testing.T) {
+	target := newTarget(t, "http://localhost:9200", WithIndex("logs-{2006.01}"))
+
+	data, err := target.operation(Entry{
+		Log: models.Log{
+			ID:        7,
+			Model:     "payments",
+			Category:  "checkout",
+			Severity:  models.Severity_Error,
+			CreatedAt: start.In(time.FixedZone("CET", 3600)),
+			TraceID:   "abc",
+		},
+		RawMessage: `{"message":"declined","order":42,"card":{"last4":"4242"}}`,
+	})
+	if err != nil {
+		t.Fatalf("operation() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want an action and a document: %s", len(lines), data)
+	}
+	if want := `{"create":{"_index":"logs-2024.01"}}`; lines[0] != want {
+		t.Errorf("action = %s, want %s", lines[0], want)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"@timestamp": "2024-01-02T03:04:05Z",
+		"message":    "declined",
+		"level":      "error",
+		"model":      "payments",
+		"category":   "checkout",
+		"log_id":     float64(7),
+		"trace_id":   "abc",
+	}
+	for key, value := range want {
+		if doc[key] != value {
+			t.Errorf("%s = %v, want %v", key, doc[key], value)
+		}
+	}
+	// Context values keep their JSON types
+	fields, _ := doc["fields"].(map[string]any)
+	if fields["order"] != float64(42) || fields["card"].(map[string]any)["last4"] != "4242" {
+		t.Errorf("fields = %v", doc["fields"])
+	}
+	if _, ok := fields["message"]; ok {
+		t.Error("the message is repeated in the fields")
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	tests := []struct {
+		index string
+		want  string
+	}{
+		{"logs", "logs"},
+		{"logar-{2006.01.02}", "logar-2024.01.02"},
+		{"logs-{2006}-x-{01}", "logs-2024-x-01"},
+		{"logs-{2006", "logs-{2006"},
+	}
+	for _, tt := range tests {
+		if got := indexName(tt.index, start); got != tt.want {
+			t.Errorf("indexName(%q) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+
+	for _, index := range []string{"", "Logs", "logs/{2006}", "logs {2006}"} {
+		if _, err := New("http://localhost:9200", WithIndex(index)); err == nil {
+			t.Errorf("New() with index %q error = nil, want an error", index)
+		}
+	}
+}
+
+func TestIndexPartialFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		respond     func(request int, messages []string) []int
+		wantIndexed [][]string // the messages of every request
+		wantFailed  []string
+	}{
+		{
+			name:        "created",
+			wantIndexed: [][]string{{"a", "b", "c"}},
+		},
+		{
+			name: "rejected documents",
+			respond: func(request int, messages []string) []int {
+				return []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated}
+			},
+			wantIndexed: [][]string{{"a", "b", "c"}},
+			wantFailed:  []string{"b"},
+		},
+		{
+			name: "overloaded cluster",
+			respond: func(request int, messages []string) []int {
+				if request == 0 {
+					return []int{http.StatusTooManyRequests, http.StatusCreated, http.StatusBadRequest}
+				}
+				return []int{http.StatusCreated}
+			},
+			// Only the documents rejected by the overloaded cluster are retried
+			wantIndexed: [][]string{{"a", "b", "c"}, {"a"}},
+			wantFailed:  []string{"c"},
+		},
+		{
+			name: "missing statuses",
+			respond: func(request int, messages []string) []int {
+				return []int{http.StatusCreated, http.StatusBadRequest}
+			},
+			// Documents without a status aren't known to be indexed
+			wantIndexed: [][]string{{"a", "b", "c"}},
+			wantFailed:  []string{"b", "c"},
+		},
+		{
+			name: "missing statuses without errors",
+			respond: func(request int, messages []string) []int {
+				return []int{http.StatusCreated}
+			},
+			wantIndexed: [][]string{{"a", "b", "c"}},
+			wantFailed:  []string{"b", "c"},
+		},
+		{
+			name: "retries run out",
+			respond: func(request int, messages []string) []int {
+				statuses := make([]int, len(messages))
+				for i := range statuses {
+					statuses[i] = http.StatusTooManyRequests
+				}
+				if request == 0 {
+					statuses[1] = http.StatusCreated
+				}
+				return statuses
+			},
+			wantIndexed: [][]string{{"a", "b", "c"}, {"a", "c"}, {"a", "c"}},
+			wantFailed:  []string{"a", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, url := newCluster(t, tt.respond)
+			target := newTarget(t, url)
+
+			var results []proxy.BatchResult
+			target.OnResult(func(result proxy.BatchResult) {
+				results = append(results, result)
+			})
+
+			for _, message := range []string{"a", "b", "c"} {
+				if err := target.Send(models.Log{Model: "app", CreatedAt: start}, message); err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+			err := target.Flush()
+
+			var bulkErr *BulkError
+			if len(tt.wantFailed) == 0 {
+				if err != nil {
+					t.Errorf("Flush() error = %v", err)
+				}
+			} else if !errors.As(err, &bulkErr) {
+				t.Errorf("Flush() error = %v, want a *BulkError", err)
+			}
+
+			if got, want := joinRequests(c.requests), joinRequests(tt.wantIndexed); got != want {
+				t.Errorf("requests = %s, want %s", got, want)
+			}
+
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			result := results[0]
+			if len(result.Entries) != 3 {
+				t.Errorf("got %d entries, want 3", len(result.Entries))
+			}
+			var failed []string
+			for _, entry := range result.Failed {
+				failed = append(failed, entry.RawMessage)
+			}
+			if strings.Join(failed, ",") != strings.Join(tt.wantFailed, ",") {
+				t.Errorf("failed = %q, want %q", failed, tt.wantFailed)
+			}
+			if bulkErr != nil && len(bulkErr.Failed) != len(tt.wantFailed) {
+				t.Errorf("BulkError has %d failed entries, want %d", len(bulkErr.Failed), len(tt.wantFailed))
+			}
+		})
+	}
+}
+
+func joinRequests(requests [][]string) string {
+	parts := make([]string, len(requests))
+	for i, messages := range requests {
+		parts[i] = "[" + strings.Join(messages, ",") + "]"
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestIndexRequestFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "security_exception", http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	target := newTarget(t, ts.URL)
+
+	var results []proxy.BatchResult
+	target.OnResult(func(result proxy.BatchResult) {
+		results = append(results, result)
+	})
+	for i := 0; i < 2; i++ {
+		target.Send(models.Log{Model: "app"}, "message")
+	}
+
+	if err := target.Flush(); err == nil || !strings.Contains(err.Error(), "security_exception") {
+		t.Errorf("Flush() error = %v, want the reason of the response", err)
+	}
+	if len(results) != 1 || len(results[0].Failed) != 2 {
+		t.Errorf("results = %+v, want every entry failed", results)
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings proxy.Settings
+	}{
+		{"missing endpoint", proxy.Settings{}},
+		{"invalid endpoint", proxy.Settings{"endpoint": "localhost:9200"}},
+		{"invalid index", proxy.Settings{"endpoint": "http://localhost:9200", "index": "Logs"}},
+		{"invalid flush_interval", proxy.Settings{"endpoint": "http://localhost:9200", "flush_interval": "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := proxy.NewTarget("elasticsearch", tt.settings); err == nil {
+				t.Error("NewTarget() error = nil, want an error")
+			}
+		})
+	}
+
+	target, err := proxy.NewTarget("elasticsearch", proxy.Settings{
+		"endpoint": "https://localhost:9200/",
+		"index":    "app-{2006.01}",
+		"api_key":  "a2V5",
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	l := target.(*elasticLogger)
+	defer l.Close()
+
+	if l.poster.URL != "https://localhost:9200"+bulkPath {
+		t.Errorf("url = %q", l.poster.URL)
+	}
+	if l.index != "app-{2006.01}" || l.header.Get("Authorization") != "ApiKey a2V5" {
+		t.Errorf("index, authorization = %q, %q", l.index, l.header.Get("Authorization"))
+	}
+}
@@ -0,0 +1,143 @@
+package emaillogger
+
+import (
+	"sort"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+// digest collects logs between two emails, grouped by model and category.
+type digest struct {
+	start      time.Time
+	end        time.Time
+	total      int
+	listed     int
+	bySeverity map[models.Severity]int
+	groups     map[digestKey]*digestGroup
+	entries    []proxy.Entry // every log, reported with the result of the digest
+	attempts   int           // failed attempts to send the digest
+}
+
+type digestKey struct {
+	model    models.Model
+	category string
+}
+
+type digestGroup struct {
+	Model       models.Model
+	Category    string
+	Count       int
+	MaxSeverity models.Severity
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Entries     []digestEntry // the first logs of the group, up to the max digest size in total
+}
+
+type digestEntry struct {
+	Time     time.Time
+	Severity models.Severity
+	Message  string
+}
+
+func newDigest() *digest {
+	return &digest{
+		bySeverity: map[models.Severity]int{},
+		groups:     map[digestKey]*digestGroup{},
+	}
+}
+
+func (d *digest) add(log models.Log, rawMessage string, maxSize int) {
+	if d.total == 0 || log.CreatedAt.Before(d.start) {
+		d.start = log.CreatedAt
+	}
+	if log.CreatedAt.After(d.end) {
+		d.end = log.CreatedAt
+	}
+	d.total++
+	d.bySeverity[log.Severity]++
+	d.entries = append(d.entries, proxy.Entry{Log: log, RawMessage: rawMessage})
+
+	key := digestKey{model: log.Model, category: log.Category}
+	group, ok := d.groups[key]
+	if !ok {
+		group = &digestGroup{Model: log.Model, Category: log.Category, FirstSeen: log.CreatedAt}
+		d.groups[key] = group
+	}
+	group.Count++
+	group.MaxSeverity = max(group.MaxSeverity, log.Severity)
+	if log.CreatedAt.Before(group.FirstSeen) {
+		group.FirstSeen = log.CreatedAt
+	}
+	if log.CreatedAt.After(group.LastSeen) {
+		group.LastSeen = log.CreatedAt
+	}
+
+	if d.listed < maxSize {
+		message, _ := format.Split(rawMessage)
+		group.Entries = append(group.Entries, digestEntry{Time: log.CreatedAt, Severity: log.Severity, Message: message})
+		d.listed++
+	}
+}
+
+// merge adds the logs collected in other, which came after the logs of d.
+func (d *digest) merge(other *digest, maxSize int) {
+	if other.total == 0 {
+		return
+	}
+	if d.total == 0 || other.start.Before(d.start) {
+		d.start = other.start
+	}
+	if other.end.After(d.end) {
+		d.end = other.end
+	}
+	d.total += other.total
+	d.entries = append(d.entries, other.entries...)
+	for severity, count := range other.bySeverity {
+		d.bySeverity[severity] += count
+	}
+
+	for key, otherGroup := range other.groups {
+		group, ok := d.groups[key]
+		if !ok {
+			group = &digestGroup{Model: otherGroup.Model, Category: otherGroup.Category, FirstSeen: otherGroup.FirstSeen}
+			d.groups[key] = group
+		}
+		group.Count += otherGroup.Count
+		group.MaxSeverity = max(group.MaxSeverity, otherGroup.MaxSeverity)
+		if otherGroup.FirstSeen.Before(group.FirstSeen) {
+			group.FirstSeen = otherGroup.FirstSeen
+		}
+		if otherGroup.LastSeen.After(group.LastSeen) {
+			group.LastSeen = otherGroup.LastSeen
+		}
+
+		for _, entry := range otherGroup.Entries {
+			if d.listed >= maxSize {
+				break
+			}
+			group.Entries = append(group.Entries, entry)
+			d.listed++
+		}
+	}
+}
+
+// sortedGroups returns the groups with the most severe logs first, then by model and category.
+func (d *digest) sortedGroups() []*digestGroup {
+	groups := make([]*digestGroup, 0, len(d.groups))
+	for _, group := range d.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].MaxSeverity != groups[j].MaxSeverity {
+			return groups[i].MaxSeverity > groups[j].MaxSeverity
+		}
+		if groups[i].Model != groups[j].Model {
+			return groups[i].Model < groups[j].Model
+		}
+		return groups[i].Category < groups[j].Category
+	})
+	return groups
+}
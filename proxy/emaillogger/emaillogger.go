@@ -0,0 +1,373 @@
+// Package emaillogger sends logs by email over SMTP, one email per log or as periodic digests.
+package emaillogger
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var ErrClosed = errors.New("emaillogger: closed")
+
+// Security is how the connection to the SMTP server is encrypted.
+type Security string
+
+const (
+	Security_Auto     Security = ""         // STARTTLS if the server supports it
+	Security_StartTLS Security = "starttls" // STARTTLS, failing if the server doesn't support it
+	Security_TLS      Security = "tls"      // implicit TLS, usually on port 465
+	Security_None     Security = "none"     // no encryption, for local relays
+)
+
+func init() {
+	proxy.RegisterTarget("email", newFromSettings, "password")
+}
+
+type Option func(*emailLogger)
+
+// WithAuth authenticates with PLAIN auth. It is only used over encrypted connections or to localhost.
+func WithAuth(username, password string) Option {
+	return func(l *emailLogger) {
+		l.username = username
+		l.password = password
+	}
+}
+
+// WithSecurity sets how the connection is encrypted. Defaults to Security_Auto.
+func WithSecurity(security Security) Option {
+	return func(l *emailLogger) {
+		l.security = security
+	}
+}
+
+// WithTLSConfig sets the TLS configuration, e.g. for private certificate authorities.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(l *emailLogger) {
+		l.tlsConfig = config
+	}
+}
+
+// WithSubjectPrefix sets the start of every subject. Defaults to "[logar]".
+func WithSubjectPrefix(prefix string) Option {
+	return func(l *emailLogger) {
+		l.subjectPrefix = prefix
+	}
+}
+
+// WithPanelURL links emails to the logs of their models in the web panel served at panelURL.
+func WithPanelURL(panelURL string) Option {
+	return func(l *emailLogger) {
+		l.panelURL = panelURL
+	}
+}
+
+// WithDigest collects logs and sends a summary grouped by model and category every interval,
+// instead of one email per log. Close sends the last digest, proxies close their target when the app is closed.
+func WithDigest(interval time.Duration) Option {
+	return func(l *emailLogger) {
+		l.digestInterval = interval
+	}
+}
+
+// WithMaxDigestSize sets how many logs a digest lists. Further logs are only counted. Defaults to 1000.
+func WithMaxDigestSize(size int) Option {
+	return func(l *emailLogger) {
+		if size > 0 {
+			l.maxDigestSize = size
+		}
+	}
+}
+
+// WithDigestRetries sets how many times a digest that couldn't be sent is sent again with the next one,
+// before its logs are reported as failed to the OnResult function and dropped. Defaults to 3.
+func WithDigestRetries(retries int) Option {
+	return func(l *emailLogger) {
+		l.digestRetries = max(retries, 0)
+	}
+}
+
+// WithTimeout sets the timeout of connecting to the server and of sending an email. Defaults to 30 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *emailLogger) {
+		if timeout > 0 {
+			l.timeout = timeout
+		}
+	}
+}
+
+// New creates a target sending emails from the address to the recipients through the SMTP server
+// at addr, such as "smtp.example.com:587".
+func New(addr string, from string, to []string, opts ...Option) (*emailLogger, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("emaillogger: invalid server address %q: %w", addr, err)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("emaillogger: invalid sender %q: %w", from, err)
+	}
+	if len(to) == 0 {
+		return nil, errors.New("emaillogger: no recipients")
+	}
+	recipients := make([]*mail.Address, len(to))
+	for i, address := range to {
+		recipients[i], err = mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("emaillogger: invalid recipient %q: %w", address, err)
+		}
+	}
+
+	l := &emailLogger{
+		addr:          addr,
+		host:          host,
+		from:          sender,
+		to:            recipients,
+		subjectPrefix: "[logar]",
+		maxDigestSize: 1000,
+		digestRetries: 3,
+		timeout:       30 * time.Second,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	switch l.security {
+	case Security_Auto, Security_StartTLS, Security_TLS, Security_None:
+	default:
+		return nil, fmt.Errorf("emaillogger: unknown security %q", l.security)
+	}
+
+	if l.digestInterval > 0 {
+		l.digest = newDigest()
+		l.wg.Add(1)
+		go l.run()
+	}
+
+	return l, nil
+}
+
+type emailLogger struct {
+	addr          string
+	host          string
+	from          *mail.Address
+	to            []*mail.Address
+	username      string
+	password      string
+	security      Security
+	tlsConfig     *tls.Config
+	subjectPrefix string
+	panelURL      string
+	timeout       time.Duration
+
+	digestInterval time.Duration
+	maxDigestSize  int
+	digestRetries  int
+
+	mu       sync.Mutex
+	digest   *digest
+	onResult func(proxy.BatchResult)
+
+	// sendMu keeps digests in order
+	sendMu    sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Send emails the log, or adds it to the next digest in digest mode.
+func (l *emailLogger) Send(log models.Log, rawMessage string) error {
+	if l.digestInterval <= 0 {
+		subject, text, html := l.renderLog(log, rawMessage)
+		start := time.Now()
+		if err := l.send(subject, text, html); err != nil {
+			return err
+		}
+		l.report(proxy.BatchResult{Entries: []proxy.Entry{{Log: log, RawMessage: rawMessage}}, Latency: time.Since(start)})
+		return nil
+	}
+
+	select {
+	case <-l.done:
+		return ErrClosed
+	default:
+	}
+
+	l.mu.Lock()
+	l.digest.add(log, rawMessage, l.maxDigestSize)
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *emailLogger) run() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.digestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			// Errors of digests whose retries ran out are reported to the OnResult function
+			if err := l.Flush(); err != nil && !l.reporting() {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// OnResult passes the result of every digest to fn, once it was sent or its retries ran out. Without
+// digests, fn is called with every log that was sent, and errors are returned by Send to be retried.
+func (l *emailLogger) OnResult(fn func(proxy.BatchResult)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onResult = fn
+}
+
+func (l *emailLogger) reporting() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.onResult != nil
+}
+
+func (l *emailLogger) report(result proxy.BatchResult) {
+	l.mu.Lock()
+	onResult := l.onResult
+	l.mu.Unlock()
+
+	if onResult != nil {
+		onResult(result)
+	}
+}
+
+// Flush sends the digest of the logs collected so far, if there are any. If sending fails,
+// the logs are kept for the next digest, until the retries of the digest run out.
+func (l *emailLogger) Flush() error {
+	return l.flush(false)
+}
+
+// flush sends the digest. If final is set, a digest that fails is reported instead of kept, as
+// there is no next digest.
+func (l *emailLogger) flush(final bool) error {
+	if l.digest == nil {
+		return nil
+	}
+
+	l.sendMu.Lock()
+	defer l.sendMu.Unlock()
+
+	l.mu.Lock()
+	current := l.digest
+	l.digest = newDigest()
+	l.mu.Unlock()
+
+	if current.total == 0 {
+		return nil
+	}
+
+	subject, text, html := l.renderDigest(current)
+	start := time.Now()
+	err := l.send(subject, text, html)
+	result := proxy.BatchResult{Entries: current.entries, Latency: time.Since(start)}
+	if err != nil {
+		current.attempts++
+		if !final && current.attempts <= l.digestRetries {
+			l.mu.Lock()
+			current.merge(l.digest, l.maxDigestSize)
+			l.digest = current
+			l.mu.Unlock()
+			return err
+		}
+		err = fmt.Errorf("emaillogger: digest of %d logs dropped after %d attempts: %w", current.total, current.attempts, err)
+		result.Failed = current.entries
+		result.Err = err
+	}
+	l.report(result)
+	return err
+}
+
+// Close stops collecting logs and sends the last digest.
+func (l *emailLogger) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	l.wg.Wait()
+	return l.flush(true)
+}
+
+type settings struct {
+	Addr          string   `json:"addr"`
+	From          string   `json:"from"`
+	To            []string `json:"to"`
+	Username      string   `json:"username"`
+	Password      string   `json:"password"`
+	Security      string   `json:"security"` // starttls, tls or none. By default STARTTLS is used if supported
+	SubjectPrefix *string  `json:"subject_prefix"`
+	PanelURL      string   `json:"panel_url"`
+	Digest        string   `json:"digest"` // interval of digests, such as "1h". Empty sends every log
+	MaxDigestSize int      `json:"max_digest_size"`
+	DigestRetries *int     `json:"digest_retries"`
+	Timeout       string   `json:"timeout"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Addr == "" {
+		return nil, errors.New("addr is required")
+	}
+	if cfg.From == "" {
+		return nil, errors.New("from is required")
+	}
+	if len(cfg.To) == 0 {
+		return nil, errors.New("to is required")
+	}
+
+	opts := []Option{
+		WithSecurity(Security(strings.ToLower(cfg.Security))),
+		WithPanelURL(cfg.PanelURL),
+		WithMaxDigestSize(cfg.MaxDigestSize),
+	}
+	if cfg.Username != "" {
+		opts = append(opts, WithAuth(cfg.Username, cfg.Password))
+	}
+	if cfg.SubjectPrefix != nil {
+		opts = append(opts, WithSubjectPrefix(*cfg.SubjectPrefix))
+	}
+	if cfg.Digest != "" {
+		interval, err := time.ParseDuration(cfg.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("digest: %w", err)
+		}
+		opts = append(opts, WithDigest(interval))
+	}
+	if cfg.DigestRetries != nil {
+		opts = append(opts, WithDigestRetries(*cfg.DigestRetries))
+	}
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout: %w", err)
+		}
+		opts = append(opts, WithTimeout(timeout))
+	}
+
+	target, err := New(cfg.Addr, cfg.From, cfg.To, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
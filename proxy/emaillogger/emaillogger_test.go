@@ -0,0 +1,548 @@
+package emaillogger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+// smtpServer is a fake SMTP server recording the emails it receives. It supports AUTH PLAIN but not STARTTLS.
+type smtpServer struct {
+	t        *testing.T
+	listener net.Listener
+	received chan receivedEmail
+
+	mu         sync.Mutex
+	rejectData int // DATA commands to reject before accepting emails
+}
+
+type receivedEmail struct {
+	auth string // decoded AUTH PLAIN credentials
+	from string
+	to   []string
+	data []byte
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{t: t, listener: listener, received: make(chan receivedEmail, 10)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	var email receivedEmail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			email.auth = string(credentials)
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			email.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			email.to = append(email.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			s.mu.Lock()
+			reject := s.rejectData > 0
+			if reject {
+				s.rejectData--
+			}
+			s.mu.Unlock()
+			if reject {
+				tp.PrintfLine("451 4.3.0 Try again later")
+				continue
+			}
+
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			email.data, err = tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.received <- email
+			email = receivedEmail{auth: email.auth}
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// next returns the next email received by the server, failing if none comes in time.
+func (s *smtpServer) next() receivedEmail {
+	s.t.Helper()
+	select {
+	case email := <-s.received:
+		return email
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no email received")
+		return receivedEmail{}
+	}
+}
+
+// expectNone fails if the server receives an email within wait.
+func (s *smtpServer) expectNone(wait time.Duration) {
+	s.t.Helper()
+	select {
+	case email := <-s.received:
+		s.t.Fatalf("unexpected email:\n%s", email.data)
+	case <-time.After(wait):
+	}
+}
+
+type parsedEmail struct {
+	header mail.Header
+	text   string
+	html   string
+}
+
+func parseEmail(t *testing.T, data []byte) parsedEmail {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid email: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid content type: %v", err)
+	}
+
+	email := parsedEmail{header: msg.Header}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid part: %v", err)
+		}
+		content, _ := io.ReadAll(part)
+		switch {
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"):
+			email.text = string(content)
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/html"):
+			email.html = string(content)
+		}
+	}
+	return email
+}
+
+func (e parsedEmail) subject(t *testing.T) string {
+	t.Helper()
+	subject, err := new(mime.WordDecoder).DecodeHeader(e.header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("invalid subject: %v", err)
+	}
+	return subject
+}
+
+func testLog(model models.Model, category string, severity models.Severity, minute int) models.Log {
+	return models.Log{
+		CreatedAt: time.Date(2024, 1, 2, 3, minute, 0, 0, time.UTC),
+		Model:     model,
+		Category:  category,
+		Severity:  severity,
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	server := newSMTPServer(t)
+	target, err := New(server.addr(), "Logar <logar@example.com>", []string{"ops@example.com", "Dev <dev@example.com>"},
+		WithSecurity(Security_Auto), WithAuth("user", "pass"), WithPanelURL("https://example.com/logar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	log := testLog("payments", "checkout", models.Severity_Error, 4)
+	log.Caller = "main.go:12"
+	if err := target.Send(log, `{"message":"card declined: <visa>","order":"42"}`); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	received := server.next()
+	if received.auth != "\x00user\x00pass" {
+		t.Errorf("auth = %q, want the PLAIN credentials", received.auth)
+	}
+	if received.from != "logar@example.com" {
+		t.Errorf("MAIL FROM = %q", received.from)
+	}
+	if strings.Join(received.to, ",") != "ops@example.com,dev@example.com" {
+		t.Errorf("RCPT TO = %v", received.to)
+	}
+
+	email := parseEmail(t, received.data)
+	if got, want := email.subject(t), "[logar] [ERROR] payments/checkout: card declined: <visa>"; got != want {
+		t.Errorf("subject = %q, want %q", got, want)
+	}
+	if got := email.header.Get("To"); got != `<ops@example.com>, "Dev" <dev@example.com>` {
+		t.Errorf("To = %q", got)
+	}
+	for _, want := range []string{"card declined: <visa>", "Caller:   main.go:12", "order: 42", "https://example.com/logar/logs?model=payments"} {
+		if !strings.Contains(email.text, want) {
+			t.Errorf("text doesn't contain %q:\n%s", want, email.text)
+		}
+	}
+	if !strings.Contains(email.html, "card declined: &lt;visa&gt;") {
+		t.Errorf("html doesn't contain the escaped message:\n%s", email.html)
+	}
+}
+
+func TestSendEmailError(t *testing.T) {
+	server := newSMTPServer(t)
+	server.rejectData = 1
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithSecurity(Security_None))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	if err := target.Send(testLog("payments", "", models.Severity_Error, 4), "card declined"); err == nil {
+		t.Error("Send() error = nil, want the error of the server")
+	}
+}
+
+func TestDigest(t *testing.T) {
+	server := newSMTPServer(t)
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(time.Hour), WithSubjectPrefix(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	logs := []models.Log{
+		testLog("api", "http", models.Severity_Info, 1),
+		testLog("payments", "checkout", models.Severity_Warning, 2),
+		testLog("api", "http", models.Severity_Info, 3),
+		testLog("payments", "checkout", models.Severity_Error, 4),
+		testLog("api", "http", models.Severity_Info, 5),
+	}
+	for i, log := range logs {
+		if err := target.Send(log, "message "+string(rune('a'+i))); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	server.expectNone(50 * time.Millisecond)
+
+	if err := target.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	email := parseEmail(t, server.next().data)
+
+	if got, want := email.subject(t), "Digest: 5 logs in 2 groups (1 Error, 1 Warn, 3 Info)"; got != want {
+		t.Errorf("subject = %q, want %q", got, want)
+	}
+	payments := strings.Index(email.text, "payments / checkout: 2 logs, up to Error")
+	api := strings.Index(email.text, "api / http: 3 logs, up to Info")
+	if payments < 0 || api < 0 || payments > api {
+		t.Errorf("text doesn't list the payments group, then the api group:\n%s", email.text)
+	}
+	for _, want := range []string{"5 logs from 2024-01-02 03:01:00 UTC to 2024-01-02 03:05:00 UTC", "[ERROR] message d", "[INFO] message e"} {
+		if !strings.Contains(email.text, want) {
+			t.Errorf("text doesn't contain %q:\n%s", want, email.text)
+		}
+	}
+
+	// Nothing was collected since
+	if err := target.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	server.expectNone(50 * time.Millisecond)
+}
+
+func TestDigestMaxSize(t *testing.T) {
+	server := newSMTPServer(t)
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(time.Hour), WithMaxDigestSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	for i := 0; i < 5; i++ {
+		target.Send(testLog("api", "http", models.Severity_Info, i), "message")
+	}
+	if err := target.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	email := parseEmail(t, server.next().data)
+
+	if got := strings.Count(email.text, "[INFO] message"); got != 2 {
+		t.Errorf("text lists %d logs, want 2:\n%s", got, email.text)
+	}
+	if !strings.Contains(email.text, "3 more logs are not listed.") {
+		t.Errorf("text doesn't count the unlisted logs:\n%s", email.text)
+	}
+}
+
+func TestDigestKeptOnFailure(t *testing.T) {
+	server := newSMTPServer(t)
+	server.rejectData = 1
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	target.Send(testLog("api", "http", models.Severity_Info, 1), "first")
+	target.Send(testLog("api", "http", models.Severity_Info, 2), "second")
+	if err := target.Flush(); err == nil {
+		t.Fatal("Flush() error = nil, want the error of the server")
+	}
+
+	target.Send(testLog("payments", "", models.Severity_Error, 3), "third")
+	if err := target.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	email := parseEmail(t, server.next().data)
+
+	if got, want := email.subject(t), "[logar] Digest: 3 logs in 2 groups (1 Error, 2 Info)"; got != want {
+		t.Errorf("subject = %q, want %q", got, want)
+	}
+	for _, want := range []string{"first", "second", "third"} {
+		if !strings.Contains(email.text, want) {
+			t.Errorf("text doesn't contain %q:\n%s", want, email.text)
+		}
+	}
+}
+
+func TestDigestResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		rejectData int
+		retries    int
+		wantErrs   int // Flush calls that fail
+		wantFailed bool
+	}{
+		{"sent", 0, 1, 0, false},
+		{"sent on retry", 1, 1, 1, false},
+		{"retries run out", 2, 1, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSMTPServer(t)
+			server.rejectData = tt.rejectData
+			target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(time.Hour), WithDigestRetries(tt.retries))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer target.Close()
+
+			var results []proxy.BatchResult
+			target.OnResult(func(result proxy.BatchResult) {
+				results = append(results, result)
+			})
+
+			target.Send(testLog("api", "http", models.Severity_Info, 1), "first")
+			target.Send(testLog("api", "http", models.Severity_Info, 2), "second")
+			errs := 0
+			for i := 0; i <= tt.retries; i++ {
+				if target.Flush() != nil {
+					errs++
+				}
+			}
+			if errs != tt.wantErrs {
+				t.Errorf("got %d failed flushes, want %d", errs, tt.wantErrs)
+			}
+
+			// Only reported once it was sent or dropped
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			result := results[0]
+			if len(result.Entries) != 2 || result.Entries[0].RawMessage != "first" {
+				t.Errorf("entries = %+v, want the logs of the digest", result.Entries)
+			}
+			if failed := len(result.Failed) == 2 && result.Err != nil; failed != tt.wantFailed {
+				t.Errorf("failed = %d, error = %v, want failed: %v", len(result.Failed), result.Err, tt.wantFailed)
+			}
+
+			// A dropped digest isn't sent again
+			if err := target.Flush(); err != nil {
+				t.Errorf("Flush() error = %v", err)
+			}
+			if len(results) != 1 {
+				t.Errorf("got %d results after the digest was dropped, want 1", len(results))
+			}
+		})
+	}
+}
+
+func TestDigestCloseFailure(t *testing.T) {
+	server := newSMTPServer(t)
+	server.rejectData = 1
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []proxy.BatchResult
+	target.OnResult(func(result proxy.BatchResult) {
+		results = append(results, result)
+	})
+
+	target.Send(testLog("api", "http", models.Severity_Info, 1), "last")
+	// There is no next digest to retry with
+	if err := target.Close(); err == nil {
+		t.Fatal("Close() error = nil, want the error of the server")
+	}
+	if len(results) != 1 || len(results[0].Failed) != 1 {
+		t.Errorf("results = %+v, want the log failed", results)
+	}
+}
+
+func TestSendEmailResult(t *testing.T) {
+	server := newSMTPServer(t)
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []proxy.BatchResult
+	target.OnResult(func(result proxy.BatchResult) {
+		results = append(results, result)
+	})
+
+	if err := target.Send(testLog("api", "http", models.Severity_Error, 1), "sent"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	server.next()
+	if len(results) != 1 || len(results[0].Entries) != 1 || results[0].Failed != nil {
+		t.Errorf("results = %+v, want the sent log", results)
+	}
+
+	// Errors are returned by Send, so that proxies retry them
+	server.mu.Lock()
+	server.rejectData = 1
+	server.mu.Unlock()
+	if err := target.Send(testLog("api", "http", models.Severity_Error, 2), "rejected"); err == nil {
+		t.Fatal("Send() error = nil, want the error of the server")
+	}
+	if len(results) != 1 {
+		t.Errorf("got %d results, want only the sent log", len(results))
+	}
+}
+
+func TestDigestInterval(t *testing.T) {
+	server := newSMTPServer(t)
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	target.Send(testLog("api", "http", models.Severity_Info, 1), "first")
+	email := parseEmail(t, server.next().data)
+	if !strings.Contains(email.text, "first") {
+		t.Errorf("text doesn't contain the log:\n%s", email.text)
+	}
+}
+
+func TestDigestClose(t *testing.T) {
+	server := newSMTPServer(t)
+	target, err := New(server.addr(), "logar@example.com", []string{"ops@example.com"}, WithDigest(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target.Send(testLog("api", "http", models.Severity_Info, 1), "last")
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	email := parseEmail(t, server.next().data)
+	if !strings.Contains(email.text, "last") {
+		t.Errorf("text doesn't contain the log:\n%s", email.text)
+	}
+
+	if err := target.Send(testLog("api", "http", models.Severity_Info, 2), "late"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() after Close() error = %v, want ErrClosed", err)
+	}
+	if err := target.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	server.expectNone(50 * time.Millisecond)
+}
+
+func TestNewFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings proxy.Settings
+	}{
+		{"missing addr", proxy.Settings{"from": "logar@example.com", "to": []any{"ops@example.com"}}},
+		{"missing to", proxy.Settings{"addr": "localhost:25", "from": "logar@example.com"}},
+		{"invalid sender", proxy.Settings{"addr": "localhost:25", "from": "logar", "to": []any{"ops@example.com"}}},
+		{"invalid security", proxy.Settings{"addr": "localhost:25", "from": "logar@example.com", "to": []any{"ops@example.com"}, "security": "ssl"}},
+		{"invalid digest", proxy.Settings{"addr": "localhost:25", "from": "logar@example.com", "to": []any{"ops@example.com"}, "digest": "daily"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := proxy.NewTarget("email", tt.settings); err == nil {
+				t.Error("NewTarget() error = nil, want an error")
+			}
+		})
+	}
+
+	target, err := proxy.NewTarget("email", proxy.Settings{"addr": "localhost:25", "from": "logar@example.com", "to": []any{"ops@example.com"}, "digest": "1h", "digest_retries": int64(0)})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	l := target.(*emailLogger)
+	defer l.Close()
+	if l.digestRetries != 0 {
+		t.Errorf("digest retries = %d, want 0", l.digestRetries)
+	}
+	if _, ok := target.(proxy.BatchTarget); !ok {
+		t.Error("the target isn't a proxy.BatchTarget")
+	}
+}
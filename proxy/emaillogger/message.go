@@ -0,0 +1,247 @@
+package emaillogger
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy/format"
+)
+
+const maxSubjectMessageLength = 80
+
+var templateFuncs = map[string]any{
+	"upper": strings.ToUpper,
+	"time": func(t time.Time) string {
+		return t.Format(time.DateTime + " MST")
+	},
+	"color": func(severity models.Severity) string {
+		return fmt.Sprintf("#%06x", format.Color(severity))
+	},
+	"truncate": format.Truncate,
+	"logsURL":  format.LogsURL,
+}
+
+type logData struct {
+	Log      models.Log
+	Message  string
+	Fields   []format.Field
+	PanelURL string
+}
+
+var logText = template.Must(template.New("log").Funcs(templateFuncs).Parse(`{{upper .Log.Severity.String}} in {{.Log.Model}}{{with .Log.Category}} / {{.}}{{end}}
+
+{{.Message}}
+
+Time:     {{time .Log.CreatedAt}}
+Model:    {{.Log.Model}}
+Category: {{.Log.Category}}
+{{- with .Log.Caller}}
+Caller:   {{.}}{{end}}
+{{- with .Log.TraceID}}
+Trace:    {{.}}{{end}}
+{{- range .Fields}}
+{{.Name}}: {{.Value}}{{end}}
+{{- with .Log.StackTrace}}
+
+{{.}}{{end}}
+{{- with logsURL .PanelURL .Log.Model}}
+
+{{.}}{{end}}
+`))
+
+var logHTML = htmltemplate.Must(htmltemplate.New("log").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; color: #222;">
+<div style="border-left: 4px solid {{color .Log.Severity}}; padding-left: 12px;">
+<h3 style="margin: 0 0 8px;">{{upper .Log.Severity.String}} in {{.Log.Model}}{{with .Log.Category}} / {{.}}{{end}}</h3>
+<pre style="white-space: pre-wrap; margin: 0 0 12px;">{{.Message}}</pre>
+<table style="border-collapse: collapse; font-size: 14px;">
+<tr><td style="padding: 2px 12px 2px 0; color: #666;">Time</td><td>{{time .Log.CreatedAt}}</td></tr>
+<tr><td style="padding: 2px 12px 2px 0; color: #666;">Model</td><td>{{.Log.Model}}</td></tr>
+<tr><td style="padding: 2px 12px 2px 0; color: #666;">Category</td><td>{{.Log.Category}}</td></tr>
+{{- with .Log.Caller}}
+<tr><td style="padding: 2px 12px 2px 0; color: #666;">Caller</td><td>{{.}}</td></tr>{{end}}
+{{- with .Log.TraceID}}
+<tr><td style="padding: 2px 12px 2px 0; color: #666;">Trace</td><td>{{.}}</td></tr>{{end}}
+{{- range .Fields}}
+<tr><td style="padding: 2px 12px 2px 0; color: #666;">{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}
+</table>
+{{- with .Log.StackTrace}}
+<pre style="font-size: 12px; background: #f5f5f5; padding: 8px;">{{.}}</pre>{{end}}
+{{- with logsURL .PanelURL .Log.Model}}
+<p><a href="{{.}}">Open in logar</a></p>{{end}}
+</div>
+</body></html>
+`))
+
+type digestData struct {
+	Total    int
+	Start    time.Time
+	End      time.Time
+	Groups   []*digestGroup
+	Counts   string
+	Unlisted int
+	PanelURL string
+}
+
+var digestText = template.Must(template.New("digest").Funcs(templateFuncs).Parse(`{{.Total}} logs from {{time .Start}} to {{time .End}}{{with .Counts}} ({{.}}){{end}}
+{{range .Groups}}
+{{.Model}}{{with .Category}} / {{.}}{{end}}: {{.Count}} logs, up to {{.MaxSeverity}}, last at {{time .LastSeen}}
+{{- range .Entries}}
+  {{time .Time}} [{{upper .Severity.String}}] {{truncate .Message 500}}{{end}}
+{{- with logsURL $.PanelURL .Model}}
+  {{.}}{{end}}
+{{end}}
+{{- if .Unlisted}}
+{{.Unlisted}} more logs are not listed.
+{{end}}`))
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; color: #222;">
+<p>{{.Total}} logs from {{time .Start}} to {{time .End}}{{with .Counts}} ({{.}}){{end}}</p>
+{{range .Groups}}
+<div style="border-left: 4px solid {{color .MaxSeverity}}; padding-left: 12px; margin-bottom: 16px;">
+<h4 style="margin: 0 0 4px;">{{.Model}}{{with .Category}} / {{.}}{{end}}</h4>
+<div style="color: #666; font-size: 13px; margin-bottom: 4px;">{{.Count}} logs, up to {{.MaxSeverity}}, last at {{time .LastSeen}}
+{{- with logsURL $.PanelURL .Model}} &middot; <a href="{{.}}">Open in logar</a>{{end}}</div>
+<table style="border-collapse: collapse; font-size: 13px;">
+{{- range .Entries}}
+<tr><td style="padding: 2px 8px 2px 0; color: #666; white-space: nowrap;">{{time .Time}}</td><td style="padding: 2px 8px 2px 0; color: {{color .Severity}};">{{upper .Severity.String}}</td><td style="padding: 2px 0;">{{truncate .Message 500}}</td></tr>{{end}}
+</table>
+</div>
+{{end}}
+{{- if .Unlisted}}
+<p style="color: #666;">{{.Unlisted}} more logs are not listed.</p>
+{{end}}
+</body></html>
+`))
+
+func (l *emailLogger) renderLog(log models.Log, rawMessage string) (subject, text, html string) {
+	message, fields := format.Split(rawMessage)
+	data := logData{Log: log, Message: message, Fields: fields, PanelURL: l.panelURL}
+
+	subject = fmt.Sprintf("[%s] %s", strings.ToUpper(log.Severity.String()), log.Model)
+	if log.Category != "" {
+		subject += "/" + log.Category
+	}
+	subject += ": " + format.Truncate(strings.Join(strings.Fields(message), " "), maxSubjectMessageLength)
+
+	return l.subject(subject), execute(logText, data), execute(logHTML, data)
+}
+
+func (l *emailLogger) renderDigest(d *digest) (subject, text, html string) {
+	var counts []string
+	for severity := models.Severity_Max - 1; severity > models.Severity_None; severity-- {
+		if count := d.bySeverity[severity]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count, severity))
+		}
+	}
+
+	data := digestData{
+		Total:    d.total,
+		Start:    d.start,
+		End:      d.end,
+		Groups:   d.sortedGroups(),
+		Counts:   strings.Join(counts, ", "),
+		Unlisted: d.total - d.listed,
+		PanelURL: l.panelURL,
+	}
+
+	subject = fmt.Sprintf("Digest: %d logs in %d groups", d.total, len(d.groups))
+	if data.Counts != "" {
+		subject += " (" + data.Counts + ")"
+	}
+
+	return l.subject(subject), execute(digestText, data), execute(digestHTML, data)
+}
+
+func (l *emailLogger) subject(subject string) string {
+	if l.subjectPrefix == "" {
+		return subject
+	}
+	return l.subjectPrefix + " " + subject
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+func execute(tmpl executor, data any) string {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "template error: " + err.Error()
+	}
+	return buf.String()
+}
+
+// buildMessage builds a multipart/alternative email with a plain text and an HTML body.
+func buildMessage(from *mail.Address, to []*mail.Address, subject, text, html string, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	recipients := make([]string, len(to))
+	for i, address := range to {
+		recipients[i] = address.String()
+	}
+
+	var message bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&message, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", strings.Join(recipients, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+writer.Boundary())
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func messageID(from *mail.Address) string {
+	domain := "logar"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
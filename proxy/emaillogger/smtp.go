@@ -0,0 +1,94 @@
+package emaillogger
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// send delivers an email with a plain text and an HTML body to every recipient.
+func (l *emailLogger) send(subject, text, html string) error {
+	message, err := buildMessage(l.from, l.to, subject, text, html, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = l.deliver(message)
+	if err != nil {
+		return fmt.Errorf("emaillogger: %w", err)
+	}
+	return nil
+}
+
+func (l *emailLogger) deliver(message []byte) error {
+	dialer := &net.Dialer{Timeout: l.timeout}
+	var conn net.Conn
+	var err error
+	if l.security == Security_TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", l.addr, l.getTLSConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", l.addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(l.timeout))
+
+	c, err := smtp.NewClient(conn, l.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if l.security == Security_Auto || l.security == Security_StartTLS {
+		ok, _ := c.Extension("STARTTLS")
+		if ok {
+			if err := c.StartTLS(l.getTLSConfig()); err != nil {
+				return err
+			}
+		} else if l.security == Security_StartTLS {
+			return fmt.Errorf("server %s doesn't support STARTTLS", l.addr)
+		}
+	}
+
+	if l.username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("server %s doesn't support AUTH", l.addr)
+		}
+		if err := c.Auth(smtp.PlainAuth("", l.username, l.password, l.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(l.from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range l.to {
+		if err := c.Rcpt(recipient.Address); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (l *emailLogger) getTLSConfig() *tls.Config {
+	if l.tlsConfig != nil {
+		return l.tlsConfig
+	}
+	return &tls.Config{ServerName: l.host}
+}
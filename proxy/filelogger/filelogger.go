@@ -0,0 +1,287 @@
+// Package filelogger writes logs to a local file as text, logfmt or JSON lines, rotating it by size or time.
+package filelogger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+var ErrClosed = errors.New("filelogger: closed")
+
+func init() {
+	proxy.RegisterTarget("file", newFromSettings)
+}
+
+type Option func(*fileLogger)
+
+// WithFormatter sets how logs are written, every log is written as a line. Defaults to format.Text.
+func WithFormatter(formatter format.Formatter) Option {
+	return func(l *fileLogger) {
+		l.formatter = formatter
+	}
+}
+
+// WithMaxSize rotates the file before it grows over size bytes.
+func WithMaxSize(size int64) Option {
+	return func(l *fileLogger) {
+		l.maxSize = max(size, 0)
+	}
+}
+
+// WithRotateEvery rotates the file when a new interval starts, e.g. every 24 hours at midnight UTC.
+func WithRotateEvery(interval time.Duration) Option {
+	return func(l *fileLogger) {
+		l.rotateEvery = max(interval, 0)
+	}
+}
+
+// WithMaxBackups sets how many rotated files are kept, the oldest are removed first. 0 keeps all of them.
+func WithMaxBackups(count int) Option {
+	return func(l *fileLogger) {
+		l.maxBackups = max(count, 0)
+	}
+}
+
+// WithCompress gzips rotated files in the background.
+func WithCompress() Option {
+	return func(l *fileLogger) {
+		l.compress = true
+	}
+}
+
+// WithReopenOnSIGHUP reopens the file when the process receives SIGHUP, so that external tools
+// such as logrotate can move it away.
+func WithReopenOnSIGHUP() Option {
+	return func(l *fileLogger) {
+		l.reopenOnSIGHUP = true
+	}
+}
+
+// WithFileMode sets the permissions of created files. Defaults to 0644.
+func WithFileMode(mode os.FileMode) Option {
+	return func(l *fileLogger) {
+		l.fileMode = mode
+	}
+}
+
+// New creates a target appending logs to the file at path, creating it and its directory if needed.
+// Proxies close their target, and so the file, when the app is closed.
+func New(path string, opts ...Option) (*fileLogger, error) {
+	if path == "" {
+		return nil, errors.New("filelogger: empty path")
+	}
+
+	l := &fileLogger{
+		path:      filepath.Clean(path),
+		formatter: format.Text,
+		fileMode:  0644,
+		mill:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	if l.maxSize > 0 || l.rotateEvery > 0 {
+		l.wg.Add(1)
+		go l.runMill()
+	}
+	if l.reopenOnSIGHUP {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+		l.wg.Add(1)
+		go l.runReopen(signals)
+	}
+
+	return l, nil
+}
+
+type fileLogger struct {
+	path           string
+	formatter      format.Formatter
+	maxSize        int64
+	rotateEvery    time.Duration
+	maxBackups     int
+	compress       bool
+	reopenOnSIGHUP bool
+	fileMode       os.FileMode
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // start of the rotation interval the file was written in
+	closed bool
+
+	millErr   error // last error of compressing or removing rotated files
+	mill      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (l *fileLogger) Send(log models.Log, rawMessage string) error {
+	text, err := l.formatter.Format(log, rawMessage)
+	if err != nil {
+		return fmt.Errorf("filelogger: %w", err)
+	}
+	line := []byte(text + "\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	// The file is missing if reopening it failed
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+
+	if l.shouldRotate(int64(len(line)), time.Now()) {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("filelogger: %w", err)
+	}
+	return nil
+}
+
+// Reopen closes the file and opens the file at the path again, which is a new file if the old one was moved.
+func (l *fileLogger) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	if l.file != nil {
+		err := l.file.Close()
+		l.file = nil
+		if err != nil {
+			return fmt.Errorf("filelogger: %w", err)
+		}
+	}
+	return l.open()
+}
+
+func (l *fileLogger) runReopen(signals chan os.Signal) {
+	defer l.wg.Done()
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-signals:
+			// On failure the next Send tries again
+			l.Reopen()
+		}
+	}
+}
+
+// Close closes the file and waits for rotated files to be compressed. It returns the errors of
+// compressing and removing rotated files, if there were any.
+func (l *fileLogger) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		l.closed = true
+		if l.file != nil {
+			err = l.file.Close()
+			l.file = nil
+		}
+		l.mu.Unlock()
+
+		close(l.done)
+	})
+	l.wg.Wait()
+
+	if err != nil {
+		err = fmt.Errorf("filelogger: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return errors.Join(err, l.millErr)
+}
+
+type settings struct {
+	Path           string `json:"path"`
+	Format         string `json:"format"`           // text, logfmt, json or markdown
+	Template       string `json:"template"`         // text/template, see format.NewTemplate
+	MaxSize        int64  `json:"max_size"`         // in bytes
+	RotateEvery    string `json:"rotate_every"`     // such as "24h"
+	MaxBackups     int    `json:"max_backups"`      // 0 keeps all rotated files
+	Compress       bool   `json:"compress"`         // gzip rotated files
+	ReopenOnSIGHUP bool   `json:"reopen_on_sighup"` // for logrotate
+	FileMode       string `json:"file_mode"`        // octal, such as "0640"
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Path == "" {
+		return nil, errors.New("path is required")
+	}
+
+	formatter, err := format.FromSettings(cfg.Format, cfg.Template, format.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{
+		WithFormatter(formatter),
+		WithMaxSize(cfg.MaxSize),
+		WithMaxBackups(cfg.MaxBackups),
+	}
+	if cfg.RotateEvery != "" {
+		interval, err := time.ParseDuration(cfg.RotateEvery)
+		if err != nil {
+			return nil, fmt.Errorf("rotate_every: %w", err)
+		}
+		opts = append(opts, WithRotateEvery(interval))
+	}
+	if cfg.Compress {
+		opts = append(opts, WithCompress())
+	}
+	if cfg.ReopenOnSIGHUP {
+		opts = append(opts, WithReopenOnSIGHUP())
+	}
+	if cfg.FileMode != "" {
+		var mode uint32
+		if _, err := fmt.Sscanf(cfg.FileMode, "%o", &mode); err != nil {
+			return nil, fmt.Errorf("file_mode: invalid mode %q", cfg.FileMode)
+		}
+		opts = append(opts, WithFileMode(os.FileMode(mode)))
+	}
+
+	target, err := New(cfg.Path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,310 @@
+package filelogger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+// lineFormatter writes the message alone, so that line lengths are known.
+var lineFormatter = format.MustTemplate("{{.Message}}")
+
+func send(t *testing.T, target proxy.ProxyTarget, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if err := target.Send(models.Log{Severity: models.Severity_Info}, message); err != nil {
+			t.Fatalf("Send(%q) error = %v", message, err)
+		}
+	}
+}
+
+func lines(prefix string, count int) []string {
+	result := make([]string, count)
+	for i := range result {
+		result[i] = fmt.Sprintf("%s-%03d", prefix, i)
+	}
+	return result
+}
+
+// readBackups returns the contents of the rotated files of path, oldest first, decompressing gzipped ones.
+func readBackups(t *testing.T, path string) []string {
+	t.Helper()
+	l := &fileLogger{path: path}
+	backups, err := l.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := make([]string, len(backups))
+	for i, b := range backups {
+		file, err := os.Open(b.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reader io.Reader = file
+		if b.compressed {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatalf("%s: %v", b.path, err)
+			}
+			reader = gz
+		}
+		data, err := io.ReadAll(reader)
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", b.path, err)
+		}
+		contents[i] = string(data)
+	}
+	return contents
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "app.log")
+	target, err := New(path, WithFormatter(lineFormatter), WithFileMode(0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send(t, target, "first", "second")
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := readFile(t, path); got != "first\nsecond\n" {
+		t.Errorf("file = %q", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := target.Send(models.Log{}, "late"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() after Close() error = %v, want ErrClosed", err)
+	}
+	if err := target.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	// Lines are 9 bytes with the new line, 3 fit in a file
+	target, err := New(path, WithFormatter(lineFormatter), WithMaxSize(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := lines("line", 10)
+	send(t, target, written...)
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	backups := readBackups(t, path)
+	if len(backups) != 3 {
+		t.Fatalf("got %d backups, want 3", len(backups))
+	}
+	for i, content := range backups {
+		if len(content) > 30 {
+			t.Errorf("backup %d has %d bytes, want at most the max size", i, len(content))
+		}
+	}
+	current := readFile(t, path)
+	if got, want := strings.Join(backups, "")+current, strings.Join(written, "\n")+"\n"; got != want {
+		t.Errorf("backups and file = %q, want every line once and in order %q", got, want)
+	}
+}
+
+func TestRotateExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 25)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The size of the file left from before is counted
+	target, err := New(path, WithFormatter(lineFormatter), WithMaxSize(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(t, target, "new line")
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if backups := readBackups(t, path); len(backups) != 1 || backups[0] != strings.Repeat("x", 25)+"\n" {
+		t.Errorf("backups = %q, want the old file", backups)
+	}
+	if got := readFile(t, path); got != "new line\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestRotateEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	target, err := New(path, WithFormatter(lineFormatter), WithRotateEvery(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send(t, target, "previous hour")
+	// As if the file was written in the previous interval
+	target.mu.Lock()
+	target.period = target.period.Add(-time.Hour)
+	target.mu.Unlock()
+	send(t, target, "this hour", "still this hour")
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if backups := readBackups(t, path); len(backups) != 1 || backups[0] != "previous hour\n" {
+		t.Errorf("backups = %q, want the file of the previous hour", backups)
+	}
+	if got := readFile(t, path); got != "this hour\nstill this hour\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestMaxBackups(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{"plain", false},
+		{"compressed", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			opts := []Option{WithFormatter(lineFormatter), WithMaxSize(10), WithMaxBackups(2)}
+			if tt.compress {
+				opts = append(opts, WithCompress())
+			}
+			target, err := New(path, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every line is rotated to its own backup
+			written := lines("line", 6)
+			send(t, target, written...)
+			if err := target.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			backups := readBackups(t, path)
+			if want := []string{written[3] + "\n", written[4] + "\n"}; strings.Join(backups, "|") != strings.Join(want, "|") {
+				t.Errorf("backups = %q, want the newest ones %q", backups, want)
+			}
+			if got := readFile(t, path); got != written[5]+"\n" {
+				t.Errorf("file = %q", got)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				name := entry.Name()
+				if strings.HasSuffix(name, ".tmp") {
+					t.Errorf("temporary file %s was left behind", name)
+				}
+				if name != "app.log" && strings.HasSuffix(name, ".gz") != tt.compress {
+					t.Errorf("backup %s, want compressed: %v", name, tt.compress)
+				}
+			}
+		})
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	target, err := New(path, WithFormatter(lineFormatter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	send(t, target, "before")
+	// As logrotate does
+	moved := filepath.Join(dir, "app.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	send(t, target, "still in the moved file")
+	if err := target.Reopen(); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	send(t, target, "after")
+
+	if got := readFile(t, moved); got != "before\nstill in the moved file\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		settings proxy.Settings
+	}{
+		{"missing path", proxy.Settings{}},
+		{"unknown format", proxy.Settings{"path": filepath.Join(dir, "a.log"), "format": "xml"}},
+		{"invalid rotate_every", proxy.Settings{"path": filepath.Join(dir, "b.log"), "rotate_every": "daily"}},
+		{"invalid file_mode", proxy.Settings{"path": filepath.Join(dir, "c.log"), "file_mode": "rw-r--r--"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := proxy.NewTarget("file", tt.settings); err == nil {
+				t.Error("NewTarget() error = nil, want an error")
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "app.log")
+	target, err := proxy.NewTarget("file", proxy.Settings{
+		"path":        path,
+		"format":      "logfmt",
+		"max_size":    int64(1 << 20),
+		"max_backups": int64(3),
+		"compress":    true,
+		"file_mode":   "0640",
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	defer target.(*fileLogger).Close()
+
+	l := target.(*fileLogger)
+	if l.maxSize != 1<<20 || l.maxBackups != 3 || !l.compress || l.fileMode != 0640 {
+		t.Errorf("max size, max backups, compress, mode = %d, %d, %v, %v", l.maxSize, l.maxBackups, l.compress, l.fileMode)
+	}
+}
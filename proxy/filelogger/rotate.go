@@ -0,0 +1,242 @@
+package filelogger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// open opens the file at the path for appending. l.mu must be held.
+func (l *fileLogger) open() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("filelogger: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, l.fileMode)
+	if err != nil {
+		return fmt.Errorf("filelogger: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("filelogger: %w", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	// A file left from before a restart belongs to the interval it was last written in
+	if l.size > 0 {
+		l.period = l.periodOf(info.ModTime())
+	} else {
+		l.period = l.periodOf(time.Now())
+	}
+	return nil
+}
+
+func (l *fileLogger) periodOf(t time.Time) time.Time {
+	if l.rotateEvery <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(l.rotateEvery)
+}
+
+// shouldRotate reports whether the file must be rotated before writing n bytes at now. l.mu must be held.
+func (l *fileLogger) shouldRotate(n int64, now time.Time) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.maxSize > 0 && l.size+n > l.maxSize {
+		return true
+	}
+	return l.rotateEvery > 0 && !l.periodOf(now).Equal(l.period)
+}
+
+// rotate moves the file to a backup named after the current time and opens a new file. l.mu must be held.
+func (l *fileLogger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("filelogger: %w", err)
+	}
+	l.file = nil
+
+	// Rotations within the same millisecond get the next free name
+	rotatedAt := time.Now()
+	for l.backupExists(rotatedAt) {
+		rotatedAt = rotatedAt.Add(time.Millisecond)
+	}
+	if err := os.Rename(l.path, l.backupPath(rotatedAt)); err != nil {
+		return fmt.Errorf("filelogger: %w", err)
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	select {
+	case l.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// backupPath returns the path of a backup rotated at t, "app.log" is rotated to "app-2006-01-02T15-04-05.000.log".
+func (l *fileLogger) backupPath(t time.Time) string {
+	prefix, ext := l.backupPrefixAndExt()
+	return prefix + t.UTC().Format(backupTimeFormat) + ext
+}
+
+func (l *fileLogger) backupExists(t time.Time) bool {
+	path := l.backupPath(t)
+	for _, p := range []string{path, path + ".gz"} {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *fileLogger) backupPrefixAndExt() (string, string) {
+	ext := filepath.Ext(l.path)
+	return strings.TrimSuffix(l.path, ext) + "-", ext
+}
+
+// runMill compresses and removes backups after rotations, one at a time.
+func (l *fileLogger) runMill() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case <-l.mill:
+			l.millBackups()
+		case <-l.done:
+			select {
+			case <-l.mill:
+				l.millBackups()
+			default:
+			}
+			return
+		}
+	}
+}
+
+func (l *fileLogger) millBackups() {
+	err := l.doMillBackups()
+	if err != nil {
+		err = fmt.Errorf("filelogger: %w", err)
+	}
+
+	l.mu.Lock()
+	l.millErr = err
+	l.mu.Unlock()
+}
+
+type backup struct {
+	path       string
+	time       time.Time
+	compressed bool
+}
+
+func (l *fileLogger) doMillBackups() error {
+	backups, err := l.backups()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if l.maxBackups > 0 && len(backups) > l.maxBackups {
+		for _, b := range backups[:len(backups)-l.maxBackups] {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+		backups = backups[len(backups)-l.maxBackups:]
+	}
+
+	if l.compress {
+		for _, b := range backups {
+			if b.compressed {
+				continue
+			}
+			if err := compressFile(b.path, l.fileMode); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// backups returns the rotated files of the path, oldest first.
+func (l *fileLogger) backups() ([]backup, error) {
+	prefix, ext := l.backupPrefixAndExt()
+	entries, err := os.ReadDir(filepath.Dir(l.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(filepath.Dir(l.path), entry.Name())
+		name, compressed := strings.CutSuffix(path, ".gz")
+		name, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, ext)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, name)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: path, time: t, compressed: compressed})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.Before(backups[j].time) })
+	return backups, nil
+}
+
+// compressFile gzips the file at path to path.gz and removes it.
+func compressFile(path string, mode os.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Written to a temporary file first, so that a crash doesn't leave a truncated archive behind
+	tmpPath := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,192 @@
+// Package format renders logs as text for proxy targets. Targets that send text accept a Formatter,
+// either one of the built-in formats or a text/template.
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"sadk.dev/logar/models"
+)
+
+// Formatter renders a log and its raw message, as passed to ProxyTarget.Send.
+type Formatter interface {
+	Format(log models.Log, rawMessage string) (string, error)
+}
+
+// FormatterFunc is a function used as a Formatter.
+type FormatterFunc func(log models.Log, rawMessage string) (string, error)
+
+func (f FormatterFunc) Format(log models.Log, rawMessage string) (string, error) {
+	return f(log, rawMessage)
+}
+
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+var (
+	// Text renders a single line such as
+	// 2006-01-02T15:04:05.000Z [ERROR] model/category: message key=value caller=main.go:10
+	Text Formatter = FormatterFunc(formatText)
+
+	// Logfmt renders a single line of key=value pairs, such as
+	// time=2006-01-02T15:04:05.000Z level=error model=app category=db msg="message" key=value
+	Logfmt Formatter = FormatterFunc(formatLogfmt)
+
+	// JSON renders a single line JSON object. Context values of structured messages keep their types.
+	JSON Formatter = FormatterFunc(formatJSON)
+
+	// Markdown renders a message for chats, with the severity emoji, model, category, time and context fields.
+	Markdown Formatter = MustTemplate(markdownTemplate)
+)
+
+var formatters = map[string]Formatter{
+	"text":     Text,
+	"logfmt":   Logfmt,
+	"json":     JSON,
+	"markdown": Markdown,
+}
+
+// ByName returns the built-in formatter named text, logfmt, json or markdown.
+func ByName(name string) (Formatter, bool) {
+	formatter, ok := formatters[strings.ToLower(name)]
+	return formatter, ok
+}
+
+// FromSettings returns the formatter of the format and template settings of a target. A template
+// takes precedence over the format, and fallback is returned if both are empty.
+func FromSettings(name, template string, fallback Formatter) (Formatter, error) {
+	if template != "" {
+		// Errors of text/template already start with "template:"
+		return NewTemplate(template)
+	}
+	if name == "" {
+		return fallback, nil
+	}
+
+	formatter, ok := ByName(name)
+	if !ok {
+		return nil, fmt.Errorf("format: unknown format %q, expected text, logfmt, json or markdown", name)
+	}
+	return formatter, nil
+}
+
+func formatText(log models.Log, rawMessage string) (string, error) {
+	message, fields := Split(rawMessage)
+
+	var buf bytes.Buffer
+	buf.WriteString(log.CreatedAt.Format(timeFormat))
+	buf.WriteString(" [" + strings.ToUpper(log.Severity.String()) + "] ")
+	buf.WriteString(string(log.Model))
+	if log.Category != "" {
+		buf.WriteString("/" + log.Category)
+	}
+	buf.WriteString(": ")
+	// Multiline messages would break line based shipping agents
+	buf.WriteString(strings.ReplaceAll(message, "\n", `\n`))
+	for _, field := range fields {
+		writeLogfmtPair(&buf, field.Name, field.Value)
+	}
+	if log.Caller != "" {
+		writeLogfmtPair(&buf, "caller", log.Caller)
+	}
+	if log.TraceID != "" {
+		writeLogfmtPair(&buf, "trace_id", log.TraceID)
+	}
+	return buf.String(), nil
+}
+
+func formatLogfmt(log models.Log, rawMessage string) (string, error) {
+	message, fields := Split(rawMessage)
+
+	var buf bytes.Buffer
+	buf.WriteString("time=" + log.CreatedAt.Format(timeFormat))
+	writeLogfmtPair(&buf, "level", strings.ToLower(log.Severity.String()))
+	writeLogfmtPair(&buf, "model", string(log.Model))
+	writeLogfmtPair(&buf, "category", log.Category)
+	writeLogfmtPair(&buf, "msg", message)
+	if log.Caller != "" {
+		writeLogfmtPair(&buf, "caller", log.Caller)
+	}
+	if log.TraceID != "" {
+		writeLogfmtPair(&buf, "trace_id", log.TraceID)
+	}
+	if log.SpanID != "" {
+		writeLogfmtPair(&buf, "span_id", log.SpanID)
+	}
+	for _, field := range fields {
+		writeLogfmtPair(&buf, field.Name, field.Value)
+	}
+	return buf.String(), nil
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key, value string) {
+	buf.WriteByte(' ')
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+	buf.WriteString(LogfmtValue(value))
+}
+
+// logfmtKey replaces the characters keys can't contain.
+func logfmtKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+	if key == "" {
+		return "_"
+	}
+	return key
+}
+
+// LogfmtValue quotes the value if it is empty or contains spaces, quotes, equal signs or control characters.
+func LogfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+type jsonLine struct {
+	Time       string                     `json:"time"`
+	Level      string                     `json:"level"`
+	Model      models.Model               `json:"model"`
+	Category   string                     `json:"category"`
+	Message    string                     `json:"message"`
+	ID         uint                       `json:"id,omitempty"`
+	Caller     string                     `json:"caller,omitempty"`
+	Function   string                     `json:"function,omitempty"`
+	StackTrace string                     `json:"stack_trace,omitempty"`
+	TraceID    string                     `json:"trace_id,omitempty"`
+	SpanID     string                     `json:"span_id,omitempty"`
+	Fields     map[string]json.RawMessage `json:"fields,omitempty"`
+}
+
+func formatJSON(log models.Log, rawMessage string) (string, error) {
+	message, fields := splitJSON(rawMessage)
+	data, err := json.Marshal(jsonLine{
+		Time:       log.CreatedAt.Format(timeFormat),
+		Level:      strings.ToLower(log.Severity.String()),
+		Model:      log.Model,
+		Category:   log.Category,
+		Message:    message,
+		ID:         log.ID,
+		Caller:     log.Caller,
+		Function:   log.Function,
+		StackTrace: log.StackTrace,
+		TraceID:    log.TraceID,
+		SpanID:     log.SpanID,
+		Fields:     fields,
+	})
+	return string(data), err
+}
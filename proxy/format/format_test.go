@@ -0,0 +1,212 @@
+package format
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+)
+
+var testLog = models.Log{
+	ID:        7,
+	CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	Model:     "payments",
+	Category:  "checkout",
+	Severity:  models.Severity_Error,
+	Caller:    "main.go:12",
+	TraceID:   "abc",
+}
+
+const structured = `{"message":"card declined","amount":12,"card":{"last4":"4242"},"note":"two words"}`
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter Formatter
+		message   string
+		want      string
+	}{
+		{
+			name:      "text",
+			formatter: Text,
+			message:   structured,
+			want:      `2024-01-02T03:04:05.000Z [ERROR] payments/checkout: card declined amount=12 card="{\"last4\":\"4242\"}" note="two words" caller=main.go:12 trace_id=abc`,
+		},
+		{
+			name:      "text keeps a line",
+			formatter: Text,
+			message:   "first\nsecond",
+			want:      `2024-01-02T03:04:05.000Z [ERROR] payments/checkout: first\nsecond caller=main.go:12 trace_id=abc`,
+		},
+		{
+			name:      "logfmt",
+			formatter: Logfmt,
+			message:   structured,
+			want:      `time=2024-01-02T03:04:05.000Z level=error model=payments category=checkout msg="card declined" caller=main.go:12 trace_id=abc amount=12 card="{\"last4\":\"4242\"}" note="two words"`,
+		},
+		{
+			name:      "logfmt of a plain message",
+			formatter: Logfmt,
+			message:   `a "quoted" = sign`,
+			want:      `time=2024-01-02T03:04:05.000Z level=error model=payments category=checkout msg="a \"quoted\" = sign" caller=main.go:12 trace_id=abc`,
+		},
+		{
+			name:      "markdown",
+			formatter: Markdown,
+			message:   `{"message":"card declined (retry)","order_id":"a-1"}`,
+			want: "❌ *ERROR* payments / checkout\n" +
+				"_2024\\-01\\-02 03:04:05 UTC_\n\n" +
+				"card declined \\(retry\\)\n" +
+				"• *order\\_id*: a\\-1\n" +
+				"• *caller*: `main.go:12`\n" +
+				"• *trace*: `abc`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.formatter.Format(testLog, tt.message)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "structured message",
+			message: structured,
+			// Context values keep their JSON types
+			want: `{"time":"2024-01-02T03:04:05.000Z","level":"error","model":"payments","category":"checkout",` +
+				`"message":"card declined","id":7,"caller":"main.go:12","trace_id":"abc",` +
+				`"fields":{"amount":12,"card":{"last4":"4242"},"note":"two words"}}`,
+		},
+		{
+			name:    "plain message",
+			message: "card declined\n",
+			want: `{"time":"2024-01-02T03:04:05.000Z","level":"error","model":"payments","category":"checkout",` +
+				`"message":"card declined","id":7,"caller":"main.go:12","trace_id":"abc"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSON.Format(testLog, tt.message)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if strings.Contains(got, "\n") {
+				t.Errorf("Format() = %q, want a single line", got)
+			}
+
+			var gotValue, wantValue any
+			if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+				t.Fatalf("Format() = %q is not JSON: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("Format() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	formatter, err := NewTemplate(`{{emoji .Log.Severity}} [{{upper .Severity}}] {{.Log.Model}}: {{truncate 10 .Message}}{{range .Fields}} {{.Name}}={{logfmt .Value}}{{end}} {{color .Log.Severity}}`)
+	if err != nil {
+		t.Fatalf("NewTemplate() error = %v", err)
+	}
+	got, err := formatter.Format(testLog, structured)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	want := `❌ [ERROR] payments: card decl… amount=12 card="{\"last4\":\"4242\"}" note="two words" #f44336`
+	if got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	for _, text := range []string{"{{.Message", "{{shout .Message}}"} {
+		if _, err := NewTemplate(text); err == nil {
+			t.Errorf("NewTemplate(%q) error = nil, want an error", text)
+		}
+	}
+	if _, err := MustTemplate("{{.Missing}}").Format(testLog, "message"); err == nil {
+		t.Error("Format() of an unknown field error = nil, want an error")
+	}
+}
+
+func TestFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "fallback", want: "fallback"},
+		{name: "format", format: "LOGFMT", want: "time=2024-01-02T03:04:05.000Z"},
+		{name: "template", format: "json", template: "{{.Severity}}", want: "Error"},
+		{name: "unknown format", format: "xml", wantErr: true},
+		{name: "invalid template", template: "{{.Message", wantErr: true},
+	}
+
+	fallback := FormatterFunc(func(models.Log, string) (string, error) { return "fallback", nil })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := FromSettings(tt.format, tt.template, fallback)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("FromSettings() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromSettings() error = %v", err)
+			}
+			got, err := formatter.Format(testLog, "message")
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("Format() = %q, want it to start with %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello world", 5, "hell…"},
+		{"héllo wörld", 7, "héllo …"},
+		{"日本語のテキスト", 4, "日本語…"},
+		{"hello", 1, "h"},
+		{"hello", 0, ""},
+		{"hello", -1, ""},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
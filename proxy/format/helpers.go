@@ -0,0 +1,139 @@
+package format
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"sadk.dev/logar/models"
+)
+
+// Field is a context value of a log, such as a request id.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Split returns the message of a raw log message and its context fields. Structured messages are
+// JSON objects, their "message" key is the message and the other keys are fields, sorted by name.
+// Other messages are returned as they are.
+func Split(rawMessage string) (string, []Field) {
+	var values map[string]json.RawMessage
+	if !strings.HasPrefix(strings.TrimSpace(rawMessage), "{") || json.Unmarshal([]byte(rawMessage), &values) != nil {
+		return strings.TrimSpace(rawMessage), nil
+	}
+
+	message, ok := values["message"]
+	if !ok {
+		// A structured message without context values, show it whole
+		return strings.TrimSpace(rawMessage), nil
+	}
+	delete(values, "message")
+
+	fields := make([]Field, 0, len(values))
+	for name, value := range values {
+		fields = append(fields, Field{Name: name, Value: valueString(value)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	return valueString(message), fields
+}
+
+// splitJSON is Split keeping the JSON values of the fields, for formats that can hold them.
+func splitJSON(rawMessage string) (string, map[string]json.RawMessage) {
+	var values map[string]json.RawMessage
+	if json.Unmarshal([]byte(rawMessage), &values) != nil {
+		return strings.TrimSpace(rawMessage), nil
+	}
+	message, ok := values["message"]
+	if !ok {
+		return strings.TrimSpace(rawMessage), nil
+	}
+	delete(values, "message")
+	if len(values) == 0 {
+		values = nil
+	}
+	return valueString(message), values
+}
+
+func valueString(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	return string(value)
+}
+
+// Color returns the RGB color of the severity, as used for attachment bars and embeds.
+func Color(severity models.Severity) int {
+	switch severity {
+	case models.Severity_Trace, models.Severity_Log:
+		return 0x9e9e9e
+	case models.Severity_Info:
+		return 0x2196f3
+	case models.Severity_Warning:
+		return 0xff9800
+	case models.Severity_Error:
+		return 0xf44336
+	case models.Severity_Fatal:
+		return 0x8e0000
+	}
+	return 0x607d8b
+}
+
+// Emoji returns an emoji standing for the severity in chat messages.
+func Emoji(severity models.Severity) string {
+	switch severity {
+	case models.Severity_Trace:
+		return "🔍"
+	case models.Severity_Log:
+		return "📝"
+	case models.Severity_Info:
+		return "ℹ️"
+	case models.Severity_Warning:
+		return "⚠️"
+	case models.Severity_Error:
+		return "❌"
+	case models.Severity_Fatal:
+		return "🔥"
+	}
+	return "•"
+}
+
+// Truncate shortens s to at most max characters, ending it with an ellipsis if it was cut.
+func Truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	if max <= 0 {
+		return ""
+	}
+	if max == 1 {
+		return string([]rune(s)[:max])
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
+
+// LogsURL returns the link to the logs of the model in the web panel served at panelURL,
+// or an empty string if panelURL is empty.
+func LogsURL(panelURL string, model models.Model) string {
+	if panelURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(panelURL, "/") + "/logs?model=" + url.QueryEscape(string(model))
+}
+
+// EscapeMarkdown escapes the characters Markdown uses for formatting. The escaped characters are
+// the ones of Telegram's MarkdownV2, which is a superset of what CommonMark needs.
+func EscapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\_*[]()~`>#+-=|{}.!", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
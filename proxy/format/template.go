@@ -0,0 +1,104 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"sadk.dev/logar/models"
+)
+
+// Data is what templates are executed with.
+type Data struct {
+	Log        models.Log
+	RawMessage string  // the message as passed to the target
+	Message    string  // the message of structured messages, or the whole message
+	Fields     []Field // the context values of structured messages, sorted by name
+	Severity   string  // the name of the severity, such as "Error"
+}
+
+// Funcs are the functions available in templates:
+//
+//	upper, lower, trim      change strings
+//	truncate N s            shortens s to N characters
+//	emoji severity          an emoji for the severity
+//	color severity          the color of the severity as "#rrggbb"
+//	json v                  v as JSON
+//	logfmt s                s quoted for logfmt if needed
+//	markdown s              s with Markdown characters escaped
+//	logsURL panelURL model  the link to the logs of the model in the web panel
+var Funcs = template.FuncMap{
+	"upper": func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+	"lower": func(v any) string { return strings.ToLower(fmt.Sprint(v)) },
+	"trim":  func(v any) string { return strings.TrimSpace(fmt.Sprint(v)) },
+	"truncate": func(max int, v any) string {
+		return Truncate(fmt.Sprint(v), max)
+	},
+	"emoji": Emoji,
+	"color": func(severity models.Severity) string {
+		return fmt.Sprintf("#%06x", Color(severity))
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"logfmt":   func(v any) string { return LogfmtValue(fmt.Sprint(v)) },
+	"markdown": func(v any) string { return EscapeMarkdown(fmt.Sprint(v)) },
+	"logsURL":  LogsURL,
+}
+
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+// NewTemplate parses a text/template executed with Data for every log, for example
+//
+//	{{emoji .Log.Severity}} [{{upper .Severity}}] {{.Log.Model}}: {{truncate 200 .Message}}
+func NewTemplate(text string) (Formatter, error) {
+	tmpl, err := template.New("format").Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &templateFormatter{tmpl: tmpl}, nil
+}
+
+// MustTemplate is NewTemplate panicking if the template is invalid, for templates defined in code.
+func MustTemplate(text string) Formatter {
+	formatter, err := NewTemplate(text)
+	if err != nil {
+		panic(err)
+	}
+	return formatter
+}
+
+// NewData returns the template data of a log.
+func NewData(log models.Log, rawMessage string) Data {
+	message, fields := Split(rawMessage)
+	return Data{
+		Log:        log,
+		RawMessage: rawMessage,
+		Message:    message,
+		Fields:     fields,
+		Severity:   log.Severity.String(),
+	}
+}
+
+func (f *templateFormatter) Format(log models.Log, rawMessage string) (string, error) {
+	var buf strings.Builder
+	if err := f.tmpl.Execute(&buf, NewData(log, rawMessage)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const markdownTemplate = `{{emoji .Log.Severity}} *{{upper .Severity}}* {{markdown .Log.Model}}{{with .Log.Category}} / {{markdown .}}{{end}}
+_{{markdown (.Log.CreatedAt.Format "2006-01-02 15:04:05 MST")}}_
+
+{{markdown .Message}}
+{{- range .Fields}}
+• *{{markdown .Name}}*: {{markdown .Value}}{{end}}
+{{- with .Log.Caller}}
+• *caller*: ` + "`{{.}}`" + `{{end}}
+{{- with .Log.TraceID}}
+• *trace*: ` + "`{{.}}`" + `{{end}}`
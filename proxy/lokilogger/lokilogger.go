@@ -0,0 +1,384 @@
+// Package lokilogger pushes logs to Grafana Loki in batches, labeled by model, category and severity.
+package lokilogger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/internal/batch"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+const pushPath = "/loki/api/v1/push"
+
+var ErrQueueFull = errors.New("lokilogger: queue is full, log dropped")
+
+func init() {
+	proxy.RegisterTarget("loki", newFromSettings, "password", "headers")
+}
+
+type Option func(*lokiLogger)
+
+// WithLabel adds a static label to every stream, such as "env" or "host".
+func WithLabel(name, value string) Option {
+	return func(l *lokiLogger) {
+		l.labels[name] = value
+	}
+}
+
+// WithTenantID sets the X-Scope-OrgID header of multi-tenant Loki installations.
+func WithTenantID(tenantID string) Option {
+	return func(l *lokiLogger) {
+		if tenantID != "" {
+			l.header.Set("X-Scope-OrgID", tenantID)
+		}
+	}
+}
+
+// WithBasicAuth authenticates every push, e.g. for Grafana Cloud.
+func WithBasicAuth(username, password string) Option {
+	return func(l *lokiLogger) {
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		l.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// WithFormatter sets how the log lines are written. By default lines are JSON objects of the message,
+// context values, caller and trace, so that LogQL's json parser extracts them.
+func WithFormatter(formatter format.Formatter) Option {
+	return func(l *lokiLogger) {
+		l.formatter = formatter
+	}
+}
+
+// WithHeader adds a header to every push request.
+func WithHeader(key, value string) Option {
+	return func(l *lokiLogger) {
+		l.header.Set(key, value)
+	}
+}
+
+// WithGzip compresses push requests.
+func WithGzip() Option {
+	return func(l *lokiLogger) {
+		l.gzip = true
+	}
+}
+
+// WithBatchSize sets how many logs are pushed in a single request. Defaults to 100.
+func WithBatchSize(size int) Option {
+	return func(l *lokiLogger) {
+		l.batch.BatchSize = size
+	}
+}
+
+// WithFlushInterval sets how often queued logs are pushed, even if the batch isn't full. Defaults to 5 seconds.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(l *lokiLogger) {
+		l.batch.FlushInterval = interval
+	}
+}
+
+// WithMaxQueueSize sets how many logs can wait to be pushed. Logs sent to a full queue are dropped. Defaults to 10000.
+func WithMaxQueueSize(size int) Option {
+	return func(l *lokiLogger) {
+		l.batch.MaxQueueSize = size
+	}
+}
+
+// WithRetry sets how many times a failed push is retried and the delay before the first retry,
+// which doubles after every attempt. Defaults to 5 retries starting at 500ms.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(l *lokiLogger) {
+		l.maxRetries = max(maxRetries, 0)
+		if backoff > 0 {
+			l.backoff = backoff
+		}
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *lokiLogger) {
+		l.client = client
+	}
+}
+
+// New creates a target pushing logs to the Loki server at endpoint, such as "http://localhost:3100".
+// Logs are pushed in the background, Close pushes the remaining ones. Proxies close their target when the app is closed.
+func New(endpoint string, opts ...Option) (*lokiLogger, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("lokilogger: invalid endpoint %q", endpoint)
+	}
+
+	l := &lokiLogger{
+		labels:     map[string]string{},
+		formatter:  format.FormatterFunc(line),
+		header:     http.Header{},
+		maxRetries: 5,
+		backoff:    500 * time.Millisecond,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.queue = batch.NewQueue(l.batch, l.Push)
+	l.poster = &batch.Poster{
+		URL:         strings.TrimSuffix(endpoint, "/") + pushPath,
+		ContentType: "application/json",
+		Header:      l.header,
+		Gzip:        l.gzip,
+		MaxRetries:  l.maxRetries,
+		Backoff:     l.backoff,
+		Client:      l.client,
+		Done:        l.queue.Done(),
+	}
+
+	return l, nil
+}
+
+type lokiLogger struct {
+	labels     map[string]string
+	formatter  format.Formatter
+	header     http.Header
+	gzip       bool
+	batch      batch.Config
+	maxRetries int
+	backoff    time.Duration
+	client     *http.Client
+
+	queue  *batch.Queue[Entry]
+	poster *batch.Poster
+}
+
+// Entry is a log waiting to be pushed.
+type Entry = proxy.Entry
+
+func (l *lokiLogger) Send(log models.Log, rawMessage string) error {
+	if !l.queue.Add(Entry{Log: log, RawMessage: rawMessage}) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// OnResult passes the result of every push made from the queue to fn.
+func (l *lokiLogger) OnResult(fn func(proxy.BatchResult)) {
+	l.queue.OnResult(func(entries []Entry, err error, elapsed time.Duration) {
+		result := proxy.BatchResult{Entries: entries, Err: err, Latency: elapsed}
+		if err != nil {
+			result.Failed = entries
+		}
+		fn(result)
+	})
+}
+
+// Flush pushes all queued logs and waits for the requests to finish.
+func (l *lokiLogger) Flush() error {
+	return l.queue.Flush()
+}
+
+// Close stops the background worker and pushes the remaining logs.
+func (l *lokiLogger) Close() error {
+	return l.queue.Close()
+}
+
+// Push sends the logs in a single request, bypassing the queue.
+func (l *lokiLogger) Push(entries []Entry) error {
+	req, err := l.pushRequest(entries)
+	if err != nil {
+		return fmt.Errorf("lokilogger: %w", err)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := l.poster.Post(body); err != nil {
+		return fmt.Errorf("lokilogger: %w", err)
+	}
+	return nil
+}
+
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"` // unix nanoseconds and line
+}
+
+func (l *lokiLogger) pushRequest(entries []Entry) (pushRequest, error) {
+	// Loki rejects entries older than the last one of a stream in some configurations
+	entries = slices.Clone(entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Log.CreatedAt.Before(entries[j].Log.CreatedAt)
+	})
+
+	var req pushRequest
+	byLabels := map[string]int{}
+	for _, entry := range entries {
+		labels := l.streamLabels(entry.Log)
+		key := labelsKey(labels)
+
+		i, ok := byLabels[key]
+		if !ok {
+			i = len(req.Streams)
+			byLabels[key] = i
+			req.Streams = append(req.Streams, stream{Stream: labels})
+		}
+
+		text, err := l.formatter.Format(entry.Log, entry.RawMessage)
+		if err != nil {
+			return pushRequest{}, err
+		}
+
+		timestamp := entry.Log.CreatedAt
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		req.Streams[i].Values = append(req.Streams[i].Values, [2]string{
+			strconv.FormatInt(timestamp.UnixNano(), 10),
+			text,
+		})
+	}
+	return req, nil
+}
+
+func (l *lokiLogger) streamLabels(log models.Log) map[string]string {
+	labels := make(map[string]string, len(l.labels)+3)
+	for name, value := range l.labels {
+		labels[name] = value
+	}
+	labels["model"] = string(log.Model)
+	labels["level"] = strings.ToLower(log.Severity.String())
+	// Loki drops labels with empty values
+	if log.Category != "" {
+		labels["category"] = log.Category
+	}
+	return labels
+}
+
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(strconv.Quote(name) + "=" + strconv.Quote(labels[name]) + ",")
+	}
+	return key.String()
+}
+
+// line returns the log as a JSON object, so that LogQL's json parser extracts its context values.
+func line(log models.Log, rawMessage string) (string, error) {
+	values := map[string]any{}
+	decoder := json.NewDecoder(strings.NewReader(rawMessage))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil || values["message"] == nil {
+		values = map[string]any{"message": strings.TrimSpace(rawMessage)}
+	}
+
+	if log.Caller != "" {
+		values["caller"] = log.Caller
+	}
+	if log.TraceID != "" {
+		values["trace_id"] = log.TraceID
+	}
+	if log.SpanID != "" {
+		values["span_id"] = log.SpanID
+	}
+	if log.StackTrace != "" {
+		values["stack_trace"] = log.StackTrace
+	}
+	if log.Fingerprint != "" {
+		values["fingerprint"] = log.Fingerprint
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type settings struct {
+	Endpoint      string            `json:"endpoint"`
+	Labels        map[string]string `json:"labels"`
+	TenantID      string            `json:"tenant_id"`
+	Format        string            `json:"format"`   // text, logfmt, json or markdown instead of the default JSON lines
+	Template      string            `json:"template"` // text/template, see format.NewTemplate
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	Headers       map[string]string `json:"headers"`
+	Gzip          bool              `json:"gzip"`
+	BatchSize     int               `json:"batch_size"`
+	FlushInterval string            `json:"flush_interval"` // such as "5s"
+	MaxQueueSize  int               `json:"max_queue_size"`
+	MaxRetries    *int              `json:"max_retries"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+
+	opts := []Option{
+		WithTenantID(cfg.TenantID),
+		WithBatchSize(cfg.BatchSize),
+		WithMaxQueueSize(cfg.MaxQueueSize),
+	}
+	if cfg.Format != "" || cfg.Template != "" {
+		formatter, err := format.FromSettings(cfg.Format, cfg.Template, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithFormatter(formatter))
+	}
+	for name, value := range cfg.Labels {
+		opts = append(opts, WithLabel(name, value))
+	}
+	if cfg.Username != "" {
+		opts = append(opts, WithBasicAuth(cfg.Username, cfg.Password))
+	}
+	for key, value := range cfg.Headers {
+		opts = append(opts, WithHeader(key, value))
+	}
+	if cfg.Gzip {
+		opts = append(opts, WithGzip())
+	}
+	if cfg.FlushInterval != "" {
+		interval, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("flush_interval: %w", err)
+		}
+		opts = append(opts, WithFlushInterval(interval))
+	}
+	if cfg.MaxRetries != nil {
+		opts = append(opts, WithRetry(*cfg.MaxRetries, 0))
+	}
+
+	target, err := New(cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,284 @@
+package lokilogger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// server is a Loki push endpoint recording the requests it receives. Requests are answered with
+// the statuses in turn, and with 204 once they run out.
+type server struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests []pushRequest
+	headers  []http.Header
+	statuses []int
+}
+
+func newServer(t *testing.T, statuses ...int) (*server, string) {
+	t.Helper()
+	s := &server{t: t, statuses: statuses}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts.URL
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != pushPath {
+		s.t.Errorf("path = %q, want %q", r.URL.Path, pushPath)
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.t.Errorf("invalid gzip body: %v", err)
+			return
+		}
+		body = gz
+	}
+	var req pushRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		s.t.Errorf("invalid body: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, r.Header.Clone())
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		http.Error(w, "entry out of order", status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newTarget returns a target pushing only when flushed.
+func newTarget(t *testing.T, url string, opts ...Option) *lokiLogger {
+	t.Helper()
+	opts = append([]Option{WithFlushInterval(time.Hour), WithRetry(2, time.Millisecond)}, opts...)
+	target, err := New(url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { target.Close() })
+	return target
+}
+
+func TestPushBody(t *testing.T) {
+	srv, url := newServer(t)
+	target := newTarget(t, url, WithLabel("env", "test"), WithTenantID("team-a"), WithBasicAuth("user", "secret"), WithGzip())
+
+	logs := []struct {
+		log     models.Log
+		message string
+	}{
+		{models.Log{Model: "payments", Category: "checkout", Severity: models.Severity_Error, CreatedAt: start.Add(2 * time.Second), TraceID: "abc"}, `{"message":"declined","order":42}`},
+		{models.Log{Model: "payments", Severity: models.Severity_Info, CreatedAt: start.Add(time.Second)}, "plain text\n"},
+		{models.Log{Model: "payments", Category: "checkout", Severity: models.Severity_Error, CreatedAt: start}, "timeout"},
+	}
+	for _, l := range logs {
+		if err := target.Send(l.log, l.message); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := target.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if len(srv.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(srv.requests))
+	}
+	header := srv.headers[0]
+	if got := header.Get("X-Scope-OrgID"); got != "team-a" {
+		t.Errorf("X-Scope-OrgID = %q", got)
+	}
+	if user, password, ok := (&http.Request{Header: header}).BasicAuth(); !ok || user != "user" || password != "secret" {
+		t.Errorf("basic auth = %q, %q, %v", user, password, ok)
+	}
+
+	// Streams are in the order of their oldest log, and values are sorted by time
+	streams := srv.requests[0].Streams
+	want := []stream{
+		{
+			Stream: map[string]string{"env": "test", "model": "payments", "category": "checkout", "level": "error"},
+			Values: [][2]string{
+				{strconv.FormatInt(start.UnixNano(), 10), `{"message":"timeout"}`},
+				{strconv.FormatInt(start.Add(2*time.Second).UnixNano(), 10), `{"message":"declined","order":42,"trace_id":"abc"}`},
+			},
+		},
+		{
+			// Loki drops labels with empty values, so there is no category
+			Stream: map[string]string{"env": "test", "model": "payments", "level": "info"},
+			Values: [][2]string{
+				{strconv.FormatInt(start.Add(time.Second).UnixNano(), 10), `{"message":"plain text"}`},
+			},
+		},
+	}
+	if len(streams) != len(want) {
+		t.Fatalf("streams = %+v, want %+v", streams, want)
+	}
+	for i := range want {
+		if labelsKey(streams[i].Stream) != labelsKey(want[i].Stream) {
+			t.Errorf("stream %d labels = %v, want %v", i, streams[i].Stream, want[i].Stream)
+		}
+		if len(streams[i].Values) != len(want[i].Values) {
+			t.Errorf("stream %d values = %q, want %q", i, streams[i].Values, want[i].Values)
+			continue
+		}
+		for j := range want[i].Values {
+			if streams[i].Values[j] != want[i].Values[j] {
+				t.Errorf("stream %d value %d = %q, want %q", i, j, streams[i].Values[j], want[i].Values[j])
+			}
+		}
+	}
+}
+
+func TestPushBatches(t *testing.T) {
+	srv, url := newServer(t)
+	target := newTarget(t, url, WithBatchSize(2))
+
+	var mu sync.Mutex
+	var results []proxy.BatchResult
+	target.OnResult(func(result proxy.BatchResult) {
+		mu.Lock()
+		results = append(results, result)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 5; i++ {
+		if err := target.Send(models.Log{Model: "app", CreatedAt: start.Add(time.Duration(i) * time.Second)}, "message"); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	pushed := 0
+	for _, req := range srv.requests {
+		for _, s := range req.Streams {
+			if len(s.Values) > 2 {
+				t.Errorf("got %d values in a request, want at most the batch size", len(s.Values))
+			}
+			pushed += len(s.Values)
+		}
+	}
+	if pushed != 5 {
+		t.Errorf("pushed %d logs, want 5", pushed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, result := range results {
+		if result.Err != nil || len(result.Failed) != 0 {
+			t.Errorf("result error = %v, failed = %d, want success", result.Err, len(result.Failed))
+		}
+	}
+}
+
+func TestPushFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		wantPushes int
+		wantFailed bool
+	}{
+		{"rejected", []int{http.StatusBadRequest}, 1, true},
+		{"retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, false},
+		{"retries run out", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, url := newServer(t, tt.statuses...)
+			target := newTarget(t, url)
+
+			var results []proxy.BatchResult
+			target.OnResult(func(result proxy.BatchResult) {
+				results = append(results, result)
+			})
+
+			for i := 0; i < 3; i++ {
+				if err := target.Send(models.Log{Model: "app", CreatedAt: start}, "message"); err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+			err := target.Flush()
+			if (err != nil) != tt.wantFailed {
+				t.Errorf("Flush() error = %v, want an error: %v", err, tt.wantFailed)
+			}
+
+			if len(srv.requests) != tt.wantPushes {
+				t.Errorf("got %d pushes, want %d", len(srv.requests), tt.wantPushes)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			result := results[0]
+			if len(result.Entries) != 3 {
+				t.Errorf("got %d entries, want 3", len(result.Entries))
+			}
+			// Loki accepts or rejects a push as a whole
+			if want := map[bool]int{true: 3, false: 0}[tt.wantFailed]; len(result.Failed) != want {
+				t.Errorf("got %d failed entries, want %d", len(result.Failed), want)
+			}
+			if (result.Err != nil) != tt.wantFailed {
+				t.Errorf("result error = %v, want an error: %v", result.Err, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings proxy.Settings
+	}{
+		{"missing endpoint", proxy.Settings{}},
+		{"invalid endpoint", proxy.Settings{"endpoint": "localhost:3100"}},
+		{"unknown format", proxy.Settings{"endpoint": "http://localhost:3100", "format": "xml"}},
+		{"invalid flush_interval", proxy.Settings{"endpoint": "http://localhost:3100", "flush_interval": "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := proxy.NewTarget("loki", tt.settings); err == nil {
+				t.Error("NewTarget() error = nil, want an error")
+			}
+		})
+	}
+
+	target, err := proxy.NewTarget("loki", proxy.Settings{
+		"endpoint":    "http://localhost:3100/",
+		"labels":      map[string]any{"env": "prod"},
+		"max_retries": int64(0),
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	l := target.(*lokiLogger)
+	defer l.Close()
+
+	if l.poster.URL != "http://localhost:3100"+pushPath {
+		t.Errorf("url = %q", l.poster.URL)
+	}
+	if l.labels["env"] != "prod" || l.maxRetries != 0 {
+		t.Errorf("labels, max retries = %v, %d", l.labels, l.maxRetries)
+	}
+}
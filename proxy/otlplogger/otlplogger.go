@@ -0,0 +1,492 @@
+// Package otlplogger forwards logs and spans to an OpenTelemetry collector over OTLP/HTTP.
+package otlplogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/internal/batch"
+	"sadk.dev/logar/internal/otlp"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+const scopeName = "sadk.dev/logar"
+
+var ErrQueueFull = errors.New("otlplogger: queue is full, log dropped")
+
+func init() {
+	proxy.RegisterTarget("otlp", newFromSettings, "headers")
+}
+
+type Option func(*otlpLogger)
+
+// WithJSON sends OTLP/JSON instead of the default binary protobuf encoding.
+func WithJSON() Option {
+	return func(l *otlpLogger) {
+		l.json = true
+	}
+}
+
+// WithHeader adds a header to every export request, e.g. for collector authentication.
+func WithHeader(key, value string) Option {
+	return func(l *otlpLogger) {
+		l.headers.Set(key, value)
+	}
+}
+
+// WithServiceName sets the service.name resource attribute. By default the log model is used.
+func WithServiceName(name string) Option {
+	return func(l *otlpLogger) {
+		l.serviceName = name
+	}
+}
+
+// WithBatchSize sets how many logs are sent in a single export request. Defaults to 100.
+func WithBatchSize(size int) Option {
+	return func(l *otlpLogger) {
+		l.batch.BatchSize = size
+	}
+}
+
+// WithFlushInterval sets how often queued logs are sent, even if the batch isn't full. Defaults to 5 seconds.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(l *otlpLogger) {
+		l.batch.FlushInterval = interval
+	}
+}
+
+// WithMaxQueueSize sets how many logs can wait to be exported. Logs sent to a full queue are dropped. Defaults to 10000.
+func WithMaxQueueSize(size int) Option {
+	return func(l *otlpLogger) {
+		l.batch.MaxQueueSize = size
+	}
+}
+
+// WithRetry sets how many times a failed export is retried and the delay before the first retry,
+// which doubles after every attempt. Defaults to 5 retries starting at 500ms.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(l *otlpLogger) {
+		l.maxRetries = max(maxRetries, 0)
+		if backoff > 0 {
+			l.backoff = backoff
+		}
+	}
+}
+
+// WithSpanErrorHandler sets a function called when a batch of spans couldn't be exported from the
+// background. Failures of logs are reported to the proxy, which stores the logs as dead letters.
+func WithSpanErrorHandler(fn func(spans []models.Span, err error)) Option {
+	return func(l *otlpLogger) {
+		l.spanErrorHandler = fn
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *otlpLogger) {
+		l.client = client
+	}
+}
+
+// New creates an OTLP/HTTP exporter. endpoint is the base URL of the collector, such as
+// "http://localhost:4318"; logs are sent to /v1/logs and spans to /v1/traces.
+// The exporter batches logs in the background, Close sends the remaining ones. Proxies close their target when the
+// app is closed, exporters used for spans only must be closed by the caller.
+func New(endpoint string, opts ...Option) *otlpLogger {
+	l := &otlpLogger{
+		headers:    http.Header{},
+		maxRetries: 5,
+		backoff:    500 * time.Millisecond,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	contentType := otlp.ContentTypeProtobuf
+	if l.json {
+		contentType = otlp.ContentTypeJSON
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	l.logs = batch.NewQueue(l.batch, l.exportEntries)
+	l.spans = batch.NewQueue(l.batch, l.ExportSpans)
+	if l.spanErrorHandler != nil {
+		l.spans.OnResult(func(spans []models.Span, err error, _ time.Duration) {
+			if err != nil {
+				l.spanErrorHandler(spans, err)
+			}
+		})
+	}
+	l.logsPoster = &batch.Poster{
+		URL:         endpoint + otlp.LogsPath,
+		ContentType: contentType,
+		Header:      l.headers,
+		MaxRetries:  l.maxRetries,
+		Backoff:     l.backoff,
+		Client:      l.client,
+		Done:        l.logs.Done(),
+	}
+	l.tracesPoster = &batch.Poster{
+		URL:         endpoint + otlp.TracesPath,
+		ContentType: contentType,
+		Header:      l.headers,
+		MaxRetries:  l.maxRetries,
+		Backoff:     l.backoff,
+		Client:      l.client,
+		Done:        l.spans.Done(),
+	}
+
+	return l
+}
+
+type otlpLogger struct {
+	headers     http.Header
+	json        bool
+	serviceName string
+	batch       batch.Config
+	maxRetries  int
+	backoff     time.Duration
+	client      *http.Client
+
+	spanErrorHandler func(spans []models.Span, err error)
+
+	logs         *batch.Queue[proxy.Entry]
+	spans        *batch.Queue[models.Span]
+	logsPoster   *batch.Poster
+	tracesPoster *batch.Poster
+}
+
+func (l *otlpLogger) Send(log models.Log, rawMessage string) error {
+	if !l.logs.Add(proxy.Entry{Log: log, RawMessage: rawMessage}) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// OnResult passes the result of every log export made from the queue to fn. Logs rejected in a
+// partial success aren't identified by the collector, so only the error is reported for them.
+func (l *otlpLogger) OnResult(fn func(proxy.BatchResult)) {
+	l.logs.OnResult(func(entries []proxy.Entry, err error, elapsed time.Duration) {
+		result := proxy.BatchResult{Entries: entries, Err: err, Latency: elapsed}
+		var partialErr *PartialError
+		if err != nil && !errors.As(err, &partialErr) {
+			result.Failed = entries
+		}
+		fn(result)
+	})
+}
+
+// ExportSpan queues a finished span for export. It can be passed to logar.WithSpanExporter.
+func (l *otlpLogger) ExportSpan(span models.Span) error {
+	if !l.spans.Add(span) {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// Flush sends all queued logs and spans and waits for the export requests to finish.
+func (l *otlpLogger) Flush() error {
+	return errors.Join(l.logs.Flush(), l.spans.Flush())
+}
+
+// Close stops the background workers and sends the remaining logs and spans.
+func (l *otlpLogger) Close() error {
+	return errors.Join(l.logs.Close(), l.spans.Close())
+}
+
+func (l *otlpLogger) exportEntries(entries []proxy.Entry) error {
+	logs := make([]models.Log, len(entries))
+	for i, entry := range entries {
+		logs[i] = entry.Log
+	}
+	return l.ExportLogs(logs)
+}
+
+// ExportLogs sends the logs in a single request, bypassing the queue. If the collector rejected some
+// of them, a *PartialError is returned.
+func (l *otlpLogger) ExportLogs(logs []models.Log) error {
+	req := l.logsRequest(logs)
+	body, err := l.marshal(req, func() []byte { return otlp.MarshalLogs(req) })
+	if err != nil {
+		return err
+	}
+	response, err := post(l.logsPoster, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := decodeResponse(l.json, response, otlp.UnmarshalLogsResponse)
+	if err != nil {
+		return fmt.Errorf("otlplogger: invalid response: %w", err)
+	}
+	if partial := resp.PartialSuccess; partial != nil && partial.RejectedLogRecords > 0 {
+		return &PartialError{Rejected: int(partial.RejectedLogRecords), Total: len(logs), Message: partial.ErrorMessage}
+	}
+	return nil
+}
+
+// ExportSpans sends the spans in a single request, bypassing the queue. If the collector rejected some
+// of them, a *PartialError is returned.
+func (l *otlpLogger) ExportSpans(spans []models.Span) error {
+	req := l.tracesRequest(spans)
+	body, err := l.marshal(req, func() []byte { return otlp.MarshalTraces(req) })
+	if err != nil {
+		return err
+	}
+	response, err := post(l.tracesPoster, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := decodeResponse(l.json, response, otlp.UnmarshalTracesResponse)
+	if err != nil {
+		return fmt.Errorf("otlplogger: invalid response: %w", err)
+	}
+	if partial := resp.PartialSuccess; partial != nil && partial.RejectedSpans > 0 {
+		return &PartialError{Rejected: int(partial.RejectedSpans), Total: len(spans), Message: partial.ErrorMessage}
+	}
+	return nil
+}
+
+// PartialError is returned when the collector accepted an export but rejected some of its records.
+// OTLP doesn't tell which ones, and they must not be sent again.
+type PartialError struct {
+	Rejected int // records rejected by the collector
+	Total    int // records of the export
+	Message  string
+}
+
+func (e *PartialError) Error() string {
+	message := fmt.Sprintf("otlplogger: collector rejected %d of %d records", e.Rejected, e.Total)
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+func (l *otlpLogger) marshal(req any, marshalProto func() []byte) ([]byte, error) {
+	if l.json {
+		return json.Marshal(req)
+	}
+	return marshalProto(), nil
+}
+
+func post(poster *batch.Poster, body []byte) ([]byte, error) {
+	response, err := poster.Post(body)
+	if err != nil {
+		return nil, fmt.Errorf("otlplogger: %w", err)
+	}
+	return response, nil
+}
+
+// decodeResponse decodes the body of a successful export, in the encoding of the request.
+func decodeResponse[T any](isJSON bool, body []byte, unmarshalProto func([]byte) (*T, error)) (*T, error) {
+	if !isJSON {
+		return unmarshalProto(body)
+	}
+	resp := new(T)
+	if len(bytes.TrimSpace(body)) == 0 {
+		return resp, nil
+	}
+	return resp, json.Unmarshal(body, resp)
+}
+
+func (l *otlpLogger) logsRequest(logs []models.Log) *otlp.ExportLogsServiceRequest {
+	req := &otlp.ExportLogsServiceRequest{}
+	byService := map[string]int{}
+	for _, log := range logs {
+		service := l.serviceName
+		if service == "" {
+			service = string(log.Model)
+		}
+
+		i, ok := byService[service]
+		if !ok {
+			i = len(req.ResourceLogs)
+			byService[service] = i
+			req.ResourceLogs = append(req.ResourceLogs, otlp.ResourceLogs{
+				Resource: otlp.Resource{Attributes: []otlp.KeyValue{otlp.Attribute("service.name", service)}},
+				ScopeLogs: []otlp.ScopeLogs{{
+					Scope: otlp.Scope{Name: scopeName},
+				}},
+			})
+		}
+
+		scope := &req.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, logRecord(log))
+	}
+	return req
+}
+
+func logRecord(log models.Log) otlp.LogRecord {
+	attributes := map[string]any{
+		"logar.model":    string(log.Model),
+		"logar.category": log.Category,
+	}
+
+	// Messages that are JSON objects, including ones with context values, are split into
+	// the "message" value as the body and the other values as attributes.
+	var body otlp.AnyValue
+	var values map[string]any
+	if err := json.Unmarshal([]byte(log.Message), &values); err == nil && values != nil {
+		if message, ok := values["message"]; ok {
+			body = otlp.ValueOf(message)
+			delete(values, "message")
+		} else {
+			body = otlp.String(log.Message)
+		}
+		for k, v := range values {
+			attributes[k] = v
+		}
+	} else {
+		body = otlp.String(log.Message)
+	}
+
+	if log.Function != "" {
+		attributes["code.function"] = log.Function
+	}
+	if log.Caller != "" {
+		file, line := log.Caller, ""
+		if i := strings.LastIndex(log.Caller, ":"); i > 0 {
+			file, line = log.Caller[:i], log.Caller[i+1:]
+		}
+		attributes["code.filepath"] = file
+		if lineno, err := strconv.Atoi(line); err == nil {
+			attributes["code.lineno"] = lineno
+		}
+	}
+	if log.StackTrace != "" {
+		attributes["exception.stacktrace"] = log.StackTrace
+	}
+	if log.Fingerprint != "" {
+		attributes["logar.fingerprint"] = log.Fingerprint
+	}
+	if log.RepeatCount > 1 {
+		attributes["logar.repeat_count"] = log.RepeatCount
+	}
+
+	timestamp := log.CreatedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	record := otlp.LogRecord{
+		TimeUnixNano:         otlp.Uint64(timestamp.UnixNano()),
+		ObservedTimeUnixNano: otlp.Uint64(time.Now().UnixNano()),
+		SeverityNumber:       otlp.SeverityNumber(log.Severity),
+		SeverityText:         otlp.SeverityText(log.Severity),
+		Body:                 &body,
+		Attributes:           otlp.Attributes(attributes),
+		TraceID:              log.TraceID,
+		SpanID:               log.SpanID,
+	}
+	if log.TraceID != "" {
+		record.Flags = 1 // sampled
+	}
+	return record
+}
+
+func (l *otlpLogger) tracesRequest(spans []models.Span) *otlp.ExportTraceServiceRequest {
+	service := l.serviceName
+	if service == "" {
+		service = "logar"
+	}
+
+	otlpSpans := make([]otlp.Span, len(spans))
+	for i, span := range spans {
+		otlpSpans[i] = otlpSpan(span)
+	}
+
+	return &otlp.ExportTraceServiceRequest{
+		ResourceSpans: []otlp.ResourceSpans{{
+			Resource: otlp.Resource{Attributes: []otlp.KeyValue{otlp.Attribute("service.name", service)}},
+			ScopeSpans: []otlp.ScopeSpans{{
+				Scope: otlp.Scope{Name: scopeName},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func otlpSpan(span models.Span) otlp.Span {
+	var attributes map[string]any
+	json.Unmarshal([]byte(span.Attributes), &attributes)
+
+	result := otlp.Span{
+		TraceID:           span.TraceID,
+		SpanID:            span.SpanID,
+		ParentSpanID:      span.ParentID,
+		Name:              span.Name,
+		Kind:              spanKind(span.Kind),
+		StartTimeUnixNano: otlp.Uint64(span.StartTime.UnixNano()),
+		EndTimeUnixNano:   otlp.Uint64(span.EndTime.UnixNano()),
+		Attributes:        otlp.Attributes(attributes),
+	}
+	switch span.Status {
+	case models.SpanStatus_Ok:
+		result.Status = &otlp.Status{Code: otlp.StatusCode_Ok}
+	case models.SpanStatus_Error:
+		result.Status = &otlp.Status{Code: otlp.StatusCode_Error, Message: span.StatusMessage}
+	}
+	return result
+}
+
+func spanKind(kind models.SpanKind) int32 {
+	switch kind {
+	case models.SpanKind_Server:
+		return otlp.SpanKind_Server
+	case models.SpanKind_Client:
+		return otlp.SpanKind_Client
+	case models.SpanKind_Internal:
+		return otlp.SpanKind_Internal
+	}
+	return otlp.SpanKind_Unspecified
+}
+
+type settings struct {
+	Endpoint      string            `json:"endpoint"`
+	JSON          bool              `json:"json"`
+	Headers       map[string]string `json:"headers"`
+	ServiceName   string            `json:"service_name"`
+	BatchSize     int               `json:"batch_size"`
+	FlushInterval string            `json:"flush_interval"` // such as "5s"
+	MaxQueueSize  int               `json:"max_queue_size"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+
+	opts := []Option{
+		WithServiceName(cfg.ServiceName),
+		WithBatchSize(cfg.BatchSize),
+		WithMaxQueueSize(cfg.MaxQueueSize),
+	}
+	if cfg.JSON {
+		opts = append(opts, WithJSON())
+	}
+	for key, value := range cfg.Headers {
+		opts = append(opts, WithHeader(key, value))
+	}
+	if cfg.FlushInterval != "" {
+		interval, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("flush_interval: %w", err)
+		}
+		opts = append(opts, WithFlushInterval(interval))
+	}
+
+	return New(cfg.Endpoint, opts...), nil
+}
@@ -0,0 +1,404 @@
+package otlplogger
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/internal/otlp"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// collector is an OTLP/HTTP collector recording the export requests it receives. Requests are
+// answered by the functions of responses in turn, and with an empty success once they run out.
+type collector struct {
+	t         *testing.T
+	mu        sync.Mutex
+	logs      []*otlp.ExportLogsServiceRequest
+	traces    []*otlp.ExportTraceServiceRequest
+	headers   []http.Header
+	responses []func(w http.ResponseWriter, isJSON bool)
+}
+
+func newCollector(t *testing.T, responses ...func(w http.ResponseWriter, isJSON bool)) (*collector, string) {
+	t.Helper()
+	c := &collector{t: t, responses: responses}
+	ts := httptest.NewServer(c)
+	t.Cleanup(ts.Close)
+	return c, ts.URL
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	isJSON := r.Header.Get("Content-Type") == otlp.ContentTypeJSON
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = append(c.headers, r.Header.Clone())
+
+	switch r.URL.Path {
+	case otlp.LogsPath:
+		req := &otlp.ExportLogsServiceRequest{}
+		var err error
+		if isJSON {
+			err = json.Unmarshal(body, req)
+		} else {
+			req, err = otlp.UnmarshalLogs(body)
+		}
+		if err != nil {
+			c.t.Errorf("invalid logs request: %v", err)
+		}
+		c.logs = append(c.logs, req)
+	case otlp.TracesPath:
+		req := &otlp.ExportTraceServiceRequest{}
+		var err error
+		if isJSON {
+			err = json.Unmarshal(body, req)
+		} else {
+			req, err = otlp.UnmarshalTraces(body)
+		}
+		if err != nil {
+			c.t.Errorf("invalid traces request: %v", err)
+		}
+		c.traces = append(c.traces, req)
+	default:
+		c.t.Errorf("unexpected path %q", r.URL.Path)
+	}
+
+	if len(c.responses) > 0 {
+		respond := c.responses[0]
+		c.responses = c.responses[1:]
+		respond(w, isJSON)
+	}
+}
+
+func status(code int) func(w http.ResponseWriter, isJSON bool) {
+	return func(w http.ResponseWriter, isJSON bool) {
+		w.WriteHeader(code)
+	}
+}
+
+func rejected(count int, message string) func(w http.ResponseWriter, isJSON bool) {
+	return func(w http.ResponseWriter, isJSON bool) {
+		resp := &otlp.ExportLogsServiceResponse{PartialSuccess: &otlp.ExportLogsPartialSuccess{
+			RejectedLogRecords: otlp.Int64(count),
+			ErrorMessage:       message,
+		}}
+		if isJSON {
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		w.Write(otlp.MarshalLogsResponse(resp))
+	}
+}
+
+// records returns the log records of every request.
+func (c *collector) records() []otlp.LogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var records []otlp.LogRecord
+	for _, req := range c.logs {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}
+	return records
+}
+
+// newTarget returns a target exporting only when flushed.
+func newTarget(t *testing.T, url string, opts ...Option) *otlpLogger {
+	t.Helper()
+	opts = append([]Option{WithFlushInterval(time.Hour), WithRetry(2, time.Millisecond)}, opts...)
+	target := New(url, opts...)
+	t.Cleanup(func() { target.Close() })
+	return target
+}
+
+func attributes(kvs []otlp.KeyValue) map[string]any {
+	values := map[string]any{}
+	for _, kv := range kvs {
+		values[kv.Key] = kv.Value.Interface()
+	}
+	return values
+}
+
+func TestExportLogsBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		contentType string
+	}{
+		{"protobuf", nil, otlp.ContentTypeProtobuf},
+		{"json", []Option{WithJSON()}, otlp.ContentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, url := newCollector(t)
+			target := newTarget(t, url, append(tt.opts, WithHeader("Authorization", "Bearer token"))...)
+
+			target.Send(models.Log{
+				Model:     "payments",
+				Category:  "checkout",
+				Severity:  models.Severity_Error,
+				Message:   `{"message":"declined","order":"42"}`,
+				CreatedAt: start,
+				Caller:    "main.go:12",
+				TraceID:   "0123456789abcdef0123456789abcdef",
+				SpanID:    "0123456789abcdef",
+			}, "")
+			target.Send(models.Log{Model: "api", Severity: models.Severity_Info, Message: "started", CreatedAt: start}, "")
+			if err := target.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			if len(c.logs) != 1 {
+				t.Fatalf("got %d requests, want 1", len(c.logs))
+			}
+			header := c.headers[0]
+			if got := header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := header.Get("Authorization"); got != "Bearer token" {
+				t.Errorf("Authorization = %q", got)
+			}
+
+			// One resource per model, named after it
+			resources := c.logs[0].ResourceLogs
+			if len(resources) != 2 {
+				t.Fatalf("got %d resources, want one per model", len(resources))
+			}
+			if got := attributes(resources[0].Resource.Attributes)["service.name"]; got != "payments" {
+				t.Errorf("service.name = %v, want payments", got)
+			}
+			if got := resources[0].ScopeLogs[0].Scope.Name; got != scopeName {
+				t.Errorf("scope = %q, want %q", got, scopeName)
+			}
+
+			record := resources[0].ScopeLogs[0].LogRecords[0]
+			if got := record.Body.Interface(); got != "declined" {
+				t.Errorf("body = %v, want the message", got)
+			}
+			if uint64(record.TimeUnixNano) != uint64(start.UnixNano()) {
+				t.Errorf("time = %d, want %d", record.TimeUnixNano, start.UnixNano())
+			}
+			if record.SeverityNumber != otlp.SeverityNumber(models.Severity_Error) || record.SeverityText != otlp.SeverityText(models.Severity_Error) {
+				t.Errorf("severity = %d %q", record.SeverityNumber, record.SeverityText)
+			}
+			if record.TraceID != "0123456789abcdef0123456789abcdef" || record.SpanID != "0123456789abcdef" || record.Flags != 1 {
+				t.Errorf("trace, span, flags = %q, %q, %d", record.TraceID, record.SpanID, record.Flags)
+			}
+			want := map[string]any{
+				"logar.model":    "payments",
+				"logar.category": "checkout",
+				"order":          "42",
+				"code.filepath":  "main.go",
+				"code.lineno":    int64(12),
+			}
+			got := attributes(record.Attributes)
+			for key, value := range want {
+				if got[key] != value {
+					t.Errorf("attribute %s = %#v, want %#v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestExportSpansBody(t *testing.T) {
+	c, url := newCollector(t)
+	target := newTarget(t, url, WithServiceName("checkout"))
+
+	target.ExportSpan(models.Span{
+		TraceID:   "0123456789abcdef0123456789abcdef",
+		SpanID:    "0123456789abcdef",
+		Name:      "GET /orders",
+		Kind:      models.SpanKind_Server,
+		Status:    models.SpanStatus_Error,
+		StartTime: start,
+		EndTime:   start.Add(time.Second),
+	})
+	if err := target.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if len(c.traces) != 1 {
+		t.Fatalf("got %d requests, want 1", len(c.traces))
+	}
+	rs := c.traces[0].ResourceSpans[0]
+	if got := attributes(rs.Resource.Attributes)["service.name"]; got != "checkout" {
+		t.Errorf("service.name = %v, want checkout", got)
+	}
+	span := rs.ScopeSpans[0].Spans[0]
+	if span.Name != "GET /orders" || span.Kind != otlp.SpanKind_Server || span.Status == nil || span.Status.Code != otlp.StatusCode_Error {
+		t.Errorf("span = %+v", span)
+	}
+}
+
+func TestExportBatches(t *testing.T) {
+	c, url := newCollector(t)
+	target := newTarget(t, url, WithBatchSize(2))
+
+	for i := 0; i < 5; i++ {
+		if err := target.Send(models.Log{Model: "app", Message: "message", CreatedAt: start}, ""); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := target.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	c.mu.Lock()
+	for _, req := range c.logs {
+		if n := len(req.ResourceLogs[0].ScopeLogs[0].LogRecords); n > 2 {
+			t.Errorf("got %d records in a request, want at most the batch size", n)
+		}
+	}
+	c.mu.Unlock()
+	if n := len(c.records()); n != 5 {
+		t.Errorf("exported %d logs, want 5", n)
+	}
+}
+
+func TestExportFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []func(w http.ResponseWriter, isJSON bool)
+		wantRequests int
+		wantFailed   bool
+	}{
+		{"retried", []func(w http.ResponseWriter, isJSON bool){status(http.StatusServiceUnavailable), status(http.StatusTooManyRequests)}, 3, false},
+		{"retries run out", []func(w http.ResponseWriter, isJSON bool){status(http.StatusBadGateway), status(http.StatusBadGateway), status(http.StatusBadGateway)}, 3, true},
+		{"rejected", []func(w http.ResponseWriter, isJSON bool){status(http.StatusBadRequest)}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, url := newCollector(t, tt.responses...)
+			target := newTarget(t, url)
+
+			var results []proxy.BatchResult
+			target.OnResult(func(result proxy.BatchResult) {
+				results = append(results, result)
+			})
+
+			for i := 0; i < 3; i++ {
+				target.Send(models.Log{Model: "app", Message: "message", CreatedAt: start}, "")
+			}
+			if err := target.Flush(); (err != nil) != tt.wantFailed {
+				t.Errorf("Flush() error = %v, want an error: %v", err, tt.wantFailed)
+			}
+
+			if len(c.logs) != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", len(c.logs), tt.wantRequests)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			if want := map[bool]int{true: 3, false: 0}[tt.wantFailed]; len(results[0].Failed) != want {
+				t.Errorf("got %d failed entries, want %d", len(results[0].Failed), want)
+			}
+		})
+	}
+}
+
+func TestExportPartialSuccess(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"protobuf", nil},
+		{"json", []Option{WithJSON()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, url := newCollector(t, rejected(1, "record too large"))
+			target := newTarget(t, url, tt.opts...)
+
+			var results []proxy.BatchResult
+			target.OnResult(func(result proxy.BatchResult) {
+				results = append(results, result)
+			})
+
+			for i := 0; i < 3; i++ {
+				target.Send(models.Log{Model: "app", Message: "message", CreatedAt: start}, "")
+			}
+			err := target.Flush()
+
+			var partialErr *PartialError
+			if !errors.As(err, &partialErr) {
+				t.Fatalf("Flush() error = %v, want a *PartialError", err)
+			}
+			if partialErr.Rejected != 1 || partialErr.Total != 3 || partialErr.Message != "record too large" {
+				t.Errorf("error = %+v", partialErr)
+			}
+
+			// Rejected records must not be sent again
+			if len(c.logs) != 1 {
+				t.Errorf("got %d requests, want 1", len(c.logs))
+			}
+			if len(results) != 1 || !errors.As(results[0].Err, &partialErr) {
+				t.Fatalf("results = %+v, want the partial error", results)
+			}
+			// The collector doesn't identify the rejected records
+			if results[0].Failed != nil {
+				t.Errorf("got %d failed entries, want none", len(results[0].Failed))
+			}
+		})
+	}
+}
+
+func TestSpanErrorHandler(t *testing.T) {
+	_, url := newCollector(t, status(http.StatusBadRequest))
+
+	var failed []models.Span
+	target := newTarget(t, url, WithSpanErrorHandler(func(spans []models.Span, err error) {
+		failed = append(failed, spans...)
+	}))
+	target.ExportSpan(models.Span{TraceID: "0123456789abcdef0123456789abcdef", SpanID: "0123456789abcdef", Name: "a"})
+	if err := target.Flush(); err == nil {
+		t.Error("Flush() error = nil, want the error of the collector")
+	}
+	if len(failed) != 1 {
+		t.Errorf("got %d failed spans, want 1", len(failed))
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	if _, err := proxy.NewTarget("otlp", proxy.Settings{}); err == nil {
+		t.Error("NewTarget() error = nil, want an error without endpoint")
+	}
+	if _, err := proxy.NewTarget("otlp", proxy.Settings{"endpoint": "http://localhost:4318", "flush_interval": "soon"}); err == nil {
+		t.Error("NewTarget() error = nil, want an error for an invalid flush_interval")
+	}
+
+	target, err := proxy.NewTarget("otlp", proxy.Settings{
+		"endpoint":     "http://localhost:4318/",
+		"json":         true,
+		"service_name": "api",
+		"headers":      map[string]any{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	l := target.(*otlpLogger)
+	defer l.Close()
+
+	if l.logsPoster.URL != "http://localhost:4318"+otlp.LogsPath || l.tracesPoster.URL != "http://localhost:4318"+otlp.TracesPath {
+		t.Errorf("urls = %q, %q", l.logsPoster.URL, l.tracesPoster.URL)
+	}
+	if !l.json || l.serviceName != "api" || l.headers.Get("Authorization") != "Bearer token" {
+		t.Errorf("json, service name, authorization = %v, %q, %q", l.json, l.serviceName, l.headers.Get("Authorization"))
+	}
+}
@@ -1,6 +1,9 @@
 package proxy
 
 import (
+	"io"
+	"time"
+
 	"sadk.dev/logar/logfilter"
 	"sadk.dev/logar/models"
 )
@@ -9,7 +12,30 @@ type ProxyTarget interface {
 	Send(log models.Log, rawMessage string) error
 }
 
+// Entry is a log with the raw message passed to ProxyTarget.Send.
+type Entry struct {
+	Log        models.Log
+	RawMessage string
+}
+
+// BatchResult is the outcome of sending a batch of logs queued by a BatchTarget.
+type BatchResult struct {
+	Entries []Entry       // every log of the batch
+	Failed  []Entry       // logs that weren't delivered, only some of them if the batch partly failed
+	Err     error         // why Failed weren't delivered
+	Latency time.Duration // time spent sending the batch, retries included
+}
+
+// BatchTarget is a target whose Send only queues the log, the logs are sent later in batches from
+// a background goroutine. The result of every batch is passed to the function given to OnResult,
+// so that logs that failed are still stored as dead letters and counted in the proxy metrics.
+type BatchTarget interface {
+	ProxyTarget
+	OnResult(fn func(BatchResult))
+}
+
 type Proxy struct {
+	name   string
 	target ProxyTarget
 	filter logfilter.Filter
 }
@@ -21,8 +47,49 @@ func NewProxy(target ProxyTarget, filter logfilter.Filter) Proxy {
 	}
 }
 
-func (p *Proxy) TrySend(log models.Log, rawMessage string) {
-	if p.filter.Evaluate(log) {
-		p.target.Send(log, rawMessage)
+// WithName returns a copy of the proxy with the given name. The name identifies the proxy in
+// dead letters and the API, unnamed proxies are named after their position.
+func (p Proxy) WithName(name string) Proxy {
+	p.name = name
+	return p
+}
+
+func (p *Proxy) Name() string {
+	return p.name
+}
+
+// Matches reports whether the log passes the filter of the proxy.
+func (p *Proxy) Matches(log models.Log) bool {
+	return p.filter.Evaluate(log)
+}
+
+// Send sends the log to the target without evaluating the filter.
+func (p *Proxy) Send(log models.Log, rawMessage string) error {
+	return p.target.Send(log, rawMessage)
+}
+
+// TrySend sends the log to the target if it passes the filter.
+func (p *Proxy) TrySend(log models.Log, rawMessage string) error {
+	if !p.Matches(log) {
+		return nil
+	}
+	return p.Send(log, rawMessage)
+}
+
+// OnBatchResult passes the result of every batch to fn if the target is a BatchTarget, and reports
+// whether it is.
+func (p *Proxy) OnBatchResult(fn func(BatchResult)) bool {
+	target, ok := p.target.(BatchTarget)
+	if ok {
+		target.OnResult(fn)
+	}
+	return ok
+}
+
+// Close closes the target if it holds resources, such as files or background workers.
+func (p *Proxy) Close() error {
+	if closer, ok := p.target.(io.Closer); ok {
+		return closer.Close()
 	}
+	return nil
 }
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"sadk.dev/logar/logfilter"
+)
+
+// Settings are the options of a proxy target, as decoded from a configuration file.
+type Settings map[string]any
+
+// Decode decodes the settings into v, a pointer to a struct with json tags. Unknown keys are an error.
+func (s Settings) Decode(v any) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+
+	// Name the offending key rather than the Go field
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case err != nil && strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
+
+// TargetFactory builds a target from its settings.
+type TargetFactory func(settings Settings) (ProxyTarget, error)
+
+type registeredTarget struct {
+	factory       TargetFactory
+	sensitiveKeys []string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]registeredTarget{}
+)
+
+// RegisterTarget makes a target type available to proxies defined in configuration.
+// Target packages register themselves when they are imported. The values of sensitiveKeys, such as
+// passwords, tokens and auth headers, are redacted when settings are shown.
+func RegisterTarget(targetType string, factory TargetFactory, sensitiveKeys ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[targetType] = registeredTarget{factory: factory, sensitiveKeys: sensitiveKeys}
+}
+
+// Redacted is the value of redacted settings.
+const Redacted = "[REDACTED]"
+
+// Redact returns a copy of the settings of a target with the values of its sensitive keys replaced by
+// Redacted. Every value is redacted if the target type isn't registered, as its keys aren't known.
+func (s Settings) Redact(targetType string) Settings {
+	if s == nil {
+		return nil
+	}
+
+	registryMu.RLock()
+	target, ok := registry[targetType]
+	registryMu.RUnlock()
+
+	redacted := make(Settings, len(s))
+	for key, value := range s {
+		if !ok || slices.Contains(target.sensitiveKeys, key) {
+			value = Redacted
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+func NewTarget(targetType string, settings Settings) (ProxyTarget, error) {
+	registryMu.RLock()
+	target, ok := registry[targetType]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown proxy type %q, the package of the target must be imported", targetType)
+	}
+
+	return target.factory(settings)
+}
+
+// TargetTypes returns the registered target types, sorted.
+func TargetTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for targetType := range registry {
+		types = append(types, targetType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Definition is a proxy declared in configuration.
+type Definition struct {
+	Name     string         `json:"name,omitempty"`
+	Type     string         `json:"type"`
+	Filter   logfilter.Spec `json:"filter"`
+	Settings Settings       `json:"settings,omitempty"`
+}
+
+func (d Definition) Build() (Proxy, error) {
+	filter, err := d.Filter.Filter()
+	if err != nil {
+		return Proxy{}, fmt.Errorf("filter: %w", err)
+	}
+
+	target, err := NewTarget(d.Type, d.Settings)
+	if err != nil {
+		return Proxy{}, err
+	}
+
+	return NewProxy(target, filter).WithName(d.Name), nil
+}
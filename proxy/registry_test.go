@@ -0,0 +1,63 @@
+package proxy_test
+
+import (
+	"testing"
+
+	"sadk.dev/logar/proxy"
+	_ "sadk.dev/logar/proxy/discordlogger"
+	_ "sadk.dev/logar/proxy/elasticlogger"
+	_ "sadk.dev/logar/proxy/emaillogger"
+	_ "sadk.dev/logar/proxy/lokilogger"
+	_ "sadk.dev/logar/proxy/otlplogger"
+	_ "sadk.dev/logar/proxy/slacklogger"
+	_ "sadk.dev/logar/proxy/webhooklogger"
+	_ "sadk.dev/logar/telegrambot"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		targetType string
+		settings   proxy.Settings
+		redacted   []string
+	}{
+		{"telegram", proxy.Settings{"token": "123:abc", "chat_id": int64(42)}, []string{"token"}},
+		{"slack", proxy.Settings{"webhook_url": "https://hooks.slack.com/services/x", "username": "logar"}, []string{"webhook_url"}},
+		{"discord", proxy.Settings{"webhook_url": "https://discord.com/api/webhooks/1/x", "username": "logar"}, []string{"webhook_url"}},
+		{"webhook", proxy.Settings{"url": "https://example.com", "secret": "s", "headers": map[string]any{"X-Key": "k"}}, []string{"secret", "headers"}},
+		{"loki", proxy.Settings{"endpoint": "http://localhost:3100", "username": "u", "password": "p", "headers": map[string]any{}}, []string{"password", "headers"}},
+		{"elasticsearch", proxy.Settings{"endpoint": "http://localhost:9200", "password": "p", "api_key": "k"}, []string{"password", "api_key"}},
+		{"email", proxy.Settings{"host": "smtp.example.com", "username": "u", "password": "p"}, []string{"password"}},
+		{"otlp", proxy.Settings{"endpoint": "http://localhost:4318", "headers": map[string]any{"Authorization": "Bearer t"}}, []string{"headers"}},
+		// The keys of unknown types aren't known to be safe
+		{"unknown", proxy.Settings{"url": "https://example.com", "name": "x"}, []string{"url", "name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.targetType, func(t *testing.T) {
+			redacted := tt.settings.Redact(tt.targetType)
+			if len(redacted) != len(tt.settings) {
+				t.Fatalf("got %d settings, want %d", len(redacted), len(tt.settings))
+			}
+			for key, value := range tt.settings {
+				want := value
+				for _, sensitive := range tt.redacted {
+					if key == sensitive {
+						want = proxy.Redacted
+					}
+				}
+				if _, isMap := want.(map[string]any); isMap {
+					if redacted[key] == proxy.Redacted {
+						t.Errorf("%s = %v, want it kept", key, redacted[key])
+					}
+					continue
+				}
+				if redacted[key] != want {
+					t.Errorf("%s = %v, want %v", key, redacted[key], want)
+				}
+			}
+			if tt.settings[tt.redacted[0]] == proxy.Redacted {
+				t.Error("Redact() modified the settings")
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// Package slacklogger posts logs to a Slack incoming webhook as attachments colored by severity.
+package slacklogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sadk.dev/logar/internal/ratelimit"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+// Slack accepts longer messages, but cuts them off in the client
+const (
+	maxTextLength       = 3000
+	maxFallbackLength   = 150
+	maxFields           = 20
+	maxFieldValueLength = 500
+)
+
+func init() {
+	proxy.RegisterTarget("slack", newFromSettings, "webhook_url")
+}
+
+type Option func(*slackLogger)
+
+// WithPanelURL links every message to the logs of its model in the web panel served at panelURL,
+// such as "https://example.com/logar".
+func WithPanelURL(panelURL string) Option {
+	return func(l *slackLogger) {
+		l.panelURL = panelURL
+	}
+}
+
+// WithUsername overrides the name the webhook posts as.
+func WithUsername(username string) Option {
+	return func(l *slackLogger) {
+		l.username = username
+	}
+}
+
+// WithChannel overrides the channel of the webhook, for legacy webhooks that allow it.
+func WithChannel(channel string) Option {
+	return func(l *slackLogger) {
+		l.channel = channel
+	}
+}
+
+// WithIconEmoji overrides the icon the webhook posts with, such as ":rotating_light:".
+func WithIconEmoji(emoji string) Option {
+	return func(l *slackLogger) {
+		l.iconEmoji = emoji
+	}
+}
+
+// WithRateLimit sends at most one message every interval. Defaults to one second, as Slack allows about one message per second per webhook.
+// Messages are held back anyway when the webhook responds with 429, for as long as it asks.
+func WithRateLimit(interval time.Duration) Option {
+	return func(l *slackLogger) {
+		l.rateLimit = interval
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *slackLogger) {
+		l.client = client
+	}
+}
+
+// New creates a target posting every log to the incoming webhook URL.
+func New(webhookURL string, opts ...Option) (*slackLogger, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("slacklogger: invalid webhook url %q", webhookURL)
+	}
+
+	l := &slackLogger{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		rateLimit:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.limiter = ratelimit.New(l.rateLimit)
+
+	return l, nil
+}
+
+type slackLogger struct {
+	webhookURL string
+	panelURL   string
+	username   string
+	channel    string
+	iconEmoji  string
+	client     *http.Client
+	rateLimit  time.Duration
+	limiter    *ratelimit.Limiter
+}
+
+type message struct {
+	Text        string       `json:"text"`
+	Username    string       `json:"username,omitempty"`
+	Channel     string       `json:"channel,omitempty"`
+	IconEmoji   string       `json:"icon_emoji,omitempty"`
+	Attachments []attachment `json:"attachments"`
+}
+
+type attachment struct {
+	Color     string  `json:"color"`
+	Fallback  string  `json:"fallback"`
+	Title     string  `json:"title"`
+	TitleLink string  `json:"title_link,omitempty"`
+	Text      string  `json:"text"`
+	Fields    []field `json:"fields,omitempty"`
+	Footer    string  `json:"footer"`
+	Timestamp int64   `json:"ts"`
+}
+
+type field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (l *slackLogger) Send(log models.Log, rawMessage string) error {
+	body, err := json.Marshal(l.message(log, rawMessage))
+	if err != nil {
+		return err
+	}
+
+	l.limiter.Wait()
+	resp, err := l.client.Post(l.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slacklogger: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// The proxy retries the log, after the delay asked by Slack
+		delay, ok := ratelimit.RetryAfter(resp.Header)
+		if !ok {
+			delay = time.Second
+		}
+		l.limiter.Delay(delay)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("slacklogger: webhook responded with %s: %s", resp.Status, strings.TrimSpace(string(reason)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (l *slackLogger) message(log models.Log, rawMessage string) message {
+	text, contextFields := format.Split(rawMessage)
+	title := fmt.Sprintf("[%s] %s", strings.ToUpper(log.Severity.String()), log.Model)
+	if log.Category != "" {
+		title += " / " + log.Category
+	}
+
+	fields := []field{
+		{Title: "Model", Value: escape(string(log.Model)), Short: true},
+		{Title: "Category", Value: escape(log.Category), Short: true},
+	}
+	if log.Caller != "" {
+		fields = append(fields, field{Title: "Caller", Value: escape(log.Caller), Short: true})
+	}
+	if log.TraceID != "" {
+		fields = append(fields, field{Title: "Trace", Value: escape(log.TraceID), Short: true})
+	}
+	for _, f := range contextFields {
+		if len(fields) >= maxFields {
+			break
+		}
+		fields = append(fields, field{
+			Title: f.Name,
+			Value: escapeTruncate(f.Value, maxFieldValueLength),
+			Short: len(f.Value) <= 40,
+		})
+	}
+
+	fallback := escapeTruncate(title+": "+text, maxFallbackLength)
+	return message{
+		Text:      fallback,
+		Username:  l.username,
+		Channel:   l.channel,
+		IconEmoji: l.iconEmoji,
+		Attachments: []attachment{{
+			Color:     fmt.Sprintf("#%06x", format.Color(log.Severity)),
+			Fallback:  fallback,
+			Title:     escape(title),
+			TitleLink: format.LogsURL(l.panelURL, log.Model),
+			Text:      escapeTruncate(text, maxTextLength),
+			Fields:    fields,
+			Footer:    "logar",
+			Timestamp: log.CreatedAt.Unix(),
+		}},
+	}
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape escapes the characters Slack uses for links and mentions.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// escapeTruncate escapes s and shortens it to at most max characters like format.Truncate. Slack
+// limits the escaped text, so entities count in full and are never cut.
+func escapeTruncate(s string, max int) string {
+	escaped := escape(s)
+	if utf8.RuneCountInString(escaped) <= max {
+		return escaped
+	}
+	if max <= 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	length := 0
+	for _, r := range s {
+		part := escape(string(r))
+		n := utf8.RuneCountInString(part)
+		if length+n > max-1 {
+			break
+		}
+		b.WriteString(part)
+		length += n
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+type settings struct {
+	WebhookURL string `json:"webhook_url"`
+	PanelURL   string `json:"panel_url"`
+	RateLimit  string `json:"rate_limit"` // such as "1s", "0" only waits when the webhook responds with 429
+	Username   string `json:"username"`
+	Channel    string `json:"channel"`
+	IconEmoji  string `json:"icon_emoji"`
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookURL == "" {
+		return nil, errors.New("webhook_url is required")
+	}
+
+	opts := []Option{
+		WithPanelURL(cfg.PanelURL),
+		WithUsername(cfg.Username),
+		WithChannel(cfg.Channel),
+		WithIconEmoji(cfg.IconEmoji),
+	}
+	if cfg.RateLimit != "" {
+		interval, err := time.ParseDuration(cfg.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit: %w", err)
+		}
+		opts = append(opts, WithRateLimit(interval))
+	}
+
+	target, err := New(cfg.WebhookURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,273 @@
+package slacklogger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var testLog = models.Log{
+	CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	Model:     "payments",
+	Category:  "checkout",
+	Severity:  models.Severity_Error,
+	Caller:    "main.go:12",
+	TraceID:   "0123456789abcdef0123456789abcdef",
+}
+
+// webhook is a Slack incoming webhook recording the messages it receives. Requests are answered
+// by the functions of responses in turn, and with 200 once they run out.
+type webhook struct {
+	t         *testing.T
+	mu        sync.Mutex
+	messages  []message
+	times     []time.Time
+	responses []func(w http.ResponseWriter)
+}
+
+func newWebhook(t *testing.T, responses ...func(w http.ResponseWriter)) (*webhook, string) {
+	t.Helper()
+	h := &webhook{t: t, responses: responses}
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return h, server.URL
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		h.t.Errorf("invalid body: %v", err)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/json" {
+		h.t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, msg)
+	h.times = append(h.times, time.Now())
+	if len(h.responses) > 0 {
+		respond := h.responses[0]
+		h.responses = h.responses[1:]
+		respond(w)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func tooManyRequests(retryAfter string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("rate_limited"))
+	}
+}
+
+func TestSendPayload(t *testing.T) {
+	hook, url := newWebhook(t)
+	target, err := New(url, WithPanelURL("https://example.com/logar/"), WithUsername("logar"), WithChannel("#alerts"), WithIconEmoji(":fire:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, `{"message":"card <declined> & refunded","order":"42"}`); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(hook.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(hook.messages))
+	}
+	msg := hook.messages[0]
+	if msg.Username != "logar" || msg.Channel != "#alerts" || msg.IconEmoji != ":fire:" {
+		t.Errorf("username, channel, icon = %q, %q, %q", msg.Username, msg.Channel, msg.IconEmoji)
+	}
+	if want := "[ERROR] payments / checkout: card &lt;declined&gt; &amp; refunded"; msg.Text != want {
+		t.Errorf("text = %q, want %q", msg.Text, want)
+	}
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(msg.Attachments))
+	}
+
+	a := msg.Attachments[0]
+	if a.Color != "#f44336" {
+		t.Errorf("color = %q, want the color of errors", a.Color)
+	}
+	if a.Title != "[ERROR] payments / checkout" {
+		t.Errorf("title = %q", a.Title)
+	}
+	if a.TitleLink != "https://example.com/logar/logs?model=payments" {
+		t.Errorf("title link = %q", a.TitleLink)
+	}
+	if a.Text != "card &lt;declined&gt; &amp; refunded" {
+		t.Errorf("text = %q, want the escaped message", a.Text)
+	}
+	if a.Timestamp != testLog.CreatedAt.Unix() {
+		t.Errorf("ts = %d, want %d", a.Timestamp, testLog.CreatedAt.Unix())
+	}
+
+	want := []field{
+		{Title: "Model", Value: "payments", Short: true},
+		{Title: "Category", Value: "checkout", Short: true},
+		{Title: "Caller", Value: "main.go:12", Short: true},
+		{Title: "Trace", Value: testLog.TraceID, Short: true},
+		{Title: "order", Value: "42", Short: true},
+	}
+	if len(a.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %+v", a.Fields, want)
+	}
+	for i := range want {
+		if a.Fields[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, a.Fields[i], want[i])
+		}
+	}
+}
+
+func TestSendLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		char string // the characters of the message and field values
+	}{
+		{"plain text", "a"},
+		// Escaping makes the text longer, the escaped text must be within the limits
+		{"escaped text", "&"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, url := newWebhook(t)
+			target, err := New(url, WithRateLimit(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values := map[string]string{"message": strings.Repeat(tt.char, 5000)}
+			for i := 0; i < 30; i++ {
+				values[string(rune('a'+i%26))+strings.Repeat("x", i)] = strings.Repeat(tt.char, 600)
+			}
+			raw, _ := json.Marshal(values)
+			if err := target.Send(testLog, string(raw)); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			msg := hook.messages[0]
+			a := msg.Attachments[0]
+			type limited struct {
+				name string
+				text string
+				max  int
+			}
+			texts := []limited{
+				{"fallback", msg.Text, maxFallbackLength},
+				{"attachment fallback", a.Fallback, maxFallbackLength},
+				{"text", a.Text, maxTextLength},
+			}
+			for _, f := range a.Fields[4:] {
+				texts = append(texts, limited{"field " + f.Title, f.Value, maxFieldValueLength})
+			}
+			for _, l := range texts {
+				// A cut entity leaves at most its length minus one character unused
+				if n := len([]rune(l.text)); n > l.max || n < l.max-len(escape(tt.char))+1 {
+					t.Errorf("%s has %d characters, want at most %d", l.name, n, l.max)
+				}
+				if !strings.HasSuffix(l.text, "…") || !wholeEntities(strings.TrimSuffix(l.text, "…")) {
+					t.Errorf("%s is not truncated between whole entities: %q", l.name, l.text[len(l.text)-20:])
+				}
+			}
+			if len(a.Fields) != maxFields {
+				t.Errorf("got %d fields, want %d", len(a.Fields), maxFields)
+			}
+		})
+	}
+}
+
+// wholeEntities reports whether every & of s starts an entity.
+func wholeEntities(s string) bool {
+	for i := strings.Index(s, "&"); i >= 0; i = strings.Index(s, "&") {
+		s = s[i+1:]
+		if !strings.HasPrefix(s, "amp;") && !strings.HasPrefix(s, "lt;") && !strings.HasPrefix(s, "gt;") {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSendRateLimit(t *testing.T) {
+	hook, url := newWebhook(t)
+	target, err := New(url, WithRateLimit(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := target.Send(testLog, "message"); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	for i := 1; i < len(hook.times); i++ {
+		if gap := hook.times[i].Sub(hook.times[i-1]); gap < 90*time.Millisecond {
+			t.Errorf("message %d was sent %v after the previous one, want at least the rate limit", i, gap)
+		}
+	}
+}
+
+func TestSendRetryAfter(t *testing.T) {
+	hook, url := newWebhook(t, tooManyRequests("1"))
+	target, err := New(url, WithRateLimit(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, "message"); err == nil {
+		t.Fatal("Send() error = nil, want an error for a 429 response")
+	}
+	if err := target.Send(testLog, "message"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if gap := hook.times[1].Sub(hook.times[0]); gap < 900*time.Millisecond {
+		t.Errorf("retry was sent %v after the 429 response, want the Retry-After delay", gap)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	_, url := newWebhook(t, func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no_service"))
+	})
+	target, err := New(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = target.Send(testLog, "message")
+	if err == nil || !strings.Contains(err.Error(), "no_service") {
+		t.Errorf("Send() error = %v, want the reason of the response", err)
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	if _, err := proxy.NewTarget("slack", proxy.Settings{}); err == nil {
+		t.Error("NewTarget() error = nil, want an error without webhook_url")
+	}
+	if _, err := proxy.NewTarget("slack", proxy.Settings{"webhook_url": "https://hooks.slack.com/x", "rate_limit": "soon"}); err == nil {
+		t.Error("NewTarget() error = nil, want an error for an invalid rate_limit")
+	}
+
+	target, err := proxy.NewTarget("slack", proxy.Settings{"webhook_url": "https://hooks.slack.com/x", "rate_limit": "2s"})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	if got := target.(*slackLogger).rateLimit; got != 2*time.Second {
+		t.Errorf("rate limit = %v, want 2s", got)
+	}
+}
@@ -1,28 +1,57 @@
 package telegramlogger
 
 import (
-	"fmt"
-	"strings"
-
 	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy/format"
 )
 
+// Telegram rejects longer messages
+const maxMessageLength = 4096
+
+// DefaultFormatter renders the severity, model, category, time, message and context fields as plain text.
+var DefaultFormatter = format.MustTemplate(`{{emoji .Log.Severity}} [{{upper .Severity}}] {{.Log.Model}}{{with .Log.Category}} / {{.}}{{end}}
+{{.Log.CreatedAt.Format "2006-01-02 15:04:05 MST"}}
+
+{{.Message}}
+{{- range .Fields}}
+{{.Name}}: {{.Value}}{{end}}`)
+
 type MessageSender interface {
 	Send(message string, chatId int64) error
 }
 
-func New(bot MessageSender, chatId int64) *telegramLogger {
-	return &telegramLogger{
-		bot:    bot,
-		chatId: chatId,
+type Option func(*telegramLogger)
+
+// WithFormatter sets how logs are rendered. format.Markdown can be used with senders that send
+// MarkdownV2 messages. Defaults to DefaultFormatter.
+func WithFormatter(formatter format.Formatter) Option {
+	return func(l *telegramLogger) {
+		l.formatter = formatter
+	}
+}
+
+func New(bot MessageSender, chatId int64, opts ...Option) *telegramLogger {
+	l := &telegramLogger{
+		bot:       bot,
+		chatId:    chatId,
+		formatter: DefaultFormatter,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
 type telegramLogger struct {
-	bot    MessageSender
-	chatId int64
+	bot       MessageSender
+	chatId    int64
+	formatter format.Formatter
 }
 
 func (l *telegramLogger) Send(log models.Log, rawMessage string) error {
-	return l.bot.Send(fmt.Sprintf("["+strings.ToUpper(log.Severity.String())+"] %s", log.Message), l.chatId)
+	message, err := l.formatter.Format(log, rawMessage)
+	if err != nil {
+		return err
+	}
+	return l.bot.Send(format.Truncate(message, maxMessageLength), l.chatId)
 }
@@ -0,0 +1,73 @@
+package webhooklogger
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"sadk.dev/logar/proxy/format"
+)
+
+// jsonTemplate is a decoded JSON document whose strings containing actions are templates.
+type jsonTemplate any
+
+func parseJSONTemplate(value any) (jsonTemplate, error) {
+	switch value := value.(type) {
+	case string:
+		if !strings.Contains(value, "{{") {
+			return value, nil
+		}
+		return template.New("webhook").Funcs(format.Funcs).Option("missingkey=error").Parse(value)
+	case map[string]any:
+		result := make(map[string]any, len(value))
+		for key, item := range value {
+			tmpl, err := parseJSONTemplate(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = tmpl
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(value))
+		for i, item := range value {
+			tmpl, err := parseJSONTemplate(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = tmpl
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+func renderJSONTemplate(tmpl jsonTemplate, data format.Data) (any, error) {
+	switch tmpl := tmpl.(type) {
+	case *template.Template:
+		var buf strings.Builder
+		err := tmpl.Execute(&buf, data)
+		return buf.String(), err
+	case map[string]any:
+		result := make(map[string]any, len(tmpl))
+		for key, item := range tmpl {
+			value, err := renderJSONTemplate(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(tmpl))
+		for i, item := range tmpl {
+			value, err := renderJSONTemplate(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = value
+		}
+		return result, nil
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,365 @@
+// Package webhooklogger posts logs to arbitrary HTTP endpoints, such as incident tools and chat bots.
+package webhooklogger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+const (
+	DefaultSignatureHeader = "X-Logar-Signature"
+	TimestampHeader        = "X-Logar-Timestamp"
+)
+
+func init() {
+	proxy.RegisterTarget("webhook", newFromSettings, "secret", "headers")
+}
+
+// Payload is the body sent when no template or formatter is set.
+type Payload struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Model       string    `json:"model"`
+	Category    string    `json:"category"`
+	Severity    string    `json:"severity"` // name, such as "Error"
+	Level       int       `json:"level"`    // number of the severity
+	Message     string    `json:"message"`  // raw message, JSON for structured messages
+	Caller      string    `json:"caller,omitempty"`
+	Function    string    `json:"function,omitempty"`
+	StackTrace  string    `json:"stack_trace,omitempty"`
+	TraceID     string    `json:"trace_id,omitempty"`
+	SpanID      string    `json:"span_id,omitempty"`
+	RepeatCount int       `json:"repeat_count,omitempty"`
+}
+
+func newPayload(log models.Log, rawMessage string) Payload {
+	return Payload{
+		ID:          log.ID,
+		CreatedAt:   log.CreatedAt,
+		Model:       string(log.Model),
+		Category:    log.Category,
+		Severity:    log.Severity.String(),
+		Level:       int(log.Severity),
+		Message:     rawMessage,
+		Caller:      log.Caller,
+		Function:    log.Function,
+		StackTrace:  log.StackTrace,
+		TraceID:     log.TraceID,
+		SpanID:      log.SpanID,
+		RepeatCount: log.RepeatCount,
+	}
+}
+
+type Option func(*webhookLogger) error
+
+// WithMethod sets the HTTP method of the requests. Defaults to POST.
+func WithMethod(method string) Option {
+	return func(l *webhookLogger) error {
+		l.method = strings.ToUpper(method)
+		return nil
+	}
+}
+
+// WithHeader adds a header to every request, e.g. for authentication.
+func WithHeader(key, value string) Option {
+	return func(l *webhookLogger) error {
+		l.headers.Set(key, value)
+		return nil
+	}
+}
+
+// WithSecret signs requests with HMAC-SHA256. The signature header is "sha256=" followed by the
+// hex encoded HMAC of the X-Logar-Timestamp header, a dot and the body, so that receivers can
+// reject old requests.
+func WithSecret(secret string) Option {
+	return func(l *webhookLogger) error {
+		l.secret = []byte(secret)
+		return nil
+	}
+}
+
+// WithSignatureHeader sets the header of the HMAC signature. Defaults to X-Logar-Signature.
+func WithSignatureHeader(header string) Option {
+	return func(l *webhookLogger) error {
+		l.signatureHeader = header
+		return nil
+	}
+}
+
+// WithTemplate renders the body with a text/template, such as "{{.Severity}}: {{.Message}}".
+// See format.NewTemplate for the data and functions of templates.
+func WithTemplate(text string) Option {
+	return func(l *webhookLogger) error {
+		formatter, err := format.NewTemplate(text)
+		if err != nil {
+			// Errors of text/template already start with "template:"
+			return err
+		}
+		l.formatter = formatter
+		l.jsonTemplate = nil
+		return nil
+	}
+}
+
+// WithFormatter renders the body with a formatter, such as format.Text.
+func WithFormatter(formatter format.Formatter) Option {
+	return func(l *webhookLogger) error {
+		l.formatter = formatter
+		l.jsonTemplate = nil
+		return nil
+	}
+}
+
+// WithJSONTemplate renders the body from a JSON document whose strings are templates like the ones
+// of WithTemplate, such as {"text": "[{{upper .Severity}}] {{.Message}}"}. Rendered strings are
+// escaped, so the body is always valid JSON.
+func WithJSONTemplate(document string) Option {
+	return func(l *webhookLogger) error {
+		var value any
+		if err := json.Unmarshal([]byte(document), &value); err != nil {
+			return fmt.Errorf("json template: %w", err)
+		}
+
+		tmpl, err := parseJSONTemplate(value)
+		if err != nil {
+			return fmt.Errorf("json template: %w", err)
+		}
+		l.jsonTemplate = tmpl
+		l.formatter = nil
+		return nil
+	}
+}
+
+// WithContentType overrides the content type of the body, which is text/plain for WithTemplate
+// and WithFormatter, and application/json otherwise.
+func WithContentType(contentType string) Option {
+	return func(l *webhookLogger) error {
+		l.contentType = contentType
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a request. Defaults to 10 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *webhookLogger) error {
+		l.timeout = timeout
+		return nil
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *webhookLogger) error {
+		l.client = client
+		return nil
+	}
+}
+
+// New creates a target sending every log in its own request to the URL. Without a template,
+// the body is the Payload as JSON.
+func New(targetURL string, opts ...Option) (*webhookLogger, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("webhooklogger: invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webhooklogger: invalid url %q, the scheme must be http or https", targetURL)
+	}
+
+	l := &webhookLogger{
+		url:             targetURL,
+		method:          http.MethodPost,
+		headers:         http.Header{},
+		signatureHeader: DefaultSignatureHeader,
+		client:          &http.Client{},
+		timeout:         10 * time.Second,
+	}
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, fmt.Errorf("webhooklogger: %w", err)
+		}
+	}
+	if l.timeout > 0 {
+		client := *l.client
+		client.Timeout = l.timeout
+		l.client = &client
+	}
+
+	return l, nil
+}
+
+type webhookLogger struct {
+	url             string
+	method          string
+	headers         http.Header
+	secret          []byte
+	signatureHeader string
+	formatter       format.Formatter
+	jsonTemplate    jsonTemplate
+	contentType     string
+	client          *http.Client
+	timeout         time.Duration
+}
+
+func (l *webhookLogger) Send(log models.Log, rawMessage string) error {
+	body, err := l.render(log, rawMessage)
+	if err != nil {
+		return fmt.Errorf("webhooklogger: rendering body: %w", err)
+	}
+
+	req, err := http.NewRequest(l.method, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range l.headers {
+		req.Header[key] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", l.defaultContentType())
+	}
+	if len(l.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(l.signatureHeader, "sha256="+Sign(l.secret, timestamp, body))
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhooklogger: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhooklogger: endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (l *webhookLogger) defaultContentType() string {
+	switch {
+	case l.contentType != "":
+		return l.contentType
+	case l.formatter != nil:
+		return "text/plain; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+func (l *webhookLogger) render(log models.Log, rawMessage string) ([]byte, error) {
+	switch {
+	case l.formatter != nil:
+		text, err := l.formatter.Format(log, rawMessage)
+		return []byte(text), err
+	case l.jsonTemplate != nil:
+		value, err := renderJSONTemplate(l.jsonTemplate, format.NewData(log, rawMessage))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	default:
+		return json.Marshal(newPayload(log, rawMessage))
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp, a dot and the body, as sent in the
+// signature header. Receivers can use it to verify requests.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type settings struct {
+	URL             string            `json:"url"`
+	Method          string            `json:"method"`
+	Headers         map[string]string `json:"headers"`
+	Secret          string            `json:"secret"`
+	SignatureHeader string            `json:"signature_header"`
+	Format          string            `json:"format"`        // text, logfmt, json or markdown instead of the default JSON payload
+	Template        string            `json:"template"`      // text/template, see format.NewTemplate
+	JSONTemplate    any               `json:"json_template"` // object, or a string holding one
+	ContentType     string            `json:"content_type"`
+	Timeout         string            `json:"timeout"` // such as "10s"
+}
+
+func newFromSettings(s proxy.Settings) (proxy.ProxyTarget, error) {
+	var cfg settings
+	if err := s.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	if (cfg.Format != "" || cfg.Template != "") && cfg.JSONTemplate != nil {
+		return nil, errors.New("format and template can't be used together with json_template")
+	}
+
+	var opts []Option
+	if cfg.Method != "" {
+		opts = append(opts, WithMethod(cfg.Method))
+	}
+	for key, value := range cfg.Headers {
+		opts = append(opts, WithHeader(key, value))
+	}
+	if cfg.Secret != "" {
+		opts = append(opts, WithSecret(cfg.Secret))
+	}
+	if cfg.SignatureHeader != "" {
+		opts = append(opts, WithSignatureHeader(cfg.SignatureHeader))
+	}
+	if cfg.Format != "" || cfg.Template != "" {
+		formatter, err := format.FromSettings(cfg.Format, cfg.Template, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithFormatter(formatter))
+		if cfg.Template == "" && strings.EqualFold(cfg.Format, "json") {
+			opts = append(opts, WithContentType("application/json"))
+		}
+	}
+	switch document := cfg.JSONTemplate.(type) {
+	case nil:
+	case string:
+		opts = append(opts, WithJSONTemplate(document))
+	default:
+		data, err := json.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("json_template: %w", err)
+		}
+		opts = append(opts, WithJSONTemplate(string(data)))
+	}
+	if cfg.ContentType != "" {
+		opts = append(opts, WithContentType(cfg.ContentType))
+	}
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout: %w", err)
+		}
+		opts = append(opts, WithTimeout(timeout))
+	}
+
+	target, err := New(cfg.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,314 @@
+package webhooklogger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+	"sadk.dev/logar/proxy/format"
+)
+
+type request struct {
+	method string
+	header http.Header
+	body   []byte
+}
+
+// newServer returns a server recording the requests it receives and responding with status.
+func newServer(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+var testLog = models.Log{
+	ID:        7,
+	CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	Model:     "payments",
+	Category:  "checkout",
+	Severity:  models.Severity_Error,
+	Caller:    "main.go:12",
+}
+
+func TestSendSignsRequests(t *testing.T) {
+	server, requests := newServer(t, http.StatusOK)
+	target, err := New(server.URL, WithSecret("s3cret"), WithSignatureHeader("X-Signature"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, "card declined"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	req := <-requests
+
+	timestamp := req.header.Get(TimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header = %q, want unix seconds", timestamp)
+	}
+	if age := time.Since(time.Unix(sent, 0)); age < 0 || age > time.Minute {
+		t.Errorf("timestamp header = %q, want the current time", timestamp)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get("X-Signature"); got != want {
+		t.Errorf("signature header = %q, want %q", got, want)
+	}
+	if got := req.header.Get(DefaultSignatureHeader); got != "" {
+		t.Errorf("default signature header = %q, want it unset", got)
+	}
+}
+
+func TestSendWithoutSecret(t *testing.T) {
+	server, requests := newServer(t, http.StatusOK)
+	target, err := New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, "card declined"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	req := <-requests
+	if req.header.Get(DefaultSignatureHeader) != "" || req.header.Get(TimestampHeader) != "" {
+		t.Errorf("headers = %v, want no signature", req.header)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac key
+	got := Sign([]byte("key"), "1700000000", []byte("{}"))
+	if want := "9d713ed406bb7076d4123f0dc2c39d2df5c654ed4b0cd56b52c8b4c940bd63ae"; got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestSendBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		message     string
+		contentType string
+		want        string // compared as JSON when contentType is application/json
+	}{
+		{
+			name:        "payload",
+			message:     `{"amount":12}`,
+			contentType: "application/json",
+			want: `{"id":7,"created_at":"2024-01-02T03:04:05Z","model":"payments","category":"checkout",` +
+				`"severity":"Error","level":5,"message":"{\"amount\":12}","caller":"main.go:12"}`,
+		},
+		{
+			name:        "template",
+			opts:        []Option{WithTemplate(`{{upper .Severity}} {{.Log.Model}}/{{.Log.Category}}: {{.Message}}`)},
+			message:     "card declined",
+			contentType: "text/plain; charset=utf-8",
+			want:        "ERROR payments/checkout: card declined",
+		},
+		{
+			name:        "template with json func",
+			opts:        []Option{WithTemplate(`{"text": {{json .Message}}}`), WithContentType("application/json")},
+			message:     `say "hi"`,
+			contentType: "application/json",
+			want:        `{"text": "say \"hi\""}`,
+		},
+		{
+			name:        "template of a structured message",
+			opts:        []Option{WithTemplate(`{{emoji .Log.Severity}} {{.Message}}{{range .Fields}} {{.Name}}={{.Value}}{{end}}`)},
+			message:     `{"message":"card declined","amount":12}`,
+			contentType: "text/plain; charset=utf-8",
+			want:        "❌ card declined amount=12",
+		},
+		{
+			name:        "formatter",
+			opts:        []Option{WithFormatter(format.Logfmt)},
+			message:     "card declined",
+			contentType: "text/plain; charset=utf-8",
+			want:        `time=2024-01-02T03:04:05.000Z level=error model=payments category=checkout msg="card declined" caller=main.go:12`,
+		},
+		{
+			name:        "json template escapes rendered strings",
+			opts:        []Option{WithJSONTemplate(`{"text": "[{{lower .Severity}}] {{.RawMessage}}", "level": 1, "tags": ["{{.Log.Model}}", "static"]}`)},
+			message:     `quote " and newline` + "\n",
+			contentType: "application/json",
+			want:        `{"text": "[error] quote \" and newline\n", "level": 1, "tags": ["payments", "static"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newServer(t, http.StatusOK)
+			target, err := New(server.URL, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := target.Send(testLog, tt.message); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			req := <-requests
+
+			if got := req.header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if tt.contentType != "application/json" {
+				if string(req.body) != tt.want {
+					t.Errorf("body = %q, want %q", req.body, tt.want)
+				}
+				return
+			}
+
+			var got, want any
+			if err := json.Unmarshal(req.body, &got); err != nil {
+				t.Fatalf("body %q is not JSON: %v", req.body, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("body = %s, want %s", req.body, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendRequest(t *testing.T) {
+	server, requests := newServer(t, http.StatusAccepted)
+	target, err := New(server.URL, WithMethod("put"), WithHeader("Authorization", "Bearer token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, "card declined"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	req := <-requests
+	if req.method != http.MethodPut {
+		t.Errorf("method = %q, want PUT", req.method)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+}
+
+func TestSendErrorStatus(t *testing.T) {
+	server, _ := newServer(t, http.StatusInternalServerError)
+	target, err := New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Send(testLog, "card declined"); err == nil {
+		t.Error("Send() error = nil, want an error for a 500 response")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		opts []Option
+	}{
+		{"scheme", "ftp://example.com", nil},
+		{"template", "http://example.com", []Option{WithTemplate("{{.Message")}},
+		{"json template document", "http://example.com", []Option{WithJSONTemplate(`{"text":`)}},
+		{"json template string", "http://example.com", []Option{WithJSONTemplate(`{"text": "{{.Missing"}`)}},
+		{"template function", "http://example.com", []Option{WithTemplate("{{shout .Message}}")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.url, tt.opts...); err == nil {
+				t.Error("New() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestNewFromSettings(t *testing.T) {
+	server, requests := newServer(t, http.StatusOK)
+	target, err := proxy.NewTarget("webhook", proxy.Settings{
+		"url":           server.URL,
+		"secret":        "s3cret",
+		"json_template": map[string]any{"text": "{{.Severity}}: {{.Message}}"},
+	})
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+
+	if err := target.Send(testLog, "card declined"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	req := <-requests
+	if string(req.body) != `{"text":"Error: card declined"}` {
+		t.Errorf("body = %s, want the rendered json_template", req.body)
+	}
+	if req.header.Get(DefaultSignatureHeader) == "" {
+		t.Error("signature header is missing")
+	}
+
+	_, err = proxy.NewTarget("webhook", proxy.Settings{"url": server.URL, "template": "{{.Message}}", "json_template": "{}"})
+	if err == nil {
+		t.Error("NewTarget() error = nil, want an error for template and json_template")
+	}
+	_, err = proxy.NewTarget("webhook", proxy.Settings{"url": server.URL, "format": "xml"})
+	if err == nil {
+		t.Error("NewTarget() error = nil, want an error for an unknown format")
+	}
+}
+
+func TestNewFromSettingsFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    proxy.Settings
+		contentType string
+		want        string
+	}{
+		{"text", proxy.Settings{"format": "text"}, "text/plain; charset=utf-8", "2024-01-02T03:04:05.000Z [ERROR] payments/checkout: card declined caller=main.go:12"},
+		{"json", proxy.Settings{"format": "json"}, "application/json", `{"time":"2024-01-02T03:04:05.000Z","level":"error","model":"payments","category":"checkout","message":"card declined","id":7,"caller":"main.go:12"}`},
+		// A template takes precedence over the format
+		{"template", proxy.Settings{"format": "json", "template": "{{upper .Severity}}: {{.Message}}"}, "text/plain; charset=utf-8", "ERROR: card declined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newServer(t, http.StatusOK)
+			tt.settings["url"] = server.URL
+			target, err := proxy.NewTarget("webhook", tt.settings)
+			if err != nil {
+				t.Fatalf("NewTarget() error = %v", err)
+			}
+
+			if err := target.Send(testLog, "card declined"); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			req := <-requests
+			if got := req.header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if string(req.body) != tt.want {
+				t.Errorf("body = %s, want %s", req.body, tt.want)
+			}
+		})
+	}
+}
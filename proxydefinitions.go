@@ -0,0 +1,403 @@
+package logar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"sadk.dev/logar/logfilter"
+	"sadk.dev/logar/models"
+	"sadk.dev/logar/proxy"
+)
+
+var (
+	ErrProxyExists = errors.New("proxy already exists")
+	// ErrProxyNotManaged is returned when updating or removing a proxy passed to New or declared in
+	// the config file. Those can only be enabled and disabled, until the app is restarted.
+	ErrProxyNotManaged = errors.New("proxy isn't managed at runtime")
+	ErrInvalidProxy    = errors.New("invalid proxy")
+)
+
+// ProxyInfo describes a proxy.
+type ProxyInfo struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Managed is whether the proxy was added at runtime. Managed proxies are stored in the database
+	// and can be updated and removed, others are passed to New or declared in the config file.
+	Managed bool `json:"managed"`
+
+	// Definition of managed proxies
+	Type     string          `json:"type,omitempty"`
+	Filter   *logfilter.Spec `json:"filter,omitempty"`
+	Settings proxy.Settings  `json:"settings,omitempty"` // with secrets redacted, see proxy.RegisterTarget
+
+	// Error is why a stored proxy couldn't be created when the app started. Such proxies don't get
+	// logs until they are updated with a valid definition.
+	Error string `json:"error,omitempty"`
+}
+
+// invalidDefinition is a stored proxy that couldn't be created when the app started.
+type invalidDefinition struct {
+	definition *models.ProxyDefinition
+	err        error
+}
+
+// loadDefinitions creates the proxies stored in the database. Definitions that are no longer valid,
+// for example because the package of their target isn't imported anymore, are kept to be fixed.
+func (p *ProxiesImpl) loadDefinitions() error {
+	var rows []models.ProxyDefinition
+	if err := p.core.db.Order("id ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	for i := range rows {
+		row := &rows[i]
+
+		if _, ok := p.findQueue(row.Name); ok {
+			p.invalid[row.Name] = invalidDefinition{definition: row, err: fmt.Errorf("%w: %q", ErrProxyExists, row.Name)}
+			continue
+		}
+
+		definition, err := decodeDefinition(row)
+		if err == nil {
+			var built proxy.Proxy
+			built, err = buildManagedProxy(definition)
+			if err == nil {
+				p.queues = append(p.queues, p.newQueue(built, row))
+				continue
+			}
+		}
+		p.invalid[row.Name] = invalidDefinition{definition: row, err: err}
+	}
+
+	return nil
+}
+
+func decodeDefinition(row *models.ProxyDefinition) (proxy.Definition, error) {
+	definition := proxy.Definition{Name: row.Name, Type: row.Type}
+	if err := json.Unmarshal([]byte(row.Filter), &definition.Filter); err != nil {
+		return proxy.Definition{}, fmt.Errorf("%w: filter: %v", ErrInvalidProxy, err)
+	}
+	if err := json.Unmarshal([]byte(row.Settings), &definition.Settings); err != nil {
+		return proxy.Definition{}, fmt.Errorf("%w: settings: %v", ErrInvalidProxy, err)
+	}
+	return definition, nil
+}
+
+// encodeDefinition sets the type, filter and settings of the row to the ones of the definition.
+func encodeDefinition(row *models.ProxyDefinition, definition proxy.Definition) error {
+	filter, err := json.Marshal(definition.Filter)
+	if err != nil {
+		return fmt.Errorf("%w: filter: %v", ErrInvalidProxy, err)
+	}
+	settings, err := json.Marshal(definition.Settings)
+	if err != nil {
+		return fmt.Errorf("%w: settings: %v", ErrInvalidProxy, err)
+	}
+
+	row.Type = definition.Type
+	row.Filter = string(filter)
+	row.Settings = string(settings)
+	return nil
+}
+
+func buildManagedProxy(definition proxy.Definition) (proxy.Proxy, error) {
+	if definition.Name == "" {
+		return proxy.Proxy{}, fmt.Errorf("%w: name: required", ErrInvalidProxy)
+	}
+
+	built, cerr := buildProxyDefinition(definition)
+	if cerr != nil {
+		return proxy.Proxy{}, fmt.Errorf("%w: %s: %v", ErrInvalidProxy, cerr.Key, cerr.Err)
+	}
+	return built, nil
+}
+
+func queueInfo(queue *proxyQueue) ProxyInfo {
+	info := ProxyInfo{
+		Name:    queue.proxy.Name(),
+		Enabled: queue.enabled.Load(),
+	}
+	if queue.definition != nil {
+		definitionInfo(&info, queue.definition)
+	}
+	return info
+}
+
+func definitionInfo(info *ProxyInfo, row *models.ProxyDefinition) {
+	info.Managed = true
+	info.Type = row.Type
+
+	var filter logfilter.Spec
+	if json.Unmarshal([]byte(row.Filter), &filter) == nil {
+		info.Filter = &filter
+	}
+	var settings proxy.Settings
+	if json.Unmarshal([]byte(row.Settings), &settings) == nil {
+		info.Settings = settings.Redact(row.Type)
+	}
+}
+
+func invalidInfo(invalid invalidDefinition) ProxyInfo {
+	info := ProxyInfo{
+		Name:    invalid.definition.Name,
+		Enabled: invalid.definition.Enabled,
+		Error:   invalid.err.Error(),
+	}
+	definitionInfo(&info, invalid.definition)
+	return info
+}
+
+func (p *ProxiesImpl) ListProxies() []ProxyInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	infos := make([]ProxyInfo, 0, len(p.queues)+len(p.invalid))
+	for _, queue := range p.queues {
+		infos = append(infos, queueInfo(queue))
+	}
+
+	invalid := make([]ProxyInfo, 0, len(p.invalid))
+	for _, definition := range p.invalid {
+		invalid = append(invalid, invalidInfo(definition))
+	}
+	sort.Slice(invalid, func(i, j int) bool { return invalid[i].Name < invalid[j].Name })
+
+	return append(infos, invalid...)
+}
+
+func (p *ProxiesImpl) GetProxy(name string) (ProxyInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if queue, ok := p.findQueue(name); ok {
+		return queueInfo(queue), nil
+	}
+	if invalid, ok := p.invalid[name]; ok {
+		return invalidInfo(invalid), nil
+	}
+	return ProxyInfo{}, fmt.Errorf("%w: %q", ErrProxyNotFound, name)
+}
+
+func (p *ProxiesImpl) CreateProxy(definition proxy.Definition) (ProxyInfo, error) {
+	built, err := buildManagedProxy(definition)
+	if err != nil {
+		return ProxyInfo{}, err
+	}
+
+	row := &models.ProxyDefinition{Name: definition.Name, Enabled: true}
+	if err := encodeDefinition(row, definition); err != nil {
+		closeProxy(built)
+		return ProxyInfo{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, exists := p.findQueue(definition.Name)
+	if _, ok := p.invalid[definition.Name]; exists || ok {
+		closeProxy(built)
+		return ProxyInfo{}, fmt.Errorf("%w: %q", ErrProxyExists, definition.Name)
+	}
+
+	if err := p.core.db.Create(row).Error; err != nil {
+		closeProxy(built)
+		return ProxyInfo{}, err
+	}
+
+	queue := p.newQueue(built, row)
+	p.queues = append(p.queues, queue)
+	if p.started {
+		p.startQueue(queue)
+	}
+
+	return queueInfo(queue), nil
+}
+
+func (p *ProxiesImpl) UpdateProxy(name string, definition proxy.Definition) (ProxyInfo, error) {
+	if definition.Name == "" {
+		definition.Name = name
+	}
+	if definition.Name != name {
+		return ProxyInfo{}, fmt.Errorf("%w: name: proxies can't be renamed, remove it and create a new one", ErrInvalidProxy)
+	}
+	definition.Settings = p.restoreRedacted(name, definition.Settings)
+
+	built, err := buildManagedProxy(definition)
+	if err != nil {
+		return ProxyInfo{}, err
+	}
+
+	p.mu.Lock()
+
+	var row *models.ProxyDefinition
+	index := p.queueIndex(name)
+	if index >= 0 {
+		row = p.queues[index].definition
+		if row == nil {
+			p.mu.Unlock()
+			closeProxy(built)
+			return ProxyInfo{}, fmt.Errorf("%w: %q", ErrProxyNotManaged, name)
+		}
+	} else if invalid, ok := p.invalid[name]; ok {
+		row = invalid.definition
+	} else {
+		p.mu.Unlock()
+		closeProxy(built)
+		return ProxyInfo{}, fmt.Errorf("%w: %q", ErrProxyNotFound, name)
+	}
+
+	updated := *row
+	if err := encodeDefinition(&updated, definition); err != nil {
+		p.mu.Unlock()
+		closeProxy(built)
+		return ProxyInfo{}, err
+	}
+	if err := p.core.db.Save(&updated).Error; err != nil {
+		p.mu.Unlock()
+		closeProxy(built)
+		return ProxyInfo{}, err
+	}
+
+	queue := p.newQueue(built, &updated)
+	var old *proxyQueue
+	if index >= 0 {
+		old = p.queues[index]
+		queue.metrics = old.metrics
+		p.queues[index] = queue
+	} else {
+		delete(p.invalid, name)
+		p.queues = append(p.queues, queue)
+	}
+	if p.started {
+		p.startQueue(queue)
+	}
+	started := p.started
+	p.mu.Unlock()
+
+	// Logs queued for the old target are still sent to it before it is closed
+	if old != nil {
+		p.stopQueue(old, started)
+	}
+
+	return queueInfo(queue), nil
+}
+
+// restoreRedacted returns the settings with the values that are still proxy.Redacted, as returned by
+// GetProxy, replaced by the stored ones, so that definitions can be edited without knowing the secrets.
+func (p *ProxiesImpl) restoreRedacted(name string, settings proxy.Settings) proxy.Settings {
+	p.mu.RLock()
+	var row *models.ProxyDefinition
+	if queue, ok := p.findQueue(name); ok {
+		row = queue.definition
+	} else if invalid, ok := p.invalid[name]; ok {
+		row = invalid.definition
+	}
+	var stored proxy.Settings
+	if row != nil {
+		_ = json.Unmarshal([]byte(row.Settings), &stored)
+	}
+	p.mu.RUnlock()
+
+	restored := make(proxy.Settings, len(settings))
+	for key, value := range settings {
+		if value == proxy.Redacted {
+			if storedValue, ok := stored[key]; ok {
+				value = storedValue
+			}
+		}
+		restored[key] = value
+	}
+	return restored
+}
+
+func (p *ProxiesImpl) RemoveProxy(name string) error {
+	p.mu.Lock()
+
+	if invalid, ok := p.invalid[name]; ok {
+		defer p.mu.Unlock()
+		if err := p.core.db.Delete(invalid.definition).Error; err != nil {
+			return err
+		}
+		delete(p.invalid, name)
+		return nil
+	}
+
+	index := p.queueIndex(name)
+	if index < 0 {
+		p.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrProxyNotFound, name)
+	}
+
+	queue := p.queues[index]
+	if queue.definition == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrProxyNotManaged, name)
+	}
+
+	if err := p.core.db.Delete(queue.definition).Error; err != nil {
+		p.mu.Unlock()
+		return err
+	}
+
+	p.queues = append(p.queues[:index:index], p.queues[index+1:]...)
+	started := p.started
+	p.mu.Unlock()
+
+	p.stopQueue(queue, started)
+	return nil
+}
+
+func (p *ProxiesImpl) SetProxyEnabled(name string, enabled bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var row *models.ProxyDefinition
+	queue, ok := p.findQueue(name)
+	if ok {
+		row = queue.definition
+	} else if invalid, isInvalid := p.invalid[name]; isInvalid {
+		row = invalid.definition
+	} else {
+		return fmt.Errorf("%w: %q", ErrProxyNotFound, name)
+	}
+
+	if row != nil {
+		if err := p.core.db.Model(row).Update("enabled", enabled).Error; err != nil {
+			return err
+		}
+		row.Enabled = enabled
+	}
+	if queue != nil {
+		queue.enabled.Store(enabled)
+	}
+
+	return nil
+}
+
+// stopQueue waits for the worker of a queue that was removed to deliver the queued logs, and closes its target.
+func (p *ProxiesImpl) stopQueue(queue *proxyQueue, started bool) {
+	if !started {
+		closeProxy(queue.proxy)
+		return
+	}
+
+	close(queue.stop)
+	<-queue.stopped
+}
+
+// queueIndex returns the index of the queue of the proxy, or -1. p.mu must be held.
+func (p *ProxiesImpl) queueIndex(name string) int {
+	for i, queue := range p.queues {
+		if queue.proxy.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// closeProxy closes the target of a proxy whose worker never ran, such as when storing its definition failed.
+func closeProxy(p proxy.Proxy) {
+	_ = p.Close()
+}
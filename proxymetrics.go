@@ -0,0 +1,255 @@
+package logar
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sadk.dev/logar/proxy"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets.
+var latencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// ProxyMetrics are the delivery counters of a proxy since the app started. Counters of proxies
+// updated at runtime are kept, the ones of removed proxies are lost.
+type ProxyMetrics struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Healthy is false once ProxyDeliveryConfig.UnhealthyAfter attempts in a row failed, until a log is delivered
+	Healthy bool `json:"healthy"`
+	Queued  int  `json:"queued"` // logs waiting to be sent
+
+	Matched int64 `json:"matched"` // logs that passed the filter while the proxy was enabled
+	Sent    int64 `json:"sent"`    // logs delivered
+	Failed  int64 `json:"failed"`  // failed attempts, or failed batches of batch targets
+	Retried int64 `json:"retried"` // attempts after a failed one
+	Dropped int64 `json:"dropped"` // logs stored as dead letters, because the queue was full or the attempts ran out
+
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+
+	Latency LatencyHistogram `json:"latency"` // duration of every attempt, or of every batch of batch targets
+}
+
+// LatencyHistogram counts durations in cumulative buckets, as Prometheus does: every bucket counts the
+// durations up to its bound. Durations over the last bound are only in Count.
+type LatencyHistogram struct {
+	Buckets []LatencyBucket `json:"buckets"`
+	Count   int64           `json:"count"`
+	SumMs   float64         `json:"sum_ms"`
+}
+
+type LatencyBucket struct {
+	LeMs  float64 `json:"le_ms"` // upper bound in milliseconds
+	Count int64   `json:"count"`
+}
+
+type proxyMetrics struct {
+	matched             atomic.Int64
+	sent                atomic.Int64
+	failed              atomic.Int64
+	retried             atomic.Int64
+	dropped             atomic.Int64
+	consecutiveFailures atomic.Int64
+
+	mu            sync.Mutex
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+	buckets       []int64 // per bound, not cumulative, and one more for durations over the last bound
+	count         int64
+	sum           time.Duration
+}
+
+func newProxyMetrics() *proxyMetrics {
+	return &proxyMetrics{buckets: make([]int64, len(latencyBounds)+1)}
+}
+
+func (m *proxyMetrics) observe(latency time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && latency > latencyBounds[i] {
+		i++
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buckets[i]++
+	m.count++
+	m.sum += latency
+}
+
+// success records delivered logs and returns the failed attempts in a row before them.
+func (m *proxyMetrics) success(logs int) int64 {
+	m.sent.Add(int64(logs))
+
+	m.mu.Lock()
+	m.lastSuccessAt = time.Now()
+	m.mu.Unlock()
+
+	return m.consecutiveFailures.Swap(0)
+}
+
+// failure records a failed attempt and returns the failed attempts in a row, including it.
+func (m *proxyMetrics) failure(err error) int64 {
+	m.failed.Add(1)
+
+	m.mu.Lock()
+	m.lastError = err.Error()
+	m.lastErrorAt = time.Now()
+	m.mu.Unlock()
+
+	return m.consecutiveFailures.Add(1)
+}
+
+func (m *proxyMetrics) snapshot(unhealthyAfter int) ProxyMetrics {
+	metrics := ProxyMetrics{
+		Matched:             m.matched.Load(),
+		Sent:                m.sent.Load(),
+		Failed:              m.failed.Load(),
+		Retried:             m.retried.Load(),
+		Dropped:             m.dropped.Load(),
+		ConsecutiveFailures: m.consecutiveFailures.Load(),
+	}
+	metrics.Healthy = metrics.ConsecutiveFailures < int64(unhealthyAfter)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics.LastError = m.lastError
+	if !m.lastErrorAt.IsZero() {
+		lastErrorAt := m.lastErrorAt
+		metrics.LastErrorAt = &lastErrorAt
+	}
+	if !m.lastSuccessAt.IsZero() {
+		lastSuccessAt := m.lastSuccessAt
+		metrics.LastSuccessAt = &lastSuccessAt
+	}
+
+	metrics.Latency = LatencyHistogram{
+		Buckets: make([]LatencyBucket, len(latencyBounds)),
+		Count:   m.count,
+		SumMs:   float64(m.sum) / float64(time.Millisecond),
+	}
+	var cumulative int64
+	for i, bound := range latencyBounds {
+		cumulative += m.buckets[i]
+		metrics.Latency.Buckets[i] = LatencyBucket{
+			LeMs:  float64(bound) / float64(time.Millisecond),
+			Count: cumulative,
+		}
+	}
+
+	return metrics
+}
+
+func (p *ProxiesImpl) queueMetrics(queue *proxyQueue) ProxyMetrics {
+	metrics := queue.metrics.snapshot(p.core.config.ProxyDeliveryConfig.UnhealthyAfter)
+	metrics.Name = queue.proxy.Name()
+	metrics.Enabled = queue.enabled.Load()
+	metrics.Queued = len(queue.entries)
+	return metrics
+}
+
+func (p *ProxiesImpl) GetProxyMetrics(name string) (ProxyMetrics, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	queue, ok := p.findQueue(name)
+	if !ok {
+		return ProxyMetrics{}, fmt.Errorf("%w: %q", ErrProxyNotFound, name)
+	}
+	return p.queueMetrics(queue), nil
+}
+
+func (p *ProxiesImpl) GetAllProxyMetrics() []ProxyMetrics {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	metrics := make([]ProxyMetrics, len(p.queues))
+	for i, queue := range p.queues {
+		metrics[i] = p.queueMetrics(queue)
+	}
+	return metrics
+}
+
+// proxyMetricsAction is the path of the built-in action returning the metrics of every proxy.
+const proxyMetricsAction = "Logar/ProxyMetrics"
+
+// registerMetricsAction adds the built-in metrics action, unless an action with its path was configured.
+func (p *ProxiesImpl) registerMetricsAction() {
+	if _, ok := p.core.actionManager.GetActionDetails(proxyMetricsAction); ok {
+		return
+	}
+
+	p.core.actionManager.AddAction(Action{
+		Path:        proxyMetricsAction,
+		Description: "Get the delivery counters, last error and latency of every proxy",
+		Func:        p.GetAllProxyMetrics,
+	})
+}
+
+// recordAttempt updates the metrics of the queue with an attempt to send a log.
+func (p *ProxiesImpl) recordAttempt(queue *proxyQueue, attempt int, latency time.Duration, err error) {
+	if attempt > 1 {
+		queue.metrics.retried.Add(1)
+	}
+	// Batch targets only queued the log, its delivery is recorded by recordBatch
+	if queue.batched && err == nil {
+		return
+	}
+
+	queue.metrics.observe(latency)
+	if err != nil {
+		p.recordFailure(queue, err)
+		return
+	}
+	p.recordSuccess(queue, 1)
+}
+
+// recordBatch updates the metrics of the queue with a batch sent by its target, and stores the logs
+// that weren't delivered as dead letters.
+func (p *ProxiesImpl) recordBatch(queue *proxyQueue, result proxy.BatchResult) {
+	queue.metrics.observe(result.Latency)
+	if delivered := len(result.Entries) - len(result.Failed); delivered > 0 {
+		p.recordSuccess(queue, delivered)
+	}
+	if result.Err == nil {
+		return
+	}
+
+	p.recordFailure(queue, result.Err)
+	for _, entry := range result.Failed {
+		p.storeDeadLetter(queue, proxyEntry{log: entry.Log, rawMessage: entry.RawMessage}, 1, result.Err)
+	}
+}
+
+// recordSuccess records delivered logs, logging to LogarLogs if the proxy was unhealthy.
+func (p *ProxiesImpl) recordSuccess(queue *proxyQueue, logs int) {
+	if failures := queue.metrics.success(logs); failures >= int64(p.core.config.ProxyDeliveryConfig.UnhealthyAfter) {
+		p.core.logger.Info(LogarLogs, fmt.Sprintf("Proxy %s recovered after %d failed attempts", queue.proxy.Name(), failures), proxiesCategory)
+	}
+}
+
+// recordFailure records a failed attempt, logging to LogarLogs when the proxy becomes unhealthy.
+func (p *ProxiesImpl) recordFailure(queue *proxyQueue, err error) {
+	// Only logged once, the log may be queued for the failing proxy too
+	if failures := queue.metrics.failure(err); failures == int64(p.core.config.ProxyDeliveryConfig.UnhealthyAfter) {
+		p.core.logger.Error(LogarLogs, fmt.Sprintf("Proxy %s failed %d times in a row: %v", queue.proxy.Name(), failures, err), proxiesCategory)
+	}
+}
@@ -0,0 +1,124 @@
+package logar
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (l *LoggerImpl) Recover(model Model, category string, rePanic bool) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	l.logPanic(model, category, value, nil)
+	if rePanic {
+		panic(value)
+	}
+}
+
+func (l *LoggerImpl) Go(model Model, category string, rePanic bool, fn func()) {
+	go func() {
+		defer l.Recover(model, category, rePanic)
+		fn()
+	}()
+}
+
+func (l *LoggerImpl) RecoverMiddleware(model Model, category string, rePanic bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return RecoverHandler(next, rePanic, func(r *http.Request, value any) {
+			logger := &LoggerImpl{core: l.core, ctx: r.Context()}
+			logger.logPanic(model, category, value, r)
+		})
+	}
+}
+
+// RecoverHandler returns a handler calling next that passes its panics to logPanic and responds
+// with 500, if the response hasn't started. It's the RecoverMiddleware of every logger, for loggers
+// without an App, such as remote clients.
+func RecoverHandler(next http.Handler, rePanic bool, logPanic func(r *http.Request, value any)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Make sure handlers further down can add context values that will be part of the panic log
+		if _, ok := GetContextValues(r.Context()); !ok {
+			r = r.WithContext(PrepareContext(r.Context(), nil))
+		}
+
+		recorder := &headerRecorder{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+
+			logPanic(r, value)
+
+			// http.ErrAbortHandler is used to abort a response on purpose and should not be swallowed
+			if rePanic || value == http.ErrAbortHandler {
+				panic(value)
+			}
+			// The status can't be changed once the handler started the response
+			if !recorder.wroteHeader {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// headerRecorder records whether the response headers were sent.
+type headerRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (r *headerRecorder) WriteHeader(status int) {
+	// Informational responses are followed by the final one
+	if status >= 200 {
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *headerRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *headerRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+func (r *headerRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (l *LoggerImpl) logPanic(model Model, category string, value any, r *http.Request) {
+	l.print(model, PanicMessage(value, r), category, Fatal, currentStackTrace())
+}
+
+// PanicMessage returns the message logged for a recovered panic value, with the request it happened
+// in if r isn't nil.
+func PanicMessage(value any, r *http.Request) Map {
+	message := Map{
+		"message": "panic: " + fmt.Sprint(value),
+		"panic":   fmt.Sprint(value),
+	}
+	if err, ok := value.(error); ok {
+		message["error"] = err.Error()
+	}
+	if r != nil {
+		message["request"] = Map{
+			"method":      r.Method,
+			"url":         r.URL.String(),
+			"host":        r.Host,
+			"remote_addr": r.RemoteAddr,
+			"user_agent":  r.UserAgent(),
+			"referer":     r.Referer(),
+		}
+	}
+	return message
+}
@@ -0,0 +1,45 @@
+package remotelogger
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/models"
+)
+
+func (l *Client) Recover(model logar.Model, category string, rePanic bool) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	l.logPanic(model, category, value, nil)
+	if rePanic {
+		panic(value)
+	}
+}
+
+func (l *Client) Go(model logar.Model, category string, rePanic bool, fn func()) {
+	go func() {
+		defer l.Recover(model, category, rePanic)
+		fn()
+	}()
+}
+
+func (l *Client) RecoverMiddleware(model logar.Model, category string, rePanic bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return logar.RecoverHandler(next, rePanic, func(r *http.Request, value any) {
+			logger := &Client{c: l.c, ctx: r.Context()}
+			logger.logPanic(model, category, value, r)
+		})
+	}
+}
+
+func (l *Client) logPanic(model logar.Model, category string, value any, r *http.Request) {
+	l.print(model, logar.PanicMessage(value, r), category, models.Severity_Fatal, stackTrace())
+}
+
+func stackTrace() string {
+	return string(debug.Stack())
+}
@@ -0,0 +1,644 @@
+// Package remotelogger implements logar.Logger for services that send their logs to a central logar
+// server instead of storing them. Logs are buffered and sent in batches to the POST /ingest endpoint
+// of the server, and written to a spool directory while the server can't be reached.
+package remotelogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"sadk.dev/logar"
+	"sadk.dev/logar/models"
+)
+
+var (
+	ErrBufferFull = errors.New("remotelogger: buffer is full, log dropped")
+	ErrClosed     = errors.New("remotelogger: client is closed")
+)
+
+type Option func(*client)
+
+// WithToken authenticates with an ingest token, created in the panel or with the /ingest-tokens API.
+func WithToken(token string) Option {
+	return func(c *client) {
+		c.headers.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithHeader adds a header to every request, e.g. for a proxy in front of the server.
+func WithHeader(key, value string) Option {
+	return func(c *client) {
+		c.headers.Set(key, value)
+	}
+}
+
+// WithBatchSize sets how many logs are sent in a single request. Defaults to 100.
+func WithBatchSize(size int) Option {
+	return func(c *client) {
+		if size > 0 {
+			c.batchSize = size
+		}
+	}
+}
+
+// WithFlushInterval sets how often buffered logs are sent, even if the batch isn't full. Defaults to 2 seconds.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *client) {
+		if interval > 0 {
+			c.flushInterval = interval
+		}
+	}
+}
+
+// WithMaxBufferSize sets how many logs can wait in memory. Logs written to a full buffer are dropped
+// and ErrBufferFull is returned. Defaults to 10000.
+func WithMaxBufferSize(size int) Option {
+	return func(c *client) {
+		if size > 0 {
+			c.maxBufferSize = size
+		}
+	}
+}
+
+// WithRetry sets how many times a failed request is retried and the delay before the first retry,
+// which doubles after every attempt. Defaults to 3 retries starting at 500ms.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(c *client) {
+		c.maxRetries = max(maxRetries, 0)
+		if backoff > 0 {
+			c.backoff = backoff
+		}
+	}
+}
+
+// WithSpool writes batches that can't be delivered to dir, and sends them once the server is reachable
+// again, including after a restart. Without a spool, such batches are dropped.
+func WithSpool(dir string) Option {
+	return func(c *client) {
+		c.spoolDir = dir
+	}
+}
+
+// WithMaxSpoolSize sets the size of the spool directory in bytes. The oldest batches are dropped
+// to stay below it. Defaults to 64 MiB.
+func WithMaxSpoolSize(size int64) Option {
+	return func(c *client) {
+		if size > 0 {
+			c.maxSpoolSize = size
+		}
+	}
+}
+
+// WithShutdownTimeout sets how long Close tries to send the remaining logs. Defaults to 10 seconds.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(c *client) {
+		c.shutdownTimeout = timeout
+	}
+}
+
+// WithCaller captures the file:line and function that produced each log, as logar.WithCaller does.
+func WithCaller(skip int) Option {
+	return func(c *client) {
+		c.caller = true
+		c.callerSkip = skip
+	}
+}
+
+// WithStackTrace captures the goroutine stack trace of logs with at least the given severity.
+func WithStackTrace(minSeverity models.Severity) Option {
+	return func(c *client) {
+		c.stackTraceSeverity = minSeverity
+	}
+}
+
+// WithErrorHandler is called when logs are dropped because they can't be delivered or were rejected.
+// By default, errors are written with the standard log package.
+func WithErrorHandler(handler func(err error)) Option {
+	return func(c *client) {
+		c.errorHandler = handler
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *client) {
+		c.httpClient = httpClient
+	}
+}
+
+type client struct {
+	url                string
+	headers            http.Header
+	batchSize          int
+	flushInterval      time.Duration
+	maxBufferSize      int
+	maxRetries         int
+	backoff            time.Duration
+	spoolDir           string
+	maxSpoolSize       int64
+	shutdownTimeout    time.Duration
+	caller             bool
+	callerSkip         int
+	stackTraceSeverity models.Severity
+	errorHandler       func(err error)
+	httpClient         *http.Client
+
+	mu       sync.Mutex
+	buffer   [][]byte // encoded entries
+	closed   bool
+	deadline time.Time // of sending the remaining logs once closed
+
+	// sendMu keeps batches in order
+	sendMu      sync.Mutex
+	spool       *spool
+	flushSignal chan struct{}
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// Client is a logar.Logger that sends logs to a remote logar server.
+type Client struct {
+	c   *client
+	ctx context.Context
+}
+
+var _ logar.Logger = (*Client)(nil)
+
+// New creates a client sending logs to the ingest endpoint at url, such as "https://logs.example.com/api/ingest".
+// Logs are sent in the background, Close must be called to send the remaining ones.
+func New(url string, opts ...Option) (*Client, error) {
+	c := &client{
+		url:             url,
+		headers:         http.Header{},
+		batchSize:       100,
+		flushInterval:   2 * time.Second,
+		maxBufferSize:   10000,
+		maxRetries:      3,
+		backoff:         500 * time.Millisecond,
+		maxSpoolSize:    64 << 20,
+		shutdownTimeout: 10 * time.Second,
+		errorHandler: func(err error) {
+			log.Println(err)
+		},
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		flushSignal: make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.spoolDir != "" {
+		spool, err := openSpool(c.spoolDir, c.maxSpoolSize)
+		if err != nil {
+			return nil, err
+		}
+		c.spool = spool
+	}
+
+	c.wg.Add(1)
+	go c.run()
+
+	return &Client{c: c}, nil
+}
+
+// GetApp returns nil, the logs of a client are stored by the App of the remote server.
+func (l *Client) GetApp() logar.App {
+	return nil
+}
+
+// WithContext returns a logger that adds the values of a context prepared with logar.PrepareContext
+// to messages, and tags logs with the span active in ctx.
+func (l *Client) WithContext(ctx context.Context) logar.Logger {
+	return &Client{c: l.c, ctx: ctx}
+}
+
+func (l *Client) Print(model logar.Model, message any, category string, severity models.Severity) error {
+	return l.print(model, message, category, severity, "")
+}
+
+func (l *Client) print(model logar.Model, message any, category string, severity models.Severity, stackTrace string) error {
+	return l.write(logar.Entry{
+		Model:      model,
+		Message:    message,
+		Category:   category,
+		Severity:   severity,
+		StackTrace: stackTrace,
+	}, true)
+}
+
+func (l *Client) Log(model logar.Model, message any, category string) error {
+	return l.Print(model, message, category, models.Severity_Log)
+}
+
+func (l *Client) Info(model logar.Model, message any, category string) error {
+	return l.Print(model, message, category, models.Severity_Info)
+}
+
+func (l *Client) Warn(model logar.Model, message any, category string) error {
+	return l.Print(model, message, category, models.Severity_Warning)
+}
+
+func (l *Client) Error(model logar.Model, message any, category string) error {
+	return l.Print(model, message, category, models.Severity_Error)
+}
+
+func (l *Client) Fatal(model logar.Model, message any, category string) error {
+	return l.Print(model, message, category, models.Severity_Fatal)
+}
+
+func (l *Client) Trace(model logar.Model, message any, category string) error {
+	return l.Print(model, message, category, models.Severity_Trace)
+}
+
+func (l *Client) Write(entry logar.Entry) error {
+	return l.write(entry, false)
+}
+
+func (l *Client) NewTimer() *logar.Timer {
+	return logar.NewTimer(l)
+}
+
+// write encodes the entry and adds it to the buffer. If local is set, the caller is captured.
+func (l *Client) write(entry logar.Entry, local bool) error {
+	message := entry.Message
+	if values, ok := logar.GetContextValues(l.ctx); ok && len(values) > 0 {
+		merged := logar.Map{}
+		if m, isMap := message.(logar.Map); isMap {
+			for k, v := range m {
+				merged[k] = v
+			}
+		} else {
+			merged["message"] = message
+		}
+		for k, v := range values {
+			merged[k] = v
+		}
+		message = merged
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if span, ok := logar.SpanFromContext(l.ctx); ok && entry.TraceID == "" {
+		entry.TraceID = span.TraceID()
+		entry.SpanID = span.SpanID()
+	}
+	if local && l.c.caller {
+		entry.Caller, entry.Function = logar.Caller(l.c.callerSkip)
+	}
+	if local && entry.StackTrace == "" && l.c.stackTraceSeverity != models.Severity_None && entry.Severity >= l.c.stackTraceSeverity {
+		entry.StackTrace = stackTrace()
+	}
+
+	encoded, err := json.Marshal(wireEntry{
+		Model:      string(entry.Model),
+		Category:   entry.Category,
+		Severity:   entry.Severity,
+		Message:    message,
+		Timestamp:  entry.Time.Format(time.RFC3339Nano),
+		TraceID:    entry.TraceID,
+		SpanID:     entry.SpanID,
+		Caller:     entry.Caller,
+		Function:   entry.Function,
+		StackTrace: entry.StackTrace,
+	})
+	if err != nil {
+		return fmt.Errorf("remotelogger: encoding message: %w", err)
+	}
+
+	return l.c.enqueue(encoded)
+}
+
+// wireEntry is the api.IngestEntry sent for a log.
+type wireEntry struct {
+	Model      string          `json:"model"`
+	Category   string          `json:"category,omitempty"`
+	Severity   models.Severity `json:"severity,omitempty"`
+	Message    any             `json:"message"`
+	Timestamp  string          `json:"timestamp"`
+	TraceID    string          `json:"trace_id,omitempty"`
+	SpanID     string          `json:"span_id,omitempty"`
+	Caller     string          `json:"caller,omitempty"`
+	Function   string          `json:"function,omitempty"`
+	StackTrace string          `json:"stack_trace,omitempty"`
+}
+
+// wireResult is the api.IngestResult of a request, decoded here so that the client doesn't import the api.
+type wireResult struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Errors   []struct {
+		Error string `json:"error"`
+	} `json:"errors"`
+}
+
+// Flush sends the buffered and spooled logs and waits for the requests to finish.
+func (l *Client) Flush(ctx context.Context) error {
+	return l.c.flush(ctx)
+}
+
+// Close stops the background worker and sends the remaining logs. Logs that can't be sent
+// before the shutdown timeout are written to the spool, if there is one.
+func (l *Client) Close() error {
+	c := l.c
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.deadline = time.Now().Add(c.shutdownTimeout)
+	c.mu.Unlock()
+
+	close(c.done)
+	c.wg.Wait()
+
+	ctx, cancel := context.WithDeadline(context.Background(), c.deadline)
+	defer cancel()
+	errs := []error{c.flush(ctx)}
+
+	// Logs still buffered when the deadline passed
+	if batch := c.take(); len(batch) > 0 {
+		err := fmt.Errorf("remotelogger: logs left after the shutdown timeout: %w", ctx.Err())
+		errs = append(errs, err)
+		for ; len(batch) > 0; batch = c.take() {
+			errs = append(errs, c.keep(batch, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *client) enqueue(encoded []byte) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if len(c.buffer) >= c.maxBufferSize {
+		c.mu.Unlock()
+		return ErrBufferFull
+	}
+	c.buffer = append(c.buffer, encoded)
+	full := len(c.buffer) >= c.batchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (c *client) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// A flush in flight when closing may finish until the shutdown deadline, Close sends the rest
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return
+		}
+		c.mu.Lock()
+		deadline := c.deadline
+		c.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		case <-c.flushSignal:
+		}
+		c.flush(ctx)
+	}
+}
+
+// flush sends the spooled batches, oldest first, then the buffer. Once a batch can't be delivered,
+// the remaining batches are spooled without trying to send them. If ctx is cancelled, the logs that
+// weren't sent stay buffered.
+func (c *client) flush(ctx context.Context) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	unreachable := false
+	var errs []error
+
+	if c.spool != nil {
+		for _, name := range c.spool.batches() {
+			lines, err := c.spool.read(name)
+			if err != nil {
+				errs = append(errs, c.report(err))
+				c.spool.remove(name)
+				continue
+			}
+			undelivered, err := c.send(ctx, lines)
+			if len(undelivered) > 0 {
+				// Only the logs that weren't delivered are sent again
+				if len(undelivered) < len(lines) {
+					if err := c.spool.replace(name, undelivered); err != nil {
+						errs = append(errs, c.report(fmt.Errorf("remotelogger: rewriting spooled batch: %w", err)))
+					}
+				}
+				unreachable = true
+				errs = append(errs, err)
+				break
+			}
+			if err != nil {
+				errs = append(errs, c.report(err))
+			}
+			c.spool.remove(name)
+		}
+	}
+	if ctx.Err() != nil {
+		return errors.Join(errs...)
+	}
+
+	for {
+		batch := c.take()
+		if len(batch) == 0 {
+			break
+		}
+		if unreachable {
+			errs = append(errs, c.keep(batch, errUnreachable))
+			continue
+		}
+
+		undelivered, err := c.send(ctx, batch)
+		if err == nil {
+			continue
+		}
+		if len(undelivered) == 0 {
+			errs = append(errs, c.report(err))
+			continue
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			// Not the server's fault, the logs are sent by the next flush or Close
+			c.requeue(undelivered)
+			break
+		}
+		unreachable = true
+		errs = append(errs, c.keep(undelivered, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// take removes the next batch from the buffer.
+func (c *client) take() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := min(len(c.buffer), c.batchSize)
+	batch := c.buffer[:n:n]
+	c.buffer = c.buffer[n:]
+	return batch
+}
+
+// requeue puts the logs of a batch back in front of the buffer.
+func (c *client) requeue(batch [][]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buffer = slices.Concat(batch, c.buffer)
+}
+
+// keep writes a batch that couldn't be delivered to the spool, or drops it if there is none.
+func (c *client) keep(batch [][]byte, err error) error {
+	if c.spool == nil {
+		return c.report(fmt.Errorf("remotelogger: dropped %d logs: %w", len(batch), err))
+	}
+	if err := c.spool.write(batch); err != nil {
+		return c.report(fmt.Errorf("remotelogger: dropped %d logs: %w", len(batch), err))
+	}
+	return nil
+}
+
+func (c *client) report(err error) error {
+	if c.errorHandler != nil {
+		c.errorHandler(err)
+	}
+	return err
+}
+
+var errUnreachable = &retryableError{errors.New("remotelogger: server is unreachable")}
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+func isRetryable(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable)
+}
+
+// send posts the batch, retrying with backoff, and returns the logs that may be delivered by sending
+// them again. Batches that are too large for the server are split, and only the parts that failed
+// are returned, so that the delivered ones aren't sent twice.
+func (c *client) send(ctx context.Context, batch [][]byte) ([][]byte, error) {
+	backoff := c.backoff
+	var err error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return batch, &retryableError{ctx.Err()}
+			}
+			backoff *= 2
+		}
+
+		var status int
+		status, err = c.post(ctx, batch)
+		if status == http.StatusRequestEntityTooLarge && len(batch) > 1 {
+			half := len(batch) / 2
+			first, err1 := c.send(ctx, batch[:half])
+			second, err2 := c.send(ctx, batch[half:])
+			return slices.Concat(first, second), errors.Join(err1, err2)
+		}
+		if !isRetryable(err) {
+			return nil, err
+		}
+	}
+	return batch, err
+}
+
+func (c *client) post(ctx context.Context, batch [][]byte) (int, error) {
+	body := bytes.Join(batch, []byte("\n"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, &retryableError{err}
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return resp.StatusCode, nil
+	case resp.StatusCode == http.StatusMultiStatus || resp.StatusCode == http.StatusUnprocessableEntity:
+		var response struct {
+			Data wireResult `json:"data"`
+		}
+		if json.Unmarshal(respBody, &response) == nil && response.Data.Rejected > 0 {
+			result := response.Data
+			err := fmt.Errorf("remotelogger: server rejected %d of %d logs", result.Rejected, result.Accepted+result.Rejected)
+			if len(result.Errors) > 0 {
+				err = fmt.Errorf("%w, first error: %s", err, result.Errors[0].Error)
+			}
+			return resp.StatusCode, err
+		}
+		if resp.StatusCode == http.StatusMultiStatus {
+			return resp.StatusCode, nil
+		}
+	}
+
+	err = fmt.Errorf("remotelogger: server responded with %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return resp.StatusCode, &retryableError{err}
+	}
+	return resp.StatusCode, err
+}
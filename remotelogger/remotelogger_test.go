@@ -0,0 +1,245 @@
+package remotelogger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server is an ingest endpoint recording the messages it accepts. respond returns the status of a
+// request from its messages, 200 by default.
+type server struct {
+	t        *testing.T
+	mu       sync.Mutex
+	accepted []string
+	respond  func(messages []string) int
+}
+
+func newServer(t *testing.T, respond func(messages []string) int) (*server, string) {
+	t.Helper()
+	s := &server{t: t, respond: respond}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts.URL
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var messages []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var entry wireEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			s.t.Errorf("invalid entry %s: %v", scanner.Text(), err)
+		}
+		message, _ := entry.Message.(string)
+		messages = append(messages, message)
+	}
+
+	status := http.StatusOK
+	if s.respond != nil {
+		status = s.respond(messages)
+	}
+	if status == http.StatusOK {
+		s.mu.Lock()
+		s.accepted = append(s.accepted, messages...)
+		s.mu.Unlock()
+	}
+	w.WriteHeader(status)
+}
+
+// counts returns how many times every message was accepted.
+func (s *server) counts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int{}
+	for _, message := range s.accepted {
+		counts[message]++
+	}
+	return counts
+}
+
+func write(t *testing.T, client *Client, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if err := client.Info("app", message, "test"); err != nil {
+			t.Fatalf("Info(%q) error = %v", message, err)
+		}
+	}
+}
+
+func messages(count int) []string {
+	result := make([]string, count)
+	for i := range result {
+		result[i] = "log " + strconv.Itoa(i)
+	}
+	return result
+}
+
+func TestCloseDuringFlush(t *testing.T) {
+	srv, url := newServer(t, func(messages []string) int {
+		time.Sleep(300 * time.Millisecond)
+		return http.StatusOK
+	})
+	client, err := New(url, WithBatchSize(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := messages(50)
+	write(t, client, written...)
+	// The background flush of the first batch is in flight
+	time.Sleep(50 * time.Millisecond)
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	counts := srv.counts()
+	for _, message := range written {
+		if counts[message] != 1 {
+			t.Errorf("%q was received %d times, want 1", message, counts[message])
+		}
+	}
+}
+
+func TestCloseAfterDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	_, url := newServer(t, func(messages []string) int {
+		<-release
+		return http.StatusOK
+	})
+	dir := t.TempDir()
+	client, err := New(url, WithBatchSize(10), WithSpool(dir), WithShutdownTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, client, messages(30)...)
+	time.Sleep(50 * time.Millisecond)
+	if err := client.Close(); err == nil {
+		t.Error("Close() error = nil, want the error of the unfinished request")
+	}
+
+	// Logs that couldn't be sent before the deadline are spooled instead of dropped
+	s := &spool{dir: dir}
+	spooled := 0
+	for _, name := range s.batches() {
+		lines, err := s.read(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spooled += len(lines)
+	}
+	if spooled != 30 {
+		t.Errorf("spooled %d logs, want 30", spooled)
+	}
+}
+
+func TestSplitTooLarge(t *testing.T) {
+	down := true
+	var mu sync.Mutex
+	srv, url := newServer(t, func(messages []string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case len(messages) > 2:
+			return http.StatusRequestEntityTooLarge
+		case down && messages[0] == "log 2":
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	dir := t.TempDir()
+	client, err := New(url, WithFlushInterval(time.Hour), WithRetry(0, time.Millisecond), WithSpool(dir), WithErrorHandler(func(error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	written := messages(4)
+	write(t, client, written...)
+	if err := client.Flush(context.Background()); err == nil {
+		t.Fatal("Flush() error = nil, want the error of the unavailable server")
+	}
+
+	// Only the half that wasn't accepted is spooled
+	s := &spool{dir: dir}
+	names := s.batches()
+	if len(names) != 1 {
+		t.Fatalf("got %d spooled batches, want 1", len(names))
+	}
+	if lines, _ := s.read(names[0]); len(lines) != 2 {
+		t.Errorf("spooled %d logs, want the 2 that weren't accepted", len(lines))
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	if err := client.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	counts := srv.counts()
+	for _, message := range written {
+		if counts[message] != 1 {
+			t.Errorf("%q was received %d times, want 1", message, counts[message])
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spool has %d files left, want none", len(entries))
+	}
+}
+
+func TestSplitSpooledBatch(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch [][]byte
+	for _, message := range messages(4) {
+		line, _ := json.Marshal(wireEntry{Model: "app", Message: message})
+		batch = append(batch, line)
+	}
+	if err := s.write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, url := newServer(t, func(messages []string) int {
+		switch {
+		case len(messages) > 2:
+			return http.StatusRequestEntityTooLarge
+		case messages[0] == "log 2":
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	client, err := New(url, WithFlushInterval(time.Hour), WithRetry(0, time.Millisecond), WithSpool(dir), WithErrorHandler(func(error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Flush(context.Background()); err == nil {
+		t.Fatal("Flush() error = nil, want the error of the unavailable server")
+	}
+
+	// The spooled batch keeps only the logs that weren't accepted
+	names := s.batches()
+	if len(names) != 1 {
+		t.Fatalf("got %d spooled batches, want 1", len(names))
+	}
+	if lines, _ := s.read(names[0]); len(lines) != 2 {
+		t.Errorf("spooled batch has %d logs, want 2", len(lines))
+	}
+	if counts := srv.counts(); counts["log 0"] != 1 || counts["log 1"] != 1 || len(counts) != 2 {
+		t.Errorf("received %v, want the first half once", counts)
+	}
+}
@@ -0,0 +1,121 @@
+package remotelogger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolExtension = ".ndjson"
+
+// spool stores undelivered batches as files of newline delimited entries, named so that
+// sorting them by name gives the order they were written in.
+type spool struct {
+	dir     string
+	maxSize int64
+
+	mu  sync.Mutex
+	seq int
+}
+
+func openSpool(dir string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("remotelogger: creating spool directory: %w", err)
+	}
+
+	// Remove batches that were being written when the process stopped
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	for _, name := range tmp {
+		os.Remove(name)
+	}
+
+	return &spool{dir: dir, maxSize: maxSize}, nil
+}
+
+// batches returns the names of the spooled batches, oldest first.
+func (s *spool) batches() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spoolExtension) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *spool) read(name string) ([][]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("remotelogger: reading spooled batch: %w", err)
+	}
+
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func (s *spool) remove(name string) {
+	os.Remove(filepath.Join(s.dir, name))
+}
+
+// write stores the batch, removing the oldest batches if the spool would grow beyond its maximum size.
+func (s *spool) write(batch [][]byte) error {
+	data := append(bytes.Join(batch, []byte("\n")), '\n')
+	if int64(len(data)) > s.maxSize {
+		return fmt.Errorf("batch of %d bytes is larger than the spool", len(data))
+	}
+
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq%1000000, spoolExtension)
+	s.mu.Unlock()
+
+	s.makeRoom(int64(len(data)))
+	return writeAtomic(filepath.Join(s.dir, name), data)
+}
+
+// replace rewrites a spooled batch with the part of it that is left to send, keeping its place in the order.
+func (s *spool) replace(name string, batch [][]byte) error {
+	return writeAtomic(filepath.Join(s.dir, name), append(bytes.Join(batch, []byte("\n")), '\n'))
+}
+
+// writeAtomic writes the file through a temporary file, so that a batch is never read half written.
+func writeAtomic(path string, data []byte) error {
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *spool) makeRoom(size int64) {
+	names := s.batches()
+	sizes := make([]int64, len(names))
+	total := size
+	for i, name := range names {
+		if info, err := os.Stat(filepath.Join(s.dir, name)); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+
+	for i := 0; total > s.maxSize && i < len(names); i++ {
+		s.remove(names[i])
+		total -= sizes[i]
+	}
+}
@@ -0,0 +1,126 @@
+package logar
+
+import (
+	"time"
+
+	"sadk.dev/logar/models"
+)
+
+// Category of the LogarLogs entries reporting deleted logs.
+const retentionCategory = "retention"
+
+// RetentionConfig controls how long logs and spans are kept.
+type RetentionConfig struct {
+	MaxAge time.Duration           // logs of models without their own max age, and spans. Zero keeps them forever
+	Models map[Model]time.Duration // per model max age, zero keeps the logs of the model forever
+	// Interval between deletions, defaults to an hour.
+	Interval time.Duration
+}
+
+func (c RetentionConfig) enabled() bool {
+	if c.MaxAge > 0 {
+		return true
+	}
+	for _, maxAge := range c.Models {
+		if maxAge > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRetention deletes logs and spans older than maxAge.
+func WithRetention(maxAge time.Duration) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.RetentionConfig.MaxAge = maxAge
+	}
+}
+
+// WithModelRetention deletes logs of the model older than maxAge, overriding WithRetention.
+// A zero maxAge keeps them forever.
+func WithModelRetention(model Model, maxAge time.Duration) ConfigOpt {
+	return func(cfg *Config) {
+		if cfg.RetentionConfig.Models == nil {
+			cfg.RetentionConfig.Models = map[Model]time.Duration{}
+		}
+		cfg.RetentionConfig.Models[model] = maxAge
+	}
+}
+
+// WithRetentionInterval sets how often old logs and spans are deleted. Defaults to an hour.
+func WithRetentionInterval(interval time.Duration) ConfigOpt {
+	return func(cfg *Config) {
+		cfg.RetentionConfig.Interval = interval
+	}
+}
+
+func (l *AppImpl) runRetention() {
+	interval := l.config.RetentionConfig.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	l.runWorker(func(done <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			l.applyRetention(time.Now())
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	})
+}
+
+// applyRetention deletes the expired logs and spans and reports how many were deleted.
+func (l *AppImpl) applyRetention(now time.Time) {
+	cfg := l.config.RetentionConfig
+	var deleted int64
+
+	overridden := make([]string, 0, len(cfg.Models))
+	for model, maxAge := range cfg.Models {
+		overridden = append(overridden, string(model))
+		if maxAge <= 0 {
+			continue
+		}
+		result := l.db.Where("model = ? AND created_at < ?", model, now.Add(-maxAge)).Delete(&models.Log{})
+		if result.Error != nil {
+			l.logger.Error(LogarLogs, "Failed to apply retention to model "+string(model)+": "+result.Error.Error(), retentionCategory)
+			continue
+		}
+		deleted += result.RowsAffected
+	}
+
+	spans := int64(0)
+	if cfg.MaxAge > 0 {
+		query := l.db.Where("created_at < ?", now.Add(-cfg.MaxAge))
+		if len(overridden) > 0 {
+			query = query.Where("model NOT IN ?", overridden)
+		}
+		result := query.Delete(&models.Log{})
+		if result.Error != nil {
+			l.logger.Error(LogarLogs, "Failed to apply retention: "+result.Error.Error(), retentionCategory)
+		} else {
+			deleted += result.RowsAffected
+		}
+
+		result = l.db.Where("end_time < ?", now.Add(-cfg.MaxAge)).Delete(&models.Span{})
+		if result.Error != nil {
+			l.logger.Error(LogarLogs, "Failed to apply retention to spans: "+result.Error.Error(), retentionCategory)
+		} else {
+			spans = result.RowsAffected
+		}
+	}
+
+	if deleted > 0 || spans > 0 {
+		l.logger.Info(LogarLogs, Map{
+			"message":       "Deleted expired logs and spans",
+			"deleted_logs":  deleted,
+			"deleted_spans": spans,
+		}, retentionCategory)
+	}
+}
@@ -0,0 +1,82 @@
+package logar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"sadk.dev/logar/logfilter"
+	"sadk.dev/logar/models"
+)
+
+// Category of the LogarLogs entries summarizing suppressed logs.
+const samplingCategory = "sampling"
+
+// sampler drops logs rejected by the sampling filter and keeps count of them for the periodic summary.
+type sampler struct {
+	filter logfilter.Filter
+
+	mu         sync.Mutex
+	suppressed map[string]int64 // "model/category" -> count
+}
+
+func newSampler(filter logfilter.Filter) *sampler {
+	return &sampler{
+		filter:     filter,
+		suppressed: map[string]int64{},
+	}
+}
+
+func (s *sampler) allow(log models.Log) bool {
+	if log.Model == models.Model(LogarLogs) && log.Category == samplingCategory {
+		return true
+	}
+
+	if s.filter.Evaluate(log) {
+		return true
+	}
+
+	s.mu.Lock()
+	s.suppressed[string(log.Model)+"/"+log.Category]++
+	s.mu.Unlock()
+	return false
+}
+
+// reset returns the suppressed counts since the last reset.
+func (s *sampler) reset() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	suppressed := s.suppressed
+	s.suppressed = map[string]int64{}
+	return suppressed
+}
+
+func (l *AppImpl) runSamplingSummary(interval time.Duration) {
+	l.runWorker(func(done <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				suppressed := l.sampler.reset()
+				if len(suppressed) == 0 {
+					continue
+				}
+
+				total := int64(0)
+				for _, count := range suppressed {
+					total += count
+				}
+
+				l.logger.Info(LogarLogs, Map{
+					"message":    fmt.Sprintf("Suppressed %d logs in the last %s", total, interval),
+					"suppressed": suppressed,
+				}, samplingCategory)
+			case <-done:
+				return
+			}
+		}
+	})
+}
@@ -1,24 +1,59 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"gorm.io/driver/sqlite"
 	"sadk.dev/logar"
 	logarweb "sadk.dev/logar-web"
-	"sadk.dev/logar/logfilter"
 	"sadk.dev/logar/proxy"
-	"sadk.dev/logar/proxy/consolelogger"
+	"sadk.dev/logar/syslogreceiver"
+
+	// Proxy targets available to the proxies of the config file
+	_ "sadk.dev/logar/proxy/consolelogger"
+	_ "sadk.dev/logar/proxy/discordlogger"
+	_ "sadk.dev/logar/proxy/elasticlogger"
+	_ "sadk.dev/logar/proxy/emaillogger"
+	_ "sadk.dev/logar/proxy/filelogger"
+	_ "sadk.dev/logar/proxy/lokilogger"
+	_ "sadk.dev/logar/proxy/otlplogger"
+	_ "sadk.dev/logar/proxy/slacklogger"
+	_ "sadk.dev/logar/proxy/webhooklogger"
+	_ "sadk.dev/logar/telegrambot"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// Config is the config file of the standalone server, the keys of logar.ConfigFile and of the
+// server and its receivers. Flags that are set override it.
+type Config struct {
+	logar.ConfigFile
+
+	Server struct {
+		Addr     string `json:"addr"`
+		APIURL   string `json:"api_url"`
+		BasePath string `json:"base_path"`
+	} `json:"server"`
+
+	Syslog struct {
+		UDP     string `json:"udp"`
+		TCP     string `json:"tcp"`
+		TLS     string `json:"tls"`
+		TLSCert string `json:"tls_cert"`
+		TLSKey  string `json:"tls_key"`
+	} `json:"syslog"`
+}
+
 var (
+	configPath    = flag.String("config", "", "Path of a YAML, JSON or TOML config file, defaults to $LOGAR_CONFIG")
 	appName       = flag.String("app-name", "minimal", "Application name")
 	adminUsername = flag.String("admin-username", "admin", "Admin username")
 	adminPassword = flag.String("admin-password", "admin", "Admin password")
@@ -26,38 +61,137 @@ var (
 	serverAddr    = flag.String("server-addr", ":3000", "Server address")
 	apiURL        = flag.String("api-url", "http://localhost:3000", "API URL for web client")
 	basePath      = flag.String("base-path", "", "Base path for web client")
+
+	ingestEnabled = flag.Bool("ingest", true, "Enable the HTTP ingest endpoint")
+	otlpEnabled   = flag.Bool("otlp", true, "Enable the OTLP/HTTP receivers")
+	syslogUDP     = flag.String("syslog-udp", "", "Address of the syslog UDP listener, e.g. :514")
+	syslogTCP     = flag.String("syslog-tcp", "", "Address of the syslog TCP listener, e.g. :601")
+	syslogTLS     = flag.String("syslog-tls", "", "Address of the syslog TLS listener, e.g. :6514")
+	syslogTLSCert = flag.String("syslog-tls-cert", "", "Certificate file of the syslog TLS listener")
+	syslogTLSKey  = flag.String("syslog-tls-key", "", "Key file of the syslog TLS listener")
+	retention     = flag.Duration("retention", 0, "Delete logs older than this, e.g. 720h. Zero keeps logs forever")
 )
 
+func loadConfig() (Config, error) {
+	cfg := Config{}
+	cfg.AppName = *appName
+	cfg.Admin.Username = *adminUsername
+	cfg.Admin.Password = *adminPassword
+	cfg.Database.DSN = *dbPath
+	cfg.Server.Addr = *serverAddr
+	cfg.Server.APIURL = *apiURL
+	cfg.Server.BasePath = *basePath
+
+	// The config file and LOGAR_* environment variables override the defaults of the flags
+	if err := logar.LoadConfigFile(*configPath, &cfg); err != nil {
+		return cfg, err
+	}
+
+	// Flags given on the command line take precedence over both
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "app-name":
+			cfg.AppName = *appName
+		case "admin-username":
+			cfg.Admin.Username = *adminUsername
+		case "admin-password":
+			cfg.Admin.Password = *adminPassword
+		case "db-path":
+			cfg.Database.DSN = *dbPath
+		case "server-addr":
+			cfg.Server.Addr = *serverAddr
+		case "api-url":
+			cfg.Server.APIURL = *apiURL
+		case "base-path":
+			cfg.Server.BasePath = *basePath
+		case "ingest":
+			cfg.Ingest.Enabled = ingestEnabled
+		case "otlp":
+			cfg.OTLP.Enabled = otlpEnabled
+		case "syslog-udp":
+			cfg.Syslog.UDP = *syslogUDP
+		case "syslog-tcp":
+			cfg.Syslog.TCP = *syslogTCP
+		case "syslog-tls":
+			cfg.Syslog.TLS = *syslogTLS
+		case "syslog-tls-cert":
+			cfg.Syslog.TLSCert = *syslogTLSCert
+		case "syslog-tls-key":
+			cfg.Syslog.TLSKey = *syslogTLSKey
+		case "retention":
+			cfg.Retention.MaxAge = *retention
+		}
+	})
+
+	if len(cfg.Models) == 0 {
+		cfg.Models = []logar.ModelDefinition{
+			{ID: "user-trace", Name: "User Trace", Icon: "fa-solid fa-users"},
+			{ID: "logs", Name: "Logs", Icon: "fa-solid fa-file-lines"},
+		}
+	}
+	if len(cfg.Proxies) == 0 {
+		cfg.Proxies = []proxy.Definition{{Type: "console"}}
+	}
+
+	return cfg, nil
+}
+
 func main() {
 	flag.Parse()
 
-	app, err := logar.New(
-		logar.WithAppName(*appName),
-		logar.WithAdminCredentials(*adminUsername, *adminPassword),
-		logar.WithDatabase(sqlite.Open(*dbPath)),
-
-		logar.AddModel("User Trace", "user-trace", "fa-solid fa-users"),
-		logar.AddModel("Logs", "logs", "fa-solid fa-file-lines"),
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		logar.AddProxy(proxy.NewProxy(
-			consolelogger.New(),
-			logfilter.NewFilter(),
-		)),
+	opts, err := cfg.Options()
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts = append(opts, logar.WithAction("Server/Time", "Get current time", func() string {
+		return time.Now().Format(time.RFC3339)
+	}))
 
-		logar.WithAction("Server/Time", "Get current time", func() string {
-			return time.Now().Format(time.RFC3339)
-		}),
-	)
+	app, err := logar.New(opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer app.Close()
+
+	var syslogOpts []syslogreceiver.Option
+	if cfg.Syslog.UDP != "" {
+		syslogOpts = append(syslogOpts, syslogreceiver.WithUDP(cfg.Syslog.UDP))
+	}
+	if cfg.Syslog.TCP != "" {
+		syslogOpts = append(syslogOpts, syslogreceiver.WithTCP(cfg.Syslog.TCP))
+	}
+	if cfg.Syslog.TLS != "" {
+		syslogOpts = append(syslogOpts, syslogreceiver.WithTLS(cfg.Syslog.TLS, cfg.Syslog.TLSCert, cfg.Syslog.TLSKey))
+	}
+	if len(syslogOpts) > 0 {
+		receiver := syslogreceiver.New(app, syslogOpts...)
+		if err := receiver.Start(); err != nil {
+			log.Fatal(err)
+		}
+		defer receiver.Close()
+	}
 
 	e := echo.New()
 	e.Use(middleware.CORS())
 	e.Use(middleware.Recover())
 
+	e.GET("/healthz", func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+		defer cancel()
+
+		if err := app.Ping(ctx); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]any{"status": "ok"})
+	})
+
 	e.GET("/manifest.json", func(c echo.Context) error {
-		base := *basePath
+		base := cfg.Server.BasePath
 		if base == "" {
 			base = "/"
 		}
@@ -92,10 +226,21 @@ func main() {
 		}
 		return c.JSON(200, manifest)
 	})
-	e.Any("*", echo.WrapHandler(logarweb.ServeHTTP(*apiURL, *basePath, app)))
+	e.Any("*", echo.WrapHandler(logarweb.ServeHTTP(cfg.Server.APIURL, cfg.Server.BasePath, app)))
 
-	err = e.Start(*serverAddr)
-	if err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
-	}
+	go func() {
+		err := e.Start(cfg.Server.Addr)
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// Stop on SIGINT/SIGTERM so the receivers and the database are closed cleanly
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	e.Shutdown(ctx)
 }
@@ -0,0 +1,283 @@
+package syslogreceiver
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"sadk.dev/logar/models"
+)
+
+type Format int
+
+const (
+	Format_RFC3164 Format = iota
+	Format_RFC5424
+)
+
+// Message is a parsed syslog message.
+type Message struct {
+	Format    Format
+	Facility  int
+	Severity  int // syslog severity, 0 (emergency) to 7 (debug)
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+
+	// StructuredData maps SD-IDs to their parameters, RFC 5424 only
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+const nilValue = "-"
+
+// Default priority of messages without one, user.notice as in RFC 3164
+const defaultPriority = 13
+
+var errInvalidPriority = errors.New("invalid priority")
+
+var facilityNames = [...]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// FacilityName returns the keyword of a facility, such as "daemon" or "local0".
+func FacilityName(facility int) string {
+	if facility < 0 || facility >= len(facilityNames) {
+		return strconv.Itoa(facility)
+	}
+	return facilityNames[facility]
+}
+
+// LogSeverity maps a syslog severity to a logar severity.
+func LogSeverity(severity int) models.Severity {
+	switch {
+	case severity <= 2: // emergency, alert, critical
+		return models.Severity_Fatal
+	case severity == 3:
+		return models.Severity_Error
+	case severity == 4:
+		return models.Severity_Warning
+	case severity <= 6: // notice, informational
+		return models.Severity_Info
+	}
+	return models.Severity_Log
+}
+
+// Parse parses an RFC 5424 or RFC 3164 message. Messages that don't follow either format
+// are returned whole as the message text, with the default priority.
+func Parse(data []byte) Message {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	priority, rest, err := parsePriority(string(data))
+	if err != nil {
+		priority, rest = defaultPriority, string(data)
+	}
+
+	msg := Message{
+		Facility: priority / 8,
+		Severity: priority % 8,
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		if parseRFC5424(&msg, rest[2:]) {
+			return msg
+		}
+		msg = Message{Facility: priority / 8, Severity: priority % 8}
+	}
+
+	parseRFC3164(&msg, rest)
+	return msg
+}
+
+func parsePriority(s string) (int, string, error) {
+	if len(s) < 3 || s[0] != '<' {
+		return 0, s, errInvalidPriority
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return 0, s, errInvalidPriority
+	}
+	priority, err := strconv.Atoi(s[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, s, errInvalidPriority
+	}
+	return priority, s[end+1:], nil
+}
+
+// parseRFC5424 parses everything after the version. It returns false if the header is malformed.
+func parseRFC5424(msg *Message, s string) bool {
+	msg.Format = Format_RFC5424
+
+	fields := make([]string, 5)
+	for i := range fields {
+		var ok bool
+		fields[i], s, ok = strings.Cut(s, " ")
+		if !ok && i < len(fields)-1 {
+			return false
+		}
+	}
+
+	if fields[0] != nilValue {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return false
+		}
+		msg.Timestamp = timestamp
+	}
+	msg.Hostname = nilToEmpty(fields[1])
+	msg.AppName = nilToEmpty(fields[2])
+	msg.ProcID = nilToEmpty(fields[3])
+	msg.MsgID = nilToEmpty(fields[4])
+
+	if strings.HasPrefix(s, nilValue) {
+		s = s[1:]
+	} else if strings.HasPrefix(s, "[") {
+		var ok bool
+		msg.StructuredData, s, ok = parseStructuredData(s)
+		if !ok {
+			return false
+		}
+	} else if s != "" {
+		return false
+	}
+
+	s = strings.TrimPrefix(s, " ")
+	msg.Message = strings.TrimPrefix(s, "\ufeff") // UTF-8 BOM
+	return true
+}
+
+// parseStructuredData parses SD-ELEMENTs such as [id key="value"][id2 key="a \"quoted\" value"].
+func parseStructuredData(s string) (map[string]map[string]string, string, bool) {
+	data := map[string]map[string]string{}
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, s, false
+		}
+		id := s[:end]
+		s = s[end:]
+		params := map[string]string{}
+
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			name, rest, ok := strings.Cut(s, "=\"")
+			if !ok || name == "" {
+				return nil, s, false
+			}
+			s = rest
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(s); i++ {
+				c := s[i]
+				if c == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+					value.WriteByte(s[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					s = s[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, s, false
+			}
+			params[name] = value.String()
+		}
+
+		if !strings.HasPrefix(s, "]") {
+			return nil, s, false
+		}
+		s = s[1:]
+		data[id] = params
+	}
+	return data, s, true
+}
+
+func nilToEmpty(s string) string {
+	if s == nilValue {
+		return ""
+	}
+	return s
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG", accepting the common variations
+// without hostname, with an RFC 3339 timestamp or without a tag.
+func parseRFC3164(msg *Message, s string) {
+	msg.Format = Format_RFC3164
+
+	if len(s) >= len(time.Stamp) {
+		if timestamp, err := time.ParseInLocation(time.Stamp, s[:len(time.Stamp)], time.Local); err == nil {
+			msg.Timestamp = withCurrentYear(timestamp)
+			s = strings.TrimPrefix(s[len(time.Stamp):], " ")
+		}
+	}
+	if msg.Timestamp.IsZero() {
+		if token, rest, ok := strings.Cut(s, " "); ok {
+			if timestamp, err := time.Parse(time.RFC3339Nano, token); err == nil {
+				msg.Timestamp = timestamp
+				s = rest
+			}
+		}
+	}
+
+	// The hostname is only present if the next token isn't already the tag
+	if token, rest, ok := strings.Cut(s, " "); ok && !msg.Timestamp.IsZero() && !isTag(token) {
+		msg.Hostname = token
+		s = rest
+	}
+
+	if token, rest, ok := strings.Cut(s, " "); ok && isTag(token) {
+		tag := strings.TrimSuffix(token, ":")
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			msg.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		msg.AppName = tag
+		s = rest
+	}
+
+	msg.Message = s
+}
+
+// isTag reports whether the token is a tag such as "sshd:" or "cron[123]:".
+func isTag(token string) bool {
+	if !strings.HasSuffix(token, ":") && !strings.HasSuffix(token, "]") {
+		return false
+	}
+	name := strings.TrimSuffix(token, ":")
+	if open := strings.IndexByte(name, '['); open >= 0 {
+		name = name[:open]
+	}
+	if name == "" || len(name) > 48 {
+		return false
+	}
+	for _, c := range name {
+		if !(unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-' || c == '_' || c == '.' || c == '/') {
+			return false
+		}
+	}
+	return true
+}
+
+// withCurrentYear sets the year of an RFC 3164 timestamp, which has none. Timestamps more
+// than a day in the future are from the end of the previous year.
+func withCurrentYear(timestamp time.Time) time.Time {
+	now := time.Now()
+	timestamp = timestamp.AddDate(now.Year()-timestamp.Year(), 0, 0)
+	if timestamp.After(now.Add(24 * time.Hour)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	return timestamp
+}
@@ -0,0 +1,417 @@
+// Package syslogreceiver accepts syslog messages over UDP, TCP and TLS and writes them as logar logs.
+package syslogreceiver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"sadk.dev/logar"
+)
+
+const logCategory = "syslog"
+
+const (
+	// maxOctetCountDigits is the number of digits of the longest octet count of TCP frames.
+	maxOctetCountDigits = 10
+	// maxFrameLength is the largest accepted octet count, unless the max message size is larger. The bytes
+	// over the max message size are discarded.
+	maxFrameLength = 16 << 20
+)
+
+// Mapping decides the model and category of a message.
+type Mapping func(msg Message) (model logar.Model, category string)
+
+// DefaultMapping uses the app name as the model, or "syslog" if there is none, and the facility as the category.
+func DefaultMapping(msg Message) (logar.Model, string) {
+	model := logar.Model(msg.AppName)
+	if model == "" {
+		model = "syslog"
+	}
+	return model, FacilityName(msg.Facility)
+}
+
+type Option func(*Receiver)
+
+func WithUDP(addr string) Option {
+	return func(r *Receiver) {
+		r.udpAddr = addr
+	}
+}
+
+func WithTCP(addr string) Option {
+	return func(r *Receiver) {
+		r.tcpAddr = addr
+	}
+}
+
+// WithTLS accepts syslog over TLS (RFC 5425) using the certificate and key files.
+func WithTLS(addr, certFile, keyFile string) Option {
+	return func(r *Receiver) {
+		r.tlsAddr = addr
+		r.certFile = certFile
+		r.keyFile = keyFile
+	}
+}
+
+// WithTLSConfig accepts syslog over TLS with a custom configuration, e.g. to require client certificates.
+func WithTLSConfig(addr string, config *tls.Config) Option {
+	return func(r *Receiver) {
+		r.tlsAddr = addr
+		r.tlsConfig = config
+	}
+}
+
+func WithMapping(mapping Mapping) Option {
+	return func(r *Receiver) {
+		r.mapping = mapping
+	}
+}
+
+// WithMaxMessageSize sets the size of the largest accepted message. Longer TCP messages are truncated.
+// Defaults to 64 KiB.
+func WithMaxMessageSize(size int) Option {
+	return func(r *Receiver) {
+		if size > 0 {
+			r.maxMessageSize = size
+		}
+	}
+}
+
+// WithIdleTimeout closes TCP connections that send nothing for the duration. Defaults to 5 minutes.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(r *Receiver) {
+		r.idleTimeout = timeout
+	}
+}
+
+type Receiver struct {
+	app logar.App
+
+	udpAddr   string
+	tcpAddr   string
+	tlsAddr   string
+	certFile  string
+	keyFile   string
+	tlsConfig *tls.Config
+
+	mapping        Mapping
+	maxMessageSize int
+	idleTimeout    time.Duration
+
+	mu        sync.Mutex
+	udpConn   net.PacketConn
+	listeners map[string]net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func New(app logar.App, opts ...Option) *Receiver {
+	r := &Receiver{
+		app:            app,
+		mapping:        DefaultMapping,
+		maxMessageSize: 64 << 10,
+		idleTimeout:    5 * time.Minute,
+		listeners:      map[string]net.Listener{},
+		conns:          map[net.Conn]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Start opens the configured listeners and serves them in the background until Close is called.
+func (r *Receiver) Start() error {
+	if r.udpAddr == "" && r.tcpAddr == "" && r.tlsAddr == "" {
+		return errors.New("syslogreceiver: no listener configured")
+	}
+
+	err := r.start()
+	if err != nil {
+		r.Close()
+	}
+	return err
+}
+
+func (r *Receiver) start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.udpAddr != "" {
+		conn, err := net.ListenPacket("udp", r.udpAddr)
+		if err != nil {
+			return err
+		}
+		r.udpConn = conn
+		r.wg.Add(1)
+		go r.serveUDP(conn)
+	}
+
+	if r.tcpAddr != "" {
+		listener, err := net.Listen("tcp", r.tcpAddr)
+		if err != nil {
+			return err
+		}
+		r.listeners["tcp"] = listener
+		r.wg.Add(1)
+		go r.serveTCP(listener)
+	}
+
+	if r.tlsAddr != "" {
+		config := r.tlsConfig
+		if config == nil {
+			cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+			if err != nil {
+				return fmt.Errorf("syslogreceiver: loading TLS certificate: %w", err)
+			}
+			config = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		}
+		listener, err := tls.Listen("tcp", r.tlsAddr, config)
+		if err != nil {
+			return err
+		}
+		r.listeners["tls"] = listener
+		r.wg.Add(1)
+		go r.serveTCP(listener)
+	}
+
+	return nil
+}
+
+// Addr returns the address of the "udp", "tcp" or "tls" listener, or nil if it isn't running.
+func (r *Receiver) Addr(network string) net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if network == "udp" {
+		if r.udpConn == nil {
+			return nil
+		}
+		return r.udpConn.LocalAddr()
+	}
+	if listener, ok := r.listeners[network]; ok {
+		return listener.Addr()
+	}
+	return nil
+}
+
+// Close stops the listeners, closes open connections and waits for pending messages to be written.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+
+	var errs []error
+	if r.udpConn != nil {
+		errs = append(errs, r.udpConn.Close())
+	}
+	for _, listener := range r.listeners {
+		errs = append(errs, listener.Close())
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (r *Receiver) serveUDP(conn net.PacketConn) {
+	defer r.wg.Done()
+
+	buf := make([]byte, r.maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.logError(fmt.Sprintf("Failed to read syslog datagram: %v", err))
+			continue
+		}
+		r.handle(buf[:n], addr)
+	}
+}
+
+func (r *Receiver) serveTCP(listener net.Listener) {
+	defer r.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.logError(fmt.Sprintf("Failed to accept syslog connection: %v", err))
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		r.conns[conn] = struct{}{}
+		r.wg.Add(1)
+		r.mu.Unlock()
+
+		go r.serveConn(conn)
+	}
+}
+
+// serveConn reads messages framed by octet counting (RFC 6587 3.4.1) or by newlines.
+func (r *Receiver) serveConn(conn net.Conn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReaderSize(conn, 4096)
+	for {
+		if r.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(r.idleTimeout))
+		}
+
+		frame, err := r.readFrame(reader)
+		if len(frame) > 0 {
+			r.handle(frame, conn.RemoteAddr())
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				var netErr net.Error
+				if !(errors.As(err, &netErr) && netErr.Timeout()) {
+					r.logError(fmt.Sprintf("Closing syslog connection from %s: %v", conn.RemoteAddr(), err))
+				}
+			}
+			return
+		}
+	}
+}
+
+func (r *Receiver) readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		length, err := r.readOctetCount(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		frame := make([]byte, min(length, r.maxMessageSize))
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		if length > len(frame) {
+			if _, err := reader.Discard(length - len(frame)); err != nil {
+				return frame, err
+			}
+		}
+		return frame, nil
+	}
+
+	var frame []byte
+	for {
+		line, err := reader.ReadSlice('\n')
+		if len(frame) < r.maxMessageSize {
+			frame = append(frame, line[:min(len(line), r.maxMessageSize-len(frame))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return frame, err
+	}
+}
+
+// readOctetCount reads the length of an octet counted frame and the space following it.
+func (r *Receiver) readOctetCount(reader *bufio.Reader) (int, error) {
+	maxLength := max(maxFrameLength, r.maxMessageSize)
+	length := 0
+	for digits := 0; ; digits++ {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == ' ' && digits > 0 {
+			return length, nil
+		}
+		if c < '0' || c > '9' || digits == maxOctetCountDigits {
+			return 0, fmt.Errorf("invalid octet count, unexpected %q", c)
+		}
+
+		length = length*10 + int(c-'0')
+		if length > maxLength {
+			return 0, fmt.Errorf("octet count over %d bytes", maxLength)
+		}
+	}
+}
+
+func (r *Receiver) handle(data []byte, addr net.Addr) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return
+	}
+
+	msg := Parse(data)
+	model, category := r.mapping(msg)
+
+	message := logar.Map{
+		"message":  msg.Message,
+		"facility": FacilityName(msg.Facility),
+	}
+	if msg.Hostname != "" {
+		message["hostname"] = msg.Hostname
+	} else if addr != nil {
+		message["hostname"] = hostOf(addr)
+	}
+	if msg.ProcID != "" {
+		message["proc_id"] = msg.ProcID
+	}
+	if msg.MsgID != "" {
+		message["msg_id"] = msg.MsgID
+	}
+	if len(msg.StructuredData) > 0 {
+		message["structured_data"] = msg.StructuredData
+	}
+
+	err := r.app.GetLogger().Write(logar.Entry{
+		Model:    model,
+		Message:  message,
+		Category: category,
+		Severity: LogSeverity(msg.Severity),
+		Time:     msg.Timestamp,
+	})
+	if err != nil {
+		r.logError(fmt.Sprintf("Failed to write syslog message: %v", err))
+	}
+}
+
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (r *Receiver) logError(message string) {
+	r.app.GetLogger().Error(logar.LogarLogs, message, logCategory)
+}
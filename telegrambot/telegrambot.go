@@ -6,6 +6,24 @@ import (
 	"sadk.dev/logar/proxy/telegramlogger"
 )
 
+func init() {
+	proxy.RegisterTarget("telegram", func(settings proxy.Settings) (proxy.ProxyTarget, error) {
+		var cfg struct {
+			Token  string `json:"token"`
+			ChatID int64  `json:"chat_id"`
+		}
+		if err := settings.Decode(&cfg); err != nil {
+			return nil, err
+		}
+
+		bot, err := New(cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		return bot.ProxyTo(cfg.ChatID), nil
+	}, "token")
+}
+
 type TelegramBot interface {
 	ProxyTo(chatId int64) proxy.ProxyTarget
 	Send(message string, chatId int64) error
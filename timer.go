@@ -7,13 +7,18 @@ import (
 
 type Timer struct {
 	start  time.Time
-	logger *AppImpl
+	logger Logger
 }
 
 func (l *AppImpl) NewTimer() *Timer {
+	return NewTimer(l.GetLogger())
+}
+
+// NewTimer starts a timer that logs with the given logger.
+func NewTimer(logger Logger) *Timer {
 	return &Timer{
 		start:  time.Now(),
-		logger: l,
+		logger: logger,
 	}
 }
 
@@ -22,7 +27,7 @@ func (t *Timer) Elapsed() time.Duration {
 }
 
 func (t *Timer) Log(model Model, message string, category string) error {
-	return t.logger.GetLogger().Log(
+	return t.logger.Log(
 		model,
 		fmt.Sprintf("\"%s\" took %s", message, t.Elapsed().String()),
 		category,
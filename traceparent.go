@@ -0,0 +1,204 @@
+package logar
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"sadk.dev/logar/models"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// SpanContext identifies a span across service boundaries, as carried by W3C Trace Context headers.
+type SpanContext struct {
+	TraceID    string
+	SpanID     string
+	TraceState string
+	Sampled    bool
+}
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a W3C traceparent header value, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, errInvalidTraceparent
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// Version 00 has exactly four fields, future versions may append more
+	if version == "ff" || !isHex(version, 2) || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if !isHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if !isHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	if !isHex(flags, 2) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+
+	flagBits, _ := hex.DecodeString(flags)
+	return SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: flagBits[0]&0x01 == 0x01,
+	}, nil
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Traceparent formats the span context as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Inject sets the traceparent and tracestate headers.
+func (sc SpanContext) Inject(header http.Header) {
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// ExtractSpanContext reads the span context from traceparent and tracestate headers.
+func ExtractSpanContext(header http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = strings.Join(header.Values(TracestateHeader), ",")
+	return sc, true
+}
+
+func (t *TracerImpl) ContextWithRemoteSpan(ctx context.Context, sc SpanContext) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{
+		tracer: t,
+		data: models.Span{
+			TraceID: sc.TraceID,
+			SpanID:  sc.SpanID,
+		},
+		traceState: sc.TraceState,
+		remote:     true,
+	}
+	return context.WithValue(ctx, spanContextKey, span)
+}
+
+func (t *TracerImpl) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := ExtractSpanContext(r.Header); ok {
+			ctx = t.ContextWithRemoteSpan(ctx, sc)
+		}
+		if _, ok := t.core.GetContextValues(ctx); !ok {
+			ctx = t.core.PrepareContext(ctx, nil)
+		}
+
+		ctx, span := t.StartSpan(ctx, r.Method+" "+r.URL.Path, Map{
+			"http.method":     r.Method,
+			"http.url":        r.URL.String(),
+			"http.host":       r.Host,
+			"http.user_agent": r.UserAgent(),
+			"net.peer.addr":   r.RemoteAddr,
+		})
+		span.SetKind(models.SpanKind_Server)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", recorder.status)
+		if recorder.status >= 500 {
+			span.SetStatus(models.SpanStatus_Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (t *TracerImpl) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{tracer: t, base: base}
+}
+
+type tracingTransport struct {
+	tracer *TracerImpl
+	base   http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.StartSpan(req.Context(), "HTTP "+req.Method+" "+req.URL.Host, Map{
+		"http.method": req.Method,
+		"http.url":    req.URL.String(),
+		"http.host":   req.URL.Host,
+	})
+	span.SetKind(models.SpanKind_Client)
+	defer span.End()
+
+	// RoundTrippers must not modify the original request
+	req = req.Clone(ctx)
+	span.SpanContext().Inject(req.Header)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 500 {
+		span.SetStatus(models.SpanStatus_Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
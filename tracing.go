@@ -0,0 +1,302 @@
+package logar
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"sadk.dev/logar/models"
+)
+
+type Tracer interface {
+	Common
+
+	// StartSpan starts a span as a child of the span active in ctx, or as the root of a new trace.
+	// The returned context carries the new span, logs written with it are tagged with its trace and span IDs.
+	StartSpan(ctx context.Context, name string, attributes ...Map) (context.Context, *Span)
+	SpanFromContext(ctx context.Context) (*Span, bool)
+
+	// ContextWithRemoteSpan returns a context whose active span is a span of another service,
+	// so spans started from it continue that trace.
+	ContextWithRemoteSpan(ctx context.Context, sc SpanContext) context.Context
+	// Middleware continues the trace of incoming traceparent/tracestate headers, or starts a new one,
+	// and records a server span for each request.
+	Middleware(next http.Handler) http.Handler
+	// Transport wraps base to record client spans and inject traceparent/tracestate headers into outgoing requests.
+	// If base is nil, http.DefaultTransport is used.
+	Transport(base http.RoundTripper) http.RoundTripper
+
+	// StoreSpans persists finished spans recorded elsewhere, such as ones received over OTLP.
+	// Spans that are already stored are skipped. They aren't passed to span exporters.
+	StoreSpans(spans ...models.Span) error
+
+	// GetTraces returns the root spans of the most recent traces. Spans continuing a trace of a service
+	// that doesn't share this database count as roots.
+	GetTraces(limit int) ([]models.Span, error)
+	GetTrace(traceID string) ([]models.Span, error)
+	// GetTraceTree returns the spans of a trace as a tree. Spans whose parent is unknown are returned as roots.
+	GetTraceTree(traceID string) ([]*SpanNode, error)
+}
+
+type SpanNode struct {
+	models.Span
+	Offset   time.Duration `json:"offset"` // start time relative to the start of the trace
+	Children []*SpanNode   `json:"children"`
+}
+
+// SpanExporter receives finished spans, e.g. to forward them to another tracing backend.
+type SpanExporter interface {
+	ExportSpan(span models.Span) error
+}
+
+type TracerImpl struct {
+	core      *AppImpl
+	exporters []SpanExporter
+}
+
+func (t *TracerImpl) GetApp() App {
+	return t.core
+}
+
+// Span is an operation in progress. It's persisted when it ends.
+type Span struct {
+	tracer *TracerImpl
+
+	mu         sync.Mutex
+	data       models.Span
+	attributes Map
+	ended      bool
+	traceState string
+	remote     bool // spans of other services are never persisted
+}
+
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (t *TracerImpl) StartSpan(ctx context.Context, name string, attributes ...Map) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{
+		tracer: t,
+		data: models.Span{
+			SpanID:    newSpanID(),
+			Name:      name,
+			Kind:      models.SpanKind_Internal,
+			StartTime: time.Now(),
+			Status:    models.SpanStatus_Unset,
+		},
+		attributes: Map{},
+	}
+
+	if parent, ok := t.SpanFromContext(ctx); ok {
+		span.data.TraceID = parent.TraceID()
+		span.data.ParentID = parent.SpanID()
+		span.traceState = parent.traceState
+	} else {
+		span.data.TraceID = newTraceID()
+	}
+
+	for _, attrs := range attributes {
+		span.SetAttributes(attrs)
+	}
+
+	return context.WithValue(ctx, spanContextKey, span), span
+}
+
+func (t *TracerImpl) SpanFromContext(ctx context.Context) (*Span, bool) {
+	return SpanFromContext(ctx)
+}
+
+// SpanFromContext returns the span started or continued in ctx, whichever tracer it belongs to.
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	span, ok := ctx.Value(spanContextKey).(*Span)
+	return span, ok
+}
+
+func (t *TracerImpl) StoreSpans(spans ...models.Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.core.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&spans).Error
+}
+
+func (t *TracerImpl) GetTraces(limit int) ([]models.Span, error) {
+	var spans []models.Span
+	knownSpans := t.core.db.Model(&models.Span{}).Select("span_id")
+	err := t.core.db.Model(&models.Span{}).
+		Where("parent_id = ? OR parent_id NOT IN (?)", "", knownSpans).
+		Order("start_time DESC").
+		Limit(limit).
+		Find(&spans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return spans, nil
+}
+
+func (t *TracerImpl) GetTrace(traceID string) ([]models.Span, error) {
+	var spans []models.Span
+	err := t.core.db.Model(&models.Span{}).Where("trace_id = ?", traceID).Order("start_time ASC").Find(&spans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return spans, nil
+}
+
+func (t *TracerImpl) GetTraceTree(traceID string) ([]*SpanNode, error) {
+	spans, err := t.GetTrace(traceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(spans) == 0 {
+		return []*SpanNode{}, nil
+	}
+
+	traceStart := spans[0].StartTime
+	nodes := map[string]*SpanNode{}
+	for _, span := range spans {
+		nodes[span.SpanID] = &SpanNode{
+			Span:     span,
+			Offset:   span.StartTime.Sub(traceStart),
+			Children: []*SpanNode{},
+		}
+	}
+
+	roots := []*SpanNode{}
+	for _, span := range spans {
+		node := nodes[span.SpanID]
+		parent, ok := nodes[span.ParentID]
+		if span.ParentID == "" || !ok {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	for _, node := range nodes {
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			return node.Children[i].StartTime.Before(node.Children[j].StartTime)
+		})
+	}
+
+	return roots, nil
+}
+
+func (s *Span) TraceID() string {
+	return s.data.TraceID
+}
+
+func (s *Span) SpanID() string {
+	return s.data.SpanID
+}
+
+func (s *Span) ParentID() string {
+	return s.data.ParentID
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return SpanContext{
+		TraceID:    s.data.TraceID,
+		SpanID:     s.data.SpanID,
+		TraceState: s.traceState,
+		Sampled:    true,
+	}
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+func (s *Span) SetKind(kind models.SpanKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Kind = kind
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *Span) SetAttributes(attributes Map) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range attributes {
+		s.attributes[k] = v
+	}
+}
+
+func (s *Span) SetStatus(status models.SpanStatus, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = status
+	s.data.StatusMessage = message
+}
+
+// RecordError marks the span as failed with the error's message. nil errors are ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(models.SpanStatus_Error, err.Error())
+}
+
+// End finishes the span, persists it and passes it to the span exporters. Calling End more than once has no effect.
+func (s *Span) End() error {
+	s.mu.Lock()
+	if s.ended || s.remote {
+		s.mu.Unlock()
+		return nil
+	}
+	s.ended = true
+
+	s.data.EndTime = time.Now()
+	s.data.Duration = s.data.EndTime.Sub(s.data.StartTime)
+	attributes, err := json.Marshal(s.attributes)
+	if err != nil {
+		attributes = []byte("{}")
+	}
+	s.data.Attributes = string(attributes)
+	data := s.data
+	s.mu.Unlock()
+
+	if err := s.tracer.core.db.Create(&data).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, exporter := range s.tracer.exporters {
+		if err := exporter.ExportSpan(data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
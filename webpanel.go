@@ -22,6 +22,10 @@ type WebPanel interface {
 	DeleteSession(token string) error
 	GetSession(token string) (*models.Session, error)
 	GetActiveSessions(userID uint) ([]models.Session, error)
+	CreateIngestToken(name string, allowedModels []Model) (models.IngestToken, string, error)
+	GetIngestTokens() ([]models.IngestToken, error)
+	DeleteIngestToken(id uint) error
+	ValidateIngestToken(token string) (*models.IngestToken, error)
 	GetDefaultLanguage() Language
 	Auth(r *http.Request) bool
 }